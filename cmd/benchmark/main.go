@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -29,7 +30,7 @@ type AllResults struct {
 }
 
 func main() {
-	fmt.Println("=== DAAR Project 3 - Performance Benchmarks ===\n")
+	fmt.Print("=== DAAR Project 3 - Performance Benchmarks ===\n\n")
 	fmt.Println("Loading index...")
 	idx, err := storage.LoadFromFile("data/index.json")
 	if err != nil {
@@ -45,7 +46,7 @@ func main() {
 
 	fmt.Println("Calculating PageRank...")
 	pageRank := ranking.CalculatePageRank(jaccardGraph, 20, 0.85)
-	fmt.Println(" PageRank calculated\n")
+	fmt.Print(" PageRank calculated\n\n")
 
 	results := AllResults{
 		SearchSimple:    []BenchmarkResult{},
@@ -129,7 +130,7 @@ func benchmarkSimpleSearch(idx *indexer.Indexer, pageRank map[int]float64, query
 
 	for i := 0; i < iterations; i++ {
 		start := time.Now()
-		results := search.Search(idx, query)
+		results, _ := search.Search(context.Background(), idx, query)
 		results = ranking.RankResults(results, pageRank)
 		elapsed := time.Since(start)
 
@@ -155,7 +156,7 @@ func benchmarkRegexSearch(idx *indexer.Indexer, pageRank map[int]float64, query
 
 	for i := 0; i < iterations; i++ {
 		start := time.Now()
-		results, _ := search.RegexSearch(idx, query)
+		results, _ := search.RegexSearch(context.Background(), idx, query)
 		results = ranking.RankResults(results, pageRank)
 		elapsed := time.Since(start)
 
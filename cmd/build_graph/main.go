@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"time"
@@ -10,11 +11,15 @@ import (
 )
 
 func main() {
-	fmt.Println("=== Building Jaccard Graph ===\n")
+	indexPath := flag.String("index", "data/index.json", "path of the index file")
+	graphPath := flag.String("graph", "data/jaccard_graph.json", "path of the graph file to write")
+	flag.Parse()
+
+	fmt.Print("=== Building Jaccard Graph ===\n\n")
 
 	// Load index
 	fmt.Println("Loading index...")
-	idx, err := storage.LoadFromFile("data/index.json")
+	idx, err := storage.LoadFromFile(*indexPath)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -29,7 +34,7 @@ func main() {
 
 	fmt.Printf("\n Graph built in %v\n", elapsed)
 	fmt.Println("\nSaving graph to disk...")
-	err = jaccardGraph.SaveToFile("data/jaccard_graph.json")
+	err = jaccardGraph.SaveToFile(*graphPath)
 	if err != nil {
 		fmt.Printf("Error saving: %v\n", err)
 		os.Exit(1)
@@ -1,32 +1,102 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"runtime"
 	"time"
 
 	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/shard"
 	"github.com/taqiyeddinedj/daar-project3/pkg/storage"
 )
 
 func main() {
+	incremental := flag.Bool("incremental", false, "only reindex books that changed since the last run")
+	booksDir := flag.String("books", "data/books", "directory containing book_<id>.txt files")
+	indexPath := flag.String("index", "data/index.json", "path of the index file")
+	manifestPath := flag.String("manifest", "data/index_manifest.json", "path of the manifest used by -incremental")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of books indexed in parallel")
+	segmentsDir := flag.String("segments", "", "add changed books as a new segment in this directory, then write the merged segments to -index")
+	shardSpec := flag.String("shard", "", "only index the books of one shard, given as i/N (e.g. 0/4)")
+	stopWordsDir := flag.String("stopwords", "data/stopwords", "directory of <language>.txt stop word lists overriding the bundled ones")
+	minLength := flag.Int("min-length", indexer.DefaultMinWordLength, "minimum number of letters of an indexed word (full builds only)")
+	foldAccents := flag.Bool("fold-accents", true, "also index words without their accents, so \"godel\" finds \"gödel\" (full builds only)")
+	flag.Parse()
+
+	if n, err := indexer.LoadStopWordsDir(*stopWordsDir); err != nil {
+		fmt.Printf("Error loading stop words: %v\n", err)
+		os.Exit(1)
+	} else if n > 0 {
+		fmt.Printf("Loaded %d stop word lists from %s\n", n, *stopWordsDir)
+	}
+
+	include, err := parseShard(*shardSpec)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *segmentsDir != "" {
+		ingestSegment(*booksDir, *segmentsDir, *indexPath, *workers, include)
+		return
+	}
+
 	fmt.Println("=== Building Search Index ===")
-	fmt.Println("This may take 30-60 minutes...")
-	fmt.Println()
 
 	startTime := time.Now()
 
-	// Create indexer
-	idx := indexer.NewIndexer()
+	var idx *indexer.Indexer
+	var manifest *indexer.Manifest
 
-	// Build index
-	fmt.Println("Step 1: Reading and indexing all books...")
-	err := idx.BuildIndexFromDirectory("data/books")
-	if err != nil {
-		fmt.Printf("Error building index: %v\n", err)
-		os.Exit(1)
+	if *incremental {
+		idx, manifest = loadPrevious(*indexPath, *manifestPath)
+	}
+
+	if idx == nil {
+		fmt.Println("This may take 30-60 minutes...")
+		fmt.Println()
+
+		// Create indexer
+		idx = indexer.NewIndexer()
+		idx.MinWordLength = *minLength
+		idx.FoldAccents = *foldAccents
+		manifest = indexer.NewManifest()
+
+		// Build index, recording what was indexed so the next -incremental run can skip it
+		fmt.Println("Step 1: Reading and indexing all books...")
+		err = idx.BuildIndexFromDirectory(*booksDir, indexer.BuildOptions{
+			Workers:  *workers,
+			Progress: printProgress,
+			Manifest: manifest,
+			Include:  include,
+		})
+		if err != nil {
+			fmt.Printf("Error building index: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Println()
+		fmt.Println("Step 1: Reindexing changed books...")
+		changes, err := idx.UpdateFromDirectory(*booksDir, manifest, indexer.BuildOptions{
+			Workers:  *workers,
+			Progress: printProgress,
+			Include:  include,
+		})
+		if err != nil {
+			fmt.Printf("Error updating index: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("  Added: %d, Updated: %d, Removed: %d, Unchanged: %d\n",
+			changes.Added, changes.Updated, changes.Removed, changes.Unchanged)
 	}
 
+	fmt.Printf("\n Indexing complete!\n")
+	fmt.Printf("  Total books: %d\n", len(idx.Books))
+	fmt.Printf("  Total words: %d\n", idx.TotalWords)
+	fmt.Printf("  Unique words: %d\n", idx.UniqueWords)
+
 	// Print stats
 	fmt.Println("\n=== Index Statistics ===")
 	stats := idx.GetStats()
@@ -36,14 +106,70 @@ func main() {
 
 	// Save index
 	fmt.Println("\nStep 2: Saving index to disk...")
-	indexPath := "data/index.json"
-	err = storage.SaveToFile(idx, indexPath)
+	err = storage.SaveToFile(idx, *indexPath)
 	if err != nil {
 		fmt.Printf("Error saving index: %v\n", err)
 		os.Exit(1)
 	}
 
+	err = manifest.SaveToFile(*manifestPath)
+	if err != nil {
+		fmt.Printf("Error saving manifest: %v\n", err)
+		os.Exit(1)
+	}
+
 	elapsed := time.Since(startTime)
 	fmt.Printf("\n Index built successfully in %v\n", elapsed)
-	fmt.Printf("Index saved to: %s\n", indexPath)
+	fmt.Printf("Index saved to: %s\n", *indexPath)
+}
+
+// parseShard turns an "i/N" shard spec into a book filter (nil when empty)
+func parseShard(spec string) (func(bookID int) bool, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var i, n int
+	if _, err := fmt.Sscanf(spec, "%d/%d", &i, &n); err != nil || n < 1 || i < 0 || i >= n {
+		return nil, fmt.Errorf("invalid -shard %q, expected i/N with 0 <= i < N", spec)
+	}
+
+	fmt.Printf("Indexing shard %d of %d\n", i, n)
+	return func(bookID int) bool {
+		return shard.Assign(bookID, n) == i
+	}, nil
+}
+
+// printProgress prints errors and a message every 100 books
+func printProgress(p indexer.Progress) {
+	if p.Err != nil {
+		fmt.Printf("Skipping %s: %v\n", p.Path, p.Err)
+		return
+	}
+	if p.Done%100 == 0 || p.Done == 1 || p.Done == p.Total {
+		fmt.Printf("Indexed book %d/%d (ID: %d)...\n", p.Done, p.Total, p.BookID)
+	}
+}
+
+// loadPrevious loads the index and manifest of the last run.
+// It returns a nil index when there is nothing to update, so a full build is done instead.
+func loadPrevious(indexPath, manifestPath string) (*indexer.Indexer, *indexer.Manifest) {
+	manifest, err := indexer.LoadManifest(manifestPath)
+	if err != nil {
+		fmt.Printf("Error loading manifest: %v\n", err)
+		os.Exit(1)
+	}
+	if len(manifest.Files) == 0 {
+		fmt.Println("No manifest found, doing a full build.")
+		return nil, nil
+	}
+
+	idx, err := storage.LoadFromFile(indexPath)
+	if err != nil {
+		fmt.Printf("Could not load previous index (%v), doing a full build.\n", err)
+		return nil, nil
+	}
+
+	fmt.Printf("Loaded previous index: %d books\n", len(idx.Books))
+	return idx, manifest
 }
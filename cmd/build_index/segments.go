@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/segment"
+	"github.com/taqiyeddinedj/daar-project3/pkg/storage"
+)
+
+// ingestSegment indexes the books that changed since the last run into a new segment,
+// tombstones deleted books and merges small segments, without touching the others.
+// The live books of all segments are then written as a single index for the server,
+// with the catalog metadata of the index it replaces.
+func ingestSegment(booksDir, segmentsDir, indexPath string, workers int, include func(bookID int) bool) {
+	fmt.Println("=== Ingesting New Segment ===")
+
+	startTime := time.Now()
+	manifestPath := filepath.Join(segmentsDir, "manifest.json")
+
+	si, err := segment.LoadFromDir(segmentsDir, segment.DefaultMergePolicy())
+	if err != nil {
+		fmt.Printf("Error loading segments: %v\n", err)
+		os.Exit(1)
+	}
+	manifest, err := indexer.LoadManifest(manifestPath)
+	if err != nil {
+		fmt.Printf("Error loading manifest: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Loaded %d segments\n", len(si.Segments()))
+
+	changed, removed, unchanged, err := manifest.Changes(booksDir)
+	if err != nil {
+		fmt.Printf("Error scanning %s: %v\n", booksDir, err)
+		os.Exit(1)
+	}
+	fmt.Printf("  Changed: %d, Removed: %d, Unchanged: %d\n", len(changed), len(removed), unchanged)
+
+	for _, path := range removed {
+		si.DeleteBook(manifest.Files[path].BookID)
+		delete(manifest.Files, path)
+	}
+
+	if seg := si.AddBooks(changed, indexer.BuildOptions{
+		Workers:  workers,
+		Progress: printProgress,
+		Manifest: manifest,
+		Include:  include,
+	}); seg != nil {
+		fmt.Printf("Added segment %d with %d books\n", seg.ID, len(seg.Index.Books))
+	}
+
+	for si.MaybeMerge() {
+		fmt.Println("Merged small segments")
+	}
+
+	if err := si.SaveToDir(segmentsDir); err != nil {
+		fmt.Printf("Error saving segments: %v\n", err)
+		os.Exit(1)
+	}
+	if err := manifest.SaveToFile(manifestPath); err != nil {
+		fmt.Printf("Error saving manifest: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The segments only hold what the book files say: the catalog metadata
+	// import_catalog added to the previous index is carried over
+	snapshot := si.Snapshot()
+	if previous, err := storage.LoadFromFile(indexPath); err == nil {
+		fmt.Printf("Kept the catalog metadata of %d books\n", snapshot.KeepCatalog(previous))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Error loading previous index: %v\n", err)
+		os.Exit(1)
+	}
+	if err := storage.SaveToFile(snapshot, indexPath); err != nil {
+		fmt.Printf("Error saving index: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("\n=== Segments ===")
+	for _, seg := range si.Segments() {
+		fmt.Printf("  segment %d: %d books (%d deleted)\n", seg.ID, len(seg.Index.Books), len(seg.Tombstones))
+	}
+	fmt.Printf("\n Segments saved to %s in %v\n", segmentsDir, time.Since(startTime))
+	fmt.Printf("Index of %d books saved to: %s\n", len(snapshot.Books), indexPath)
+}
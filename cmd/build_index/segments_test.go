@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/catalog"
+	"github.com/taqiyeddinedj/daar-project3/pkg/storage"
+)
+
+// writeBooks writes books of the catalog testdata, by ID
+func writeBooks(t *testing.T, dir string, books map[string]string) {
+	t.Helper()
+	for id, text := range books {
+		content := "Title: Book " + id + "\n\nLanguage: English\n\n" +
+			"*** START OF THE PROJECT GUTENBERG EBOOK " + id + " ***\n\n" + text +
+			"\n\n*** END OF THE PROJECT GUTENBERG EBOOK " + id + " ***\n"
+		if err := os.WriteFile(filepath.Join(dir, "book_"+id+".txt"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSegmentsKeepCatalog(t *testing.T) {
+	booksDir, dataDir := t.TempDir(), t.TempDir()
+	segmentsDir := filepath.Join(dataDir, "segments")
+	indexPath := filepath.Join(dataDir, "index.json")
+
+	writeBooks(t, booksDir, map[string]string{
+		"1342": "It is a truth universally acknowledged.",
+		"2701": "Call me Ishmael.",
+	})
+	ingestSegment(booksDir, segmentsDir, indexPath, 1, nil)
+
+	// Like import_catalog
+	idx, err := storage.LoadFromFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _, err := catalog.Load("../../pkg/catalog/testdata", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := catalog.Merge(idx, entries); stats.Merged != 2 {
+		t.Fatalf("catalog merged into %d books, want 2", stats.Merged)
+	}
+	if err := storage.SaveToFile(idx, indexPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// One book changes, one is added, one stays
+	writeBooks(t, booksDir, map[string]string{
+		"2701": "Call me Ishmael. Some years ago, never mind how long precisely.",
+		"2554": "On an exceptionally hot evening early in July.",
+	})
+	ingestSegment(booksDir, segmentsDir, indexPath, 1, nil)
+
+	idx, err = storage.LoadFromFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Books) != 3 {
+		t.Fatalf("%d books after the second run, want 3", len(idx.Books))
+	}
+	want := map[int][]string{
+		1342: {"Courtship -- Fiction", "England -- Fiction", "Sisters -- Fiction"},
+		2701: {"Whaling -- Fiction", "Sea stories"},
+		2554: nil,
+	}
+	for id, subjects := range want {
+		book := idx.Books[id]
+		if !reflect.DeepEqual(book.Subjects, subjects) {
+			t.Errorf("subjects of %d = %v, want %v", id, book.Subjects, subjects)
+		}
+		if subjects != nil && len(book.Authors) == 0 {
+			t.Errorf("authors of %d lost", id)
+		}
+	}
+	if len(idx.Books[2701].Bookshelves) != 1 {
+		t.Errorf("bookshelves of 2701 = %v", idx.Books[2701].Bookshelves)
+	}
+
+	// The subjects are still searchable
+	if _, found := idx.Fields["subject:whale"][2701]; !found {
+		t.Errorf("subject:whale doesn't find 2701: %v", idx.Fields["subject:whale"])
+	}
+	if _, found := idx.Fields["subject:courtship"][1342]; !found {
+		t.Errorf("subject:courtship doesn't find 1342: %v", idx.Fields["subject:courtship"])
+	}
+}
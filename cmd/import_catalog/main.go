@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/catalog"
+	"github.com/taqiyeddinedj/daar-project3/pkg/storage"
+)
+
+// import_catalog adds the metadata of the Gutenberg RDF catalog (subjects,
+// bookshelves, LCC classification, authors' years) to the books of an index.
+// The catalog can be downloaded from https://www.gutenberg.org/cache/epub/feeds/rdf-files.tar.bz2
+func main() {
+	catalogPath := flag.String("catalog", "data/rdf-files.tar.bz2", "directory or tarball of pg<id>.rdf files")
+	indexPath := flag.String("index", "data/index.json", "path of the index file")
+	flag.Parse()
+
+	fmt.Println("=== Importing Gutenberg Catalog ===")
+	startTime := time.Now()
+
+	fmt.Printf("Loading index from %s...\n", *indexPath)
+	idx, err := storage.LoadFromFile(*indexPath)
+	if err != nil {
+		fmt.Printf("Error loading index: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Reading catalog from %s...\n", *catalogPath)
+	entries, loadStats, err := catalog.Load(*catalogPath, func(id int) bool {
+		_, indexed := idx.Books[id]
+		return indexed
+	})
+	if err != nil {
+		fmt.Printf("Error reading catalog: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  RDF files: %d, for indexed books: %d, unreadable: %d\n",
+		loadStats.Files, loadStats.Loaded, loadStats.Failed)
+
+	mergeStats := catalog.Merge(idx, entries)
+	fmt.Printf("  Books updated: %d of %d\n", mergeStats.Merged, len(idx.Books))
+
+	if err := storage.SaveToFile(idx, *indexPath); err != nil {
+		fmt.Printf("Error saving index: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n Catalog imported in %v\n", time.Since(startTime))
+	fmt.Printf("Index saved to: %s\n", *indexPath)
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math"
+	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
 
 	"github.com/gin-gonic/gin"
+	"github.com/taqiyeddinedj/daar-project3/pkg/cache"
 	"github.com/taqiyeddinedj/daar-project3/pkg/graph"
 	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/library"
 	"github.com/taqiyeddinedj/daar-project3/pkg/models"
 	"github.com/taqiyeddinedj/daar-project3/pkg/ranking"
 	"github.com/taqiyeddinedj/daar-project3/pkg/search"
@@ -20,47 +32,83 @@ var (
 	idx          *indexer.Indexer
 	jaccardGraph *graph.JaccardGraph
 	pageRank     map[int]float64
+	bookFiles    *library.Library
+	speller      *search.Speller
+	completer    *search.Completer
+
+	// dataMu guards the data above, which a reload replaces (see holdData)
+	dataMu sync.RWMutex
+	// resultCache keeps the ranked results of recent searches
+	resultCache *cache.LRU[*rankedSearch]
+	// searchLimits bound every search (see the -max-terms, -max-results and -search-timeout flags)
+	searchLimits = search.DefaultLimits
+)
+
+// defaultPerPage and maxPerPage bound the per_page parameter of searches
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
 )
 
 type SearchResponse struct {
+	// Books are the books of the Results of the page
 	Books      []models.Book         `json:"books"`
 	Results    []models.SearchResult `json:"results"`
 	TotalCount int                   `json:"total_count"`
 	Page       int                   `json:"page"`
 	PerPage    int                   `json:"per_page"`
 	TotalPages int                   `json:"total_pages"`
+	// NextCursor asks for the next page (cursor=...), empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Terms maps each matched index term to the words found in the books
+	Terms map[string][]string `json:"terms"`
+	// Variants maps each fuzzy word or glob pattern of the query to the words of the books it matched
+	Variants map[string][]string `json:"variants,omitempty"`
+	// Suggestions are corrections of the words of the query found in few books,
+	// and DidYouMean the query with the best of them
+	Suggestions []search.Suggestion `json:"suggestions,omitempty"`
+	DidYouMean  string              `json:"did_you_mean,omitempty"`
+	// Partial lists the limits the search reached ("terms", "results", "time"):
+	// the results are then those found until then. After "time", the next pages
+	// come from the search run again, which may find other books.
+	Partial search.Partial `json:"partial,omitempty"`
+	// Facets counts the authors, languages, subjects, clusters and lengths of all the matches
+	Facets search.Facets `json:"facets"`
 }
 
 func main() {
-	fmt.Println("=== Starting Search Engine Server ===\n")
-
-	fmt.Println("Loading index...")
-	var err error
-	idx, err = storage.LoadFromFile("data/index.json")
-	if err != nil {
-		log.Fatal(err)
+	addr := flag.String("addr", ":8080", "address to listen on")
+	indexPath := flag.String("index", "data/index.json", "path of the index file")
+	graphPath := flag.String("graph", "data/jaccard_graph.json", "path of the Jaccard graph file")
+	shardMode := flag.Bool("shard", false, "also serve the /api/shard endpoints used by a coordinator")
+	shardURLs := flag.String("shards", "", "comma separated shard URLs; run as a coordinator instead of loading an index")
+	libraryDir := flag.String("library", "data/books", "directory of the book_<id>.txt files of the index")
+	stopWordsDir := flag.String("stopwords", "data/stopwords", "directory of stop word lists, must be the one the index was built with")
+	maxTerms := flag.Int("max-terms", search.DefaultLimits.MaxTerms, "number of matching terms a search reads at most, 0 for no limit")
+	maxResults := flag.Int("max-results", search.DefaultLimits.MaxResults, "number of books a search keeps at most, the most relevant, 0 for no limit")
+	searchTimeout := flag.Duration("search-timeout", search.DefaultLimits.Timeout, "time a search may take, 0 for no limit")
+	cacheSize := flag.Int64("cache-size", 64, "megabytes of ranked search results kept in memory, 0 for no cache")
+	flag.Parse()
+
+	searchLimits = search.Limits{
+		MaxTerms:   *maxTerms,
+		MaxResults: *maxResults,
+		Timeout:    *searchTimeout,
 	}
-	fmt.Printf("✓ Index: %d books\n", len(idx.Books))
 
-	fmt.Println("Loading Jaccard graph...")
-	jaccardGraph, err = graph.LoadGraphFromFile("data/jaccard_graph.json")
-	if err != nil {
-		log.Fatal(err)
+	if _, err := indexer.LoadStopWordsDir(*stopWordsDir); err != nil {
+		log.Fatalf("Failed to load stop words: %v", err)
 	}
-	fmt.Printf("✓ Graph: %d edges\n", jaccardGraph.EdgeCount)
-
-	fmt.Println("Calculating PageRank...")
-	pageRank = ranking.CalculatePageRank(jaccardGraph, 20, 0.85)
-	fmt.Println("✓ PageRank calculated")
 
-	fmt.Println("\n🚀 Server: http://localhost:8080\n")
+	fmt.Print("=== Starting Search Engine Server ===\n\n")
 
 	r := gin.Default()
 
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Range, If-None-Match, If-Modified-Since, If-Range")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Range, Content-Length, Accept-Ranges, ETag, Last-Modified")
 		c.Next()
 	})
 
@@ -68,12 +116,170 @@ func main() {
 	r.LoadHTMLGlob("web/templates/*")
 
 	r.GET("/", homeHandler)
+
+	if *shardURLs != "" {
+		if err := setupCoordinator(r, strings.Split(*shardURLs, ",")); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		data, err := loadData(*indexPath, *graphPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data.use()
+		reloadOnSignal(*indexPath, *graphPath)
+
+		resultCache = cache.New[*rankedSearch](*cacheSize << 20)
+		fmt.Printf("✓ Result cache: %d MB\n", *cacheSize)
+
+		bookFiles = library.New(*libraryDir)
+		if err := bookFiles.Check(); err != nil {
+			fmt.Printf("⚠ Library: %v, book contents won't be available\n", err)
+		} else {
+			fmt.Printf("✓ Library: %s\n", *libraryDir)
+		}
+
+		setupServer(r, *shardMode)
+	}
+
+	fmt.Printf("\n🚀 Server: http://localhost%s\n\n", *addr)
+
+	log.Fatal(r.Run(*addr))
+}
+
+// setupServer registers the routes of a server searching its own index, and the
+// endpoints a coordinator calls when it is a shard
+func setupServer(r *gin.Engine, shardMode bool) {
+	r.Use(holdData)
 	r.GET("/api/search", searchHandler)
+	r.GET("/api/cache/stats", cacheStatsHandler)
+	r.GET("/api/suggest", suggestHandler)
 	r.GET("/api/book/:id", bookDetailHandler)
+	r.GET("/api/book/:id/search", bookSearchHandler)
+	r.GET("/api/book/:id/toc", tocHandler)
+	r.GET("/api/book/:id/chapters/:n", chapterHandler)
 	r.GET("/api/recommendations/:id", recommendHandler)
 	r.GET("/api/content/:id", contentHandler)
+	r.GET("/api/book/:id/text", textHandler)
+	r.HEAD("/api/book/:id/text", textHandler)
+
+	if shardMode {
+		r.GET("/api/shard/stats", shardStatsHandler)
+		r.POST("/api/shard/search", shardSearchHandler)
+		r.POST("/api/shard/snippets", shardSnippetsHandler)
+		fmt.Println("✓ Shard endpoints enabled")
+	}
+}
+
+// serverData is what the server loads from the index and graph files, and computes from them
+type serverData struct {
+	idx          *indexer.Indexer
+	jaccardGraph *graph.JaccardGraph
+	pageRank     map[int]float64
+	speller      *search.Speller
+	completer    *search.Completer
+}
+
+// loadData loads the index and graph and computes PageRank
+func loadData(indexPath, graphPath string) (*serverData, error) {
+	d := &serverData{}
+
+	fmt.Println("Loading index...")
+	var err error
+	d.idx, err = storage.LoadFromFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("✓ Index: %d books\n", len(d.idx.Books))
+	fmt.Printf("✓ Vocabulary: %d words\n", len(d.idx.Vocabulary().Words))
+
+	d.speller = search.NewSpeller(d.idx)
+	words, deletes := d.speller.Size()
+	fmt.Printf("✓ Spelling dictionary: %d words, %d deletes\n", words, deletes)
+
+	d.completer = search.NewCompleter(d.idx)
+	fmt.Printf("✓ Autocomplete: %d trie nodes\n", d.completer.Size())
+
+	fmt.Println("Loading Jaccard graph...")
+	d.jaccardGraph, err = graph.LoadGraphFromFile(graphPath)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("✓ Graph: %d edges\n", d.jaccardGraph.EdgeCount)
+
+	fmt.Println("Calculating PageRank...")
+	d.pageRank = ranking.CalculatePageRank(d.jaccardGraph, 20, 0.85)
+	fmt.Println("✓ PageRank calculated")
+
+	fmt.Println("Finding clusters...")
+	assignClusters(d.idx, d.jaccardGraph)
+	return d, nil
+}
+
+// use makes the handlers serve the data
+func (d *serverData) use() {
+	idx = d.idx
+	jaccardGraph = d.jaccardGraph
+	pageRank = d.pageRank
+	speller = d.speller
+	completer = d.completer
+}
+
+// reloadData loads the index and graph files again, once they were rebuilt, and
+// serves them instead of the current ones, which are kept if loading fails.
+// The result cache is emptied: its results were ranked with the old index and PageRank.
+func reloadData(indexPath, graphPath string) error {
+	d, err := loadData(indexPath, graphPath)
+	if err != nil {
+		return err
+	}
+
+	dataMu.Lock()
+	defer dataMu.Unlock()
+	d.use()
+	resultCache.Clear()
+	return nil
+}
+
+// reloadOnSignal reloads the data when the server gets SIGHUP (kill -HUP <pid>)
+func reloadOnSignal(indexPath, graphPath string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			fmt.Println("Reloading data...")
+			if err := reloadData(indexPath, graphPath); err != nil {
+				log.Printf("Reload failed, still serving the previous data: %v", err)
+				continue
+			}
+			fmt.Println("✓ Data reloaded")
+		}
+	}()
+}
+
+// holdData keeps the data a request started with until it ends: a reload
+// waits for the requests running, and the next ones wait for the reload
+func holdData(c *gin.Context) {
+	dataMu.RLock()
+	defer dataMu.RUnlock()
+	c.Next()
+}
+
+// assignClusters sets the cluster of every book from the Jaccard graph
+func assignClusters(idx *indexer.Indexer, jaccardGraph *graph.JaccardGraph) {
+	bookIDs := make([]int, 0, len(idx.Books))
+	for id := range idx.Books {
+		bookIDs = append(bookIDs, id)
+	}
 
-	log.Fatal(r.Run(":8080"))
+	clusters := graph.FindClusters(jaccardGraph, bookIDs)
+	names := make(map[int]bool)
+	for id, book := range idx.Books {
+		book.Cluster = clusters[id]
+		idx.Books[id] = book
+		names[book.Cluster] = true
+	}
+	fmt.Printf("✓ Clusters: %d\n", len(names))
 }
 
 func homeHandler(c *gin.Context) {
@@ -81,62 +287,274 @@ func homeHandler(c *gin.Context) {
 }
 
 func searchHandler(c *gin.Context) {
-	query := c.Query("q")
-	searchType := c.Query("type")
-	pageStr := c.DefaultQuery("page", "1")
-
-	page, _ := strconv.Atoi(pageStr)
-	if page < 1 {
-		page = 1
+	query, searchType, p, err := searchParams(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
-	perPage := 20
+	filters := searchFilters(c)
 
-	var results []models.SearchResult
-	if searchType == "regex" {
-		var err error
-		results, err = search.RegexSearch(idx, query)
+	// The pages of a search, and the searches repeated, are cut from the same ranked results
+	key := searchKey(query, searchType, filters)
+	ranked, cached := resultCache.Get(key)
+	if cached {
+		c.Header("X-Cache", "HIT")
+	} else {
+		c.Header("X-Cache", "MISS")
+		ranked, err = rankSearch(c.Request.Context(), query, searchType, filters)
 		if err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+			if !searchCanceled(c, err) {
+				c.JSON(400, gin.H{"error": err.Error()})
+			}
 			return
 		}
-	} else {
-		results = search.Search(idx, query)
+		// Results cut by the time limit depend on the time the search had: the next one may find more
+		if !ranked.partial.Has(search.LimitTime) {
+			resultCache.Add(key, ranked, ranked.size())
+		}
 	}
 
-	results = ranking.RankResults(results, pageRank)
+	response := newSearchResponse(p.pick(ranked.results), len(ranked.results), p)
+	response.Terms = ranked.terms
+	response.Variants = ranked.query.Variants
+	response.Suggestions = ranked.suggestions
+	response.DidYouMean = search.CorrectQuery(query, ranked.suggestions)
+	response.Facets = ranked.facets
+	response.Partial = ranked.partial
 
-	// Extract books for pagination
-	var books []models.Book
-	for _, r := range results {
-		books = append(books, r.Book)
+	if explain, _ := strconv.ParseBool(c.Query("explain")); explain {
+		explainResults(response.Results, ranked.query, ranked.stats, pageRank)
 	}
+	addSnippets(c.Request.Context(), response.Results, ranked.query)
+	c.JSON(200, response)
+}
 
-	totalCount := len(books)
-	totalPages := (totalCount + perPage - 1) / perPage
-	start := (page - 1) * perPage
-	end := start + perPage
+// rankedSearch is a search with all its results ranked, before they are paginated
+type rankedSearch struct {
+	query       search.Query
+	stats       search.CorpusStats
+	results     []models.SearchResult // in no particular order (see pagination.pick)
+	terms       map[string][]string
+	facets      search.Facets
+	suggestions []search.Suggestion
+	partial     search.Partial
+}
 
-	var paginatedBooks []models.Book
+// rankSearch runs a search and ranks its results. The search stops when the client
+// goes away or at the limits, which leave the results found until then.
+func rankSearch(ctx context.Context, query, searchType string, filters search.Filters) (*rankedSearch, error) {
+	ctx, cancel := searchLimits.Context(ctx)
+	defer cancel()
+	s := &rankedSearch{}
 
-	if start >= totalCount {
-		paginatedBooks = []models.Book{}
-	} else {
-		if end > totalCount {
-			end = totalCount
+	var err error
+	s.query, err = search.MatchQuery(ctx, idx, query, searchType)
+	if err = s.partial.Check(err); err != nil {
+		return nil, err
+	}
+
+	s.stats = search.LocalStats(idx, s.query.Terms)
+	results, err := search.ScoreQuery(ctx, idx, s.query, s.stats)
+	if err = s.partial.Check(err); err != nil {
+		return nil, err
+	}
+	s.facets = search.ComputeFacets(idx, results, filters).Top(search.FacetLimit)
+	s.results = filters.Apply(results)
+	ranking.ApplyPageRank(s.results, pageRank)
+
+	s.terms = search.TermForms(idx, s.query.Terms)
+	if spellChecked(searchType) {
+		s.suggestions = speller.Suggest(query)
+	}
+	return s, nil
+}
+
+// size estimates the memory a ranked search holds in the result cache. The books
+// of the results share their strings with the index: only the results count.
+func (s *rankedSearch) size() int64 {
+	size := int64(unsafe.Sizeof(*s))
+	size += int64(len(s.results)) * int64(unsafe.Sizeof(models.SearchResult{}))
+	for _, term := range s.query.Terms {
+		// the term in the query, the stats and the terms with their words
+		size += 3*int64(len(term)) + 64
+		for _, form := range s.terms[term] {
+			size += int64(len(form)) + 16
+		}
+	}
+	return size
+}
+
+// searchKey identifies the ranked results of a search in the result cache:
+// the ranking profile, the search type, the normalized query and the filters
+func searchKey(query, searchType string, filters search.Filters) string {
+	encodedFilters, _ := json.Marshal(filters)
+	return strings.Join([]string{
+		ranking.Profile(),
+		searchType,
+		search.NormalizeQuery(query, searchType),
+		string(encodedFilters),
+	}, "\x00")
+}
+
+// cacheStatsHandler reports the hits and misses of the result cache
+func cacheStatsHandler(c *gin.Context) {
+	c.JSON(200, resultCache.Stats())
+}
+
+// explainResults breaks down the relevance of ranked results into their BM25 and PageRank parts
+func explainResults(results []models.SearchResult, q search.Query, stats search.CorpusStats, pageRank map[int]float64) {
+	for i := range results {
+		search.Explain(idx, q, stats, &results[i])
+		ranking.Explain(&results[i], pageRank)
+	}
+}
+
+// searchCanceled reports whether a search failed because its client went away,
+// in which case nobody is left to answer
+func searchCanceled(c *gin.Context, err error) bool {
+	if !errors.Is(err, context.Canceled) {
+		return false
+	}
+	log.Printf("Search canceled: %s", c.Request.URL.RequestURI())
+	c.Abort()
+	return true
+}
+
+// suggestHandler completes what was typed in the search box
+func suggestHandler(c *gin.Context) {
+	c.JSON(200, completer.Complete(c.Query("prefix"), suggestLimit(c)))
+}
+
+// suggestLimit reads the number of completions of each kind wanted (8 by default)
+func suggestLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "8"))
+	if err != nil || limit < 1 {
+		limit = 8
+	}
+	return min(limit, search.MaxCompletions)
+}
+
+// searchParams reads the query parameters shared by the search handlers
+func searchParams(c *gin.Context) (query, searchType string, p pagination, err error) {
+	query = c.Query("q")
+	searchType = c.Query("type")
+
+	p.page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if p.page < 1 {
+		p.page = 1
+	}
+	p.perPage, err = strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultPerPage)))
+	if err != nil || p.perPage < 1 {
+		p.perPage = defaultPerPage
+	}
+	p.perPage = min(p.perPage, maxPerPage)
+	// Past this page, the number of results up to it overflows
+	p.page = min(p.page, (math.MaxInt-1)/p.perPage)
+
+	if s := c.Query("cursor"); s != "" {
+		cursor, err := search.ParseCursor(s)
+		if err != nil {
+			return query, searchType, p, err
+		}
+		p.cursor = &cursor
+	}
+	return query, searchType, p, nil
+}
+
+// pagination is the page of results a search asks for: the results after a cursor
+// (the next_cursor of the previous page) if there is one, else the page number.
+// Cursors stay on the same results as more pages are read, and only the
+// results after them are ranked.
+type pagination struct {
+	page, perPage int
+	cursor        *search.Cursor
+}
+
+// limit is the number of best results the page is picked from, with one more
+// than it shows to know whether another page follows
+func (p pagination) limit() int {
+	if p.cursor != nil {
+		return p.perPage + 1
+	}
+	return p.page*p.perPage + 1
+}
+
+// pick returns the ranked results of the page out of all the matches,
+// followed by the first result of the next page if there is one
+func (p pagination) pick(results []models.SearchResult) []models.SearchResult {
+	if p.cursor != nil {
+		return search.TopResultsAfter(results, *p.cursor, p.limit())
+	}
+	// A page past the last one has nothing to rank
+	start := (p.page - 1) * p.perPage
+	if start >= len(results) {
+		return nil
+	}
+	return search.TopResults(results, p.limit())[start:]
+}
+
+// spellChecked reports whether the words of a search type may get corrections:
+// keyword searches, not patterns, fuzzy words or metadata fields
+func spellChecked(searchType string) bool {
+	return searchType == "" || searchType == "keyword"
+}
+
+// searchFilters reads the filters of a search: lang=fr or lang=fr,de, and the facets
+// author, subject, cluster and length, repeated for several values
+// (author=Jane+Austen&author=Herman+Melville) as names and subjects contain commas
+func searchFilters(c *gin.Context) search.Filters {
+	var filters search.Filters
+	for _, value := range c.QueryArray("lang") {
+		for _, language := range strings.Split(value, ",") {
+			if language = strings.TrimSpace(language); language != "" {
+				filters.Languages = append(filters.Languages, language)
+			}
+		}
+	}
+	filters.Authors = queryValues(c, "author")
+	filters.Subjects = queryValues(c, "subject")
+	filters.Clusters = queryValues(c, "cluster")
+	filters.Lengths = queryValues(c, "length")
+	return filters
+}
+
+// queryValues returns the non-empty values of a repeated query parameter
+func queryValues(c *gin.Context, name string) []string {
+	var values []string
+	for _, value := range c.QueryArray(name) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
 		}
-		paginatedBooks = books[start:end]
+	}
+	return values
+}
+
+// newSearchResponse builds the response of a page from the results picked for it
+// (see pagination.pick); totalCount is the number of matches
+func newSearchResponse(results []models.SearchResult, totalCount int, p pagination) SearchResponse {
+	var nextCursor string
+	if len(results) > p.perPage {
+		results = results[:p.perPage]
+		nextCursor = search.CursorOf(results[len(results)-1]).String()
 	}
 
-	response := SearchResponse{
-		Books:      paginatedBooks,
+	books := make([]models.Book, 0, len(results))
+	for _, r := range results {
+		books = append(books, r.Book)
+	}
+	if results == nil {
+		results = []models.SearchResult{}
+	}
+
+	return SearchResponse{
+		Books:      books,
 		Results:    results,
 		TotalCount: totalCount,
-		Page:       page,
-		PerPage:    perPage,
-		TotalPages: totalPages,
+		Page:       p.page,
+		PerPage:    p.perPage,
+		TotalPages: (totalCount + p.perPage - 1) / p.perPage,
+		NextCursor: nextCursor,
 	}
-
-	c.JSON(200, response)
 }
 
 func bookDetailHandler(c *gin.Context) {
@@ -151,6 +569,45 @@ func bookDetailHandler(c *gin.Context) {
 	c.JSON(200, book)
 }
 
+// bookSearchHandler finds every match of a query inside one book, for the reader
+func bookSearchHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	book, exists := idx.Books[id]
+	if !exists {
+		c.JSON(404, gin.H{"error": "Book not found"})
+		return
+	}
+
+	query := c.Query("q")
+	ctx, cancel := searchLimits.Context(c.Request.Context())
+	defer cancel()
+	// Past the limits, the matches are those of the terms found
+	q, err := search.MatchQuery(ctx, idx, query, c.Query("type"))
+	if err != nil && !search.IsLimit(err) {
+		if !searchCanceled(c, err) {
+			c.JSON(400, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	content, err := bookFiles.ReadFile(book)
+	if err != nil {
+		bookFileError(c, book, err)
+		return
+	}
+
+	text := string(content)
+	matches, truncated := search.NewHighlighter(idx, q).Matches(text, book.Language, bookChapters(book, text))
+	c.JSON(200, gin.H{
+		"book_id":   book.ID,
+		"query":     query,
+		"total":     len(matches),
+		"truncated": truncated,
+		"matches":   matches,
+	})
+}
+
 func recommendHandler(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 
@@ -165,6 +622,7 @@ func recommendHandler(c *gin.Context) {
 	c.JSON(200, books)
 }
 
+// contentHandler returns a whole book in JSON; /api/book/:id/text streams it instead
 func contentHandler(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 
@@ -174,36 +632,12 @@ func contentHandler(c *gin.Context) {
 		return
 	}
 
-	possiblePaths := []string{
-		book.FilePath,
-		filepath.Join("data/books", book.FilePath),
-		filepath.Join("books", book.FilePath),
-	}
-
-	var content []byte
-	var err error
-	var successPath string
-
-	for _, path := range possiblePaths {
-		content, err = os.ReadFile(path)
-		if err == nil {
-			successPath = path
-			break
-		}
-	}
-
+	content, err := bookFiles.ReadFile(book)
 	if err != nil {
-		log.Printf("Failed to read book %d. Tried paths: %v", id, possiblePaths)
-		c.JSON(500, gin.H{
-			"error":       "Failed to read book content",
-			"details":     fmt.Sprintf("Book ID: %d, FilePath: %s", id, book.FilePath),
-			"tried_paths": possiblePaths,
-		})
+		bookFileError(c, book, err)
 		return
 	}
 
-	log.Printf("✓ Successfully read book %d from: %s", id, successPath)
-
 	c.JSON(200, gin.H{
 		"book_id": book.ID,
 		"title":   book.Title,
@@ -211,3 +645,65 @@ func contentHandler(c *gin.Context) {
 		"content": string(content),
 	})
 }
+
+// addSnippets fills the snippets of the results of a page, reading their books in parallel.
+// Books are not read anymore once ctx ends.
+func addSnippets(ctx context.Context, results []models.SearchResult, q search.Query) {
+	highlighter := search.NewHighlighter(idx, q)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(r *models.SearchResult) {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
+			content, err := bookFiles.ReadFile(r.Book)
+			if err != nil {
+				log.Printf("No snippets for book %d: %v", r.Book.ID, err)
+				return
+			}
+			r.Snippets = highlighter.Snippets(string(content), r.Book.Language)
+		}(&results[i])
+	}
+	wg.Wait()
+}
+
+// textHandler streams the file of a book as plain text. Range requests let clients
+// fetch it in parts; ETag and Last-Modified let them revalidate their copy
+// (If-None-Match, If-Modified-Since, If-Range), all handled by http.ServeContent.
+func textHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	book, exists := idx.Books[id]
+	if !exists {
+		c.JSON(404, gin.H{"error": "Book not found"})
+		return
+	}
+
+	file, info, err := bookFiles.Open(book)
+	if err != nil {
+		bookFileError(c, book, err)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("ETag", library.ETag(info))
+	c.Header("Cache-Control", "no-cache")
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), file)
+}
+
+// bookFileError answers a request for a book whose file can't be read
+func bookFileError(c *gin.Context, book models.Book, err error) {
+	log.Printf("Failed to read book %d: %v", book.ID, err)
+	if os.IsNotExist(err) {
+		c.JSON(404, gin.H{
+			"error":   "Book file not found",
+			"details": fmt.Sprintf("Book ID: %d, expected at %s", book.ID, bookFiles.Path(book)),
+		})
+		return
+	}
+	c.JSON(500, gin.H{"error": "Failed to read book content"})
+}
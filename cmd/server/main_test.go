@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taqiyeddinedj/daar-project3/pkg/cache"
+	"github.com/taqiyeddinedj/daar-project3/pkg/graph"
+	"github.com/taqiyeddinedj/daar-project3/pkg/library"
+	"github.com/taqiyeddinedj/daar-project3/pkg/storage"
+)
+
+// saveData writes the index and graph files of the books of a directory, like build_index and build_graph
+func saveData(t *testing.T, booksDir, indexPath, graphPath string) {
+	t.Helper()
+	idx := buildData(t, booksDir, nil).idx
+	if err := storage.SaveToFile(idx, indexPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.BuildJaccardGraph(idx, 0.1).SaveToFile(graphPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// searchCache runs a search and returns its total count and X-Cache header
+func searchCache(t *testing.T, baseURL, query string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(baseURL + "/api/search?q=" + query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var response SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return response.TotalCount, resp.Header.Get("X-Cache")
+}
+
+func TestReloadClearsCache(t *testing.T) {
+	booksDir := writeLibrary(t)
+	dataDir := t.TempDir()
+	indexPath := filepath.Join(dataDir, "index.json")
+	graphPath := filepath.Join(dataDir, "jaccard_graph.json")
+	saveData(t, booksDir, indexPath, graphPath)
+
+	d, err := loadData(indexPath, graphPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.use()
+	bookFiles = library.New(booksDir)
+	resultCache = cache.New[*rankedSearch](1 << 20)
+	t.Cleanup(func() { resultCache = nil })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupServer(r, false)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	reloadOnSignal(indexPath, graphPath)
+
+	before, status := searchCache(t, srv.URL, "whale")
+	if status != "MISS" {
+		t.Errorf("first search: X-Cache %s, want MISS", status)
+	}
+	if _, status := searchCache(t, srv.URL, "whale"); status != "HIT" {
+		t.Errorf("repeated search: X-Cache %s, want HIT", status)
+	}
+
+	// A new book about whales, then the files are rebuilt and the server told so
+	content := "Title: Book 13\n\nLanguage: English\n\n*** START OF THE PROJECT GUTENBERG EBOOK BOOK 13 ***\n\n" +
+		"whale whale whale.\n\n*** END OF THE PROJECT GUTENBERG EBOOK BOOK 13 ***\n"
+	if err := os.WriteFile(filepath.Join(booksDir, "book_13.txt"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saveData(t, booksDir, indexPath, graphPath)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for resultCache.Stats().Clears == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the cache was not cleared after SIGHUP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := resultCache.Stats()
+	if stats.Entries != 0 || stats.Hits != 0 || stats.Clears != 1 {
+		t.Errorf("cache stats after reload = %+v, want it empty", stats)
+	}
+
+	after, status := searchCache(t, srv.URL, "whale")
+	if status != "MISS" {
+		t.Errorf("search after reload: X-Cache %s, want MISS", status)
+	}
+	if after != before+1 {
+		t.Errorf("search after reload found %d books, want %d with the new one", after, before+1)
+	}
+	dataMu.RLock()
+	defer dataMu.RUnlock()
+	if _, found := idx.Books[13]; !found {
+		t.Error("book 13 not served after reload")
+	}
+}
+
+// getText requests the text of a book with the given headers
+func getText(t *testing.T, url string, header map[string]string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestBookText(t *testing.T) {
+	booksDir := t.TempDir()
+	content := "Title: Moby Dick\n\n*** START OF THE PROJECT GUTENBERG EBOOK MOBY DICK ***\n\nCall me Ishmael.\n\n*** END OF THE PROJECT GUTENBERG EBOOK MOBY DICK ***\n"
+	if err := os.WriteFile(filepath.Join(booksDir, "book_1.txt"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := serveData(t, buildData(t, booksDir, nil), booksDir)
+	url := srv.URL + "/api/book/1/text"
+
+	resp, body := getText(t, url, nil)
+	etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != 200 || body != content {
+		t.Fatalf("text: status %d, body %q", resp.StatusCode, body)
+	}
+	if etag == "" || modified == "" || resp.Header.Get("Accept-Ranges") != "bytes" {
+		t.Errorf("text: headers %v", resp.Header)
+	}
+
+	resp, body = getText(t, url, map[string]string{"Range": "bytes=7-15"})
+	if resp.StatusCode != 206 || body != "Moby Dick" {
+		t.Errorf("range: status %d, body %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Range"); got != fmt.Sprintf("bytes 7-15/%d", len(content)) {
+		t.Errorf("range: Content-Range %q", got)
+	}
+	if resp, _ = getText(t, url, map[string]string{"Range": "bytes=100000-"}); resp.StatusCode != 416 {
+		t.Errorf("range past the end: status %d", resp.StatusCode)
+	}
+
+	if resp, body = getText(t, url, map[string]string{"If-None-Match": etag}); resp.StatusCode != 304 || body != "" {
+		t.Errorf("If-None-Match: status %d, body %q", resp.StatusCode, body)
+	}
+	if resp, _ = getText(t, url, map[string]string{"If-None-Match": `"other"`}); resp.StatusCode != 200 {
+		t.Errorf("If-None-Match of another version: status %d", resp.StatusCode)
+	}
+	if resp, _ = getText(t, url, map[string]string{"If-Modified-Since": modified}); resp.StatusCode != 304 {
+		t.Errorf("If-Modified-Since: status %d", resp.StatusCode)
+	}
+	if resp, _ = getText(t, url, map[string]string{"If-Modified-Since": "Mon, 02 Jan 2006 15:04:05 GMT"}); resp.StatusCode != 200 {
+		t.Errorf("If-Modified-Since before the file: status %d", resp.StatusCode)
+	}
+	// A range of another version of the file gets the whole file
+	if resp, body = getText(t, url, map[string]string{"Range": "bytes=7-15", "If-Range": `"other"`}); resp.StatusCode != 200 || body != content {
+		t.Errorf("If-Range of another version: status %d", resp.StatusCode)
+	}
+
+	// A replaced file gets a new ETag
+	later := time.Now().Add(time.Hour)
+	if err := os.WriteFile(filepath.Join(booksDir, "book_1.txt"), []byte(content+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(booksDir, "book_1.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ = getText(t, url, map[string]string{"If-None-Match": etag}); resp.StatusCode != 200 || resp.Header.Get("ETag") == etag {
+		t.Errorf("replaced file: status %d, ETag %s", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	if resp, _ = getText(t, srv.URL+"/api/book/2/text", nil); resp.StatusCode != 404 {
+		t.Errorf("unknown book: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// TOCEntry is a chapter as listed in the table of contents of a book.
+// Offsets are in characters of the book file, like the matches of a search inside the book.
+type TOCEntry struct {
+	Number int    `json:"number"` // from 1, as in /api/book/:id/chapters/:n
+	Title  string `json:"title"`
+	Level  int    `json:"level"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// indexedChapters returns the chapters of a book found at index time if they fit
+// its file of size bytes, else nil: the file was changed or replaced since, or the
+// index was built before chapters, or their characters, were recorded
+func indexedChapters(book models.Book, size int64) []models.Chapter {
+	chapters := idx.Chapters[book.ID]
+	if len(chapters) == 0 || int64(chapters[len(chapters)-1].End) != size {
+		return nil
+	}
+	end := 0
+	for _, ch := range chapters {
+		if ch.Start < end || ch.End < ch.Start || ch.Length == 0 {
+			return nil
+		}
+		end = ch.End
+	}
+	return chapters
+}
+
+// bookChapters returns the chapters of a book read whole. They are detected from
+// the file when the index has none that fit it.
+func bookChapters(book models.Book, content string) []models.Chapter {
+	if chapters := indexedChapters(book, int64(len(content))); chapters != nil {
+		return chapters
+	}
+	return indexer.DetectChapters(content, indexer.SplitGutenberg(content))
+}
+
+// openBookChapters opens the file of a book and returns its chapters, answering the
+// request itself on error. content is only read, whole, when the chapters of the
+// index don't fit the file; the caller closes the file.
+func openBookChapters(c *gin.Context) (book models.Book, file *os.File, content string, chapters []models.Chapter, ok bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	book, exists := idx.Books[id]
+	if !exists {
+		c.JSON(404, gin.H{"error": "Book not found"})
+		return book, nil, "", nil, false
+	}
+
+	file, info, err := bookFiles.Open(book)
+	if err != nil {
+		bookFileError(c, book, err)
+		return book, nil, "", nil, false
+	}
+
+	if chapters = indexedChapters(book, info.Size()); chapters != nil {
+		return book, file, "", chapters, true
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		bookFileError(c, book, err)
+		return book, nil, "", nil, false
+	}
+	content = string(data)
+	return book, file, content, indexer.DetectChapters(content, indexer.SplitGutenberg(content)), true
+}
+
+// tocHandler returns the table of contents of a book
+func tocHandler(c *gin.Context) {
+	book, file, _, chapters, ok := openBookChapters(c)
+	if !ok {
+		return
+	}
+	file.Close()
+
+	toc := make([]TOCEntry, len(chapters))
+	for i, ch := range chapters {
+		toc[i] = TOCEntry{Number: i + 1, Title: ch.Title, Level: ch.Level, Offset: ch.Offset, Length: ch.Length}
+	}
+
+	c.JSON(200, gin.H{
+		"book_id":  book.ID,
+		"title":    book.Title,
+		"chapters": toc,
+	})
+}
+
+// chapterHandler returns the text of one chapter of a book, so the reader can page
+// through it. Only the chapter is read from the file.
+func chapterHandler(c *gin.Context) {
+	book, file, content, chapters, ok := openBookChapters(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	n, err := strconv.Atoi(c.Param("n"))
+	if err != nil || n < 1 || n > len(chapters) {
+		c.JSON(404, gin.H{"error": "Chapter not found"})
+		return
+	}
+	ch := chapters[n-1]
+
+	var text string
+	if content != "" {
+		text = content[ch.Start:ch.End]
+	} else {
+		section := make([]byte, ch.End-ch.Start)
+		if _, err := file.ReadAt(section, int64(ch.Start)); err != nil {
+			bookFileError(c, book, err)
+			return
+		}
+		text = string(section)
+	}
+
+	c.JSON(200, gin.H{
+		"book_id":        book.ID,
+		"title":          book.Title,
+		"author":         book.Author,
+		"number":         n,
+		"total_chapters": len(chapters),
+		"chapter_title":  ch.Title,
+		"level":          ch.Level,
+		"offset":         ch.Offset,
+		"content":        text,
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// chapterBook is a book of three chapters with accented letters, so that
+// characters and bytes differ
+const chapterBook = "Title: Les Chapitres\n\nLanguage: French\n\n" +
+	"*** START OF THE PROJECT GUTENBERG EBOOK LES CHAPITRES ***\n\n" +
+	"CHAPITRE I. L'été\n\nÉté après été, la mer était calme.\n\n" +
+	"CHAPITRE II. L'hiver\n\nL'hiver, la baleine s'éloignait.\n\n" +
+	"CHAPITRE III. Le départ\n\nLe navire quitta le port à l'aube.\n\n" +
+	"*** END OF THE PROJECT GUTENBERG EBOOK LES CHAPITRES ***\n"
+
+// getJSON decodes the response of a GET request into v and returns its status
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+type tocResponse struct {
+	Chapters []TOCEntry `json:"chapters"`
+}
+
+type chapterResponse struct {
+	ChapterTitle string `json:"chapter_title"`
+	Offset       int    `json:"offset"`
+	Content      string `json:"content"`
+}
+
+func TestChapters(t *testing.T) {
+	booksDir := t.TempDir()
+	path := filepath.Join(booksDir, "book_1.txt")
+	if err := os.WriteFile(path, []byte(chapterBook), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := serveData(t, buildData(t, booksDir, nil), booksDir)
+
+	var toc tocResponse
+	if status := getJSON(t, srv.URL+"/api/book/1/toc", &toc); status != 200 {
+		t.Fatalf("table of contents: status %d", status)
+	}
+	if len(toc.Chapters) != 3 {
+		t.Fatalf("table of contents = %+v, want 3 chapters", toc.Chapters)
+	}
+
+	// Each chapter is at its offset in characters, and the chapters cover the book
+	book := []rune(chapterBook)
+	var whole strings.Builder
+	for i, entry := range toc.Chapters {
+		var ch chapterResponse
+		if status := getJSON(t, srv.URL+"/api/book/1/chapters/"+strconv.Itoa(entry.Number), &ch); status != 200 {
+			t.Fatalf("chapter %d: status %d", i+1, status)
+		}
+		if ch.Offset != entry.Offset {
+			t.Errorf("chapter %d: offset %d, the table of contents says %d", i+1, ch.Offset, entry.Offset)
+		}
+		if got := string(book[entry.Offset : entry.Offset+entry.Length]); got != ch.Content {
+			t.Errorf("chapter %d: characters %d+%d are %q, the chapter is %q", i+1, entry.Offset, entry.Length, got, ch.Content)
+		}
+		whole.WriteString(ch.Content)
+	}
+	if whole.String() != chapterBook {
+		t.Errorf("the chapters don't add up to the book:\n%s", whole.String())
+	}
+
+	// The file replaced by a shorter one: its chapters are detected again
+	shorter := strings.Replace(chapterBook, "Été après été, la mer était calme.", "Calme.", 1)
+	if err := os.WriteFile(path, []byte(shorter), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var ch chapterResponse
+	if status := getJSON(t, srv.URL+"/api/book/1/chapters/3", &ch); status != 200 {
+		t.Fatalf("chapter 3 of the changed file: status %d", status)
+	}
+	if !strings.HasPrefix(ch.Content, "CHAPITRE III.") || !strings.HasSuffix(shorter, ch.Content) {
+		t.Errorf("chapter 3 of the changed file = %q", ch.Content)
+	}
+
+	// Truncated below the offsets of the index
+	if err := os.WriteFile(path, []byte(chapterBook[:40]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if status := getJSON(t, srv.URL+"/api/book/1/toc", &toc); status != 200 {
+		t.Fatalf("table of contents of the truncated file: status %d", status)
+	}
+	if status := getJSON(t, srv.URL+"/api/book/1/chapters/1", &ch); status != 200 || ch.Content != chapterBook[:40] {
+		t.Errorf("chapter 1 of the truncated file: status %d, content %q", status, ch.Content)
+	}
+}
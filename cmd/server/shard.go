@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+	"github.com/taqiyeddinedj/daar-project3/pkg/ranking"
+	"github.com/taqiyeddinedj/daar-project3/pkg/search"
+	"github.com/taqiyeddinedj/daar-project3/pkg/shard"
+)
+
+var coordinator *shard.Coordinator
+
+// shardStatsHandler returns this shard's document frequencies for a query (first round)
+func shardStatsHandler(c *gin.Context) {
+	ctx, cancel := searchLimits.Context(c.Request.Context())
+	defer cancel()
+
+	// Past the limits, the stats are those of the terms found; the second round reports the limits
+	terms, err := search.MatchTerms(ctx, idx, c.Query("q"), c.Query("type"))
+	if err != nil && !search.IsLimit(err) {
+		if !searchCanceled(c, err) {
+			c.JSON(400, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(200, search.LocalStats(idx, terms))
+}
+
+// shardSearchHandler scores this shard's books with the global stats (second round)
+func shardSearchHandler(c *gin.Context) {
+	var req shard.SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := searchLimits.Context(c.Request.Context())
+	defer cancel()
+	var partial search.Partial
+
+	q, err := search.MatchQuery(ctx, idx, req.Query, req.Type)
+	if err = partial.Check(err); err != nil {
+		if !searchCanceled(c, err) {
+			c.JSON(400, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	results, err := search.ScoreQuery(ctx, idx, q, req.Stats)
+	if err = partial.Check(err); err != nil {
+		searchCanceled(c, err)
+		return
+	}
+	facets := search.ComputeFacets(idx, results, req.Filters)
+	results = req.Filters.Apply(results)
+
+	// PageRank sums to 1 over this shard's graph only: scale it by the
+	// shard's share of the library so that shards rank on the same scale
+	shardPageRank := pageRank
+	if req.Stats.TotalBooks > 0 {
+		scale := float64(len(idx.Books)) / float64(req.Stats.TotalBooks)
+		shardPageRank = make(map[int]float64, len(results))
+		for _, r := range results {
+			shardPageRank[r.Book.ID] = pageRank[r.Book.ID] * scale
+		}
+	}
+	ranking.ApplyPageRank(results, shardPageRank)
+
+	total := len(results)
+	if req.After != nil {
+		results = search.TopResultsAfter(results, *req.After, req.Limit)
+	} else {
+		results = search.TopResults(results, req.Limit)
+	}
+	if req.Explain {
+		explainResults(results, q, req.Stats, shardPageRank)
+	}
+
+	resp := shard.SearchResponse{
+		Results:    results,
+		TotalCount: total,
+		Terms:      search.TermForms(idx, q.Terms),
+		Variants:   q.Variants,
+		Facets:     facets,
+		Partial:    partial,
+	}
+	if spellChecked(req.Type) {
+		resp.Suggestions = speller.Suggest(req.Query)
+	}
+	c.JSON(200, resp)
+}
+
+// shardSnippetsHandler returns the snippets of some of this shard's books for a query
+func shardSnippetsHandler(c *gin.Context) {
+	var req shard.SnippetsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := searchLimits.Context(c.Request.Context())
+	defer cancel()
+
+	q, err := search.MatchQuery(ctx, idx, req.Query, req.Type)
+	if err != nil && !search.IsLimit(err) {
+		if !searchCanceled(c, err) {
+			c.JSON(400, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	var results []models.SearchResult
+	for _, id := range req.BookIDs {
+		if book, found := idx.Books[id]; found {
+			results = append(results, models.SearchResult{Book: book})
+		}
+	}
+	addSnippets(c.Request.Context(), results, q)
+
+	resp := shard.SnippetsResponse{Snippets: make(map[int][]models.Snippet, len(results))}
+	for _, r := range results {
+		resp.Snippets[r.Book.ID] = r.Snippets
+	}
+	c.JSON(200, resp)
+}
+
+// setupCoordinator registers the routes of a coordinator: searches are sent to
+// every shard, book pages are proxied to the shard holding the book.
+// It fails if a shard URL is not an absolute http(s) URL.
+func setupCoordinator(r *gin.Engine, urls []string) error {
+	targets := make([]*url.URL, len(urls))
+	for i, u := range urls {
+		target, err := parseShardURL(u)
+		if err != nil {
+			return err
+		}
+		targets[i] = target
+		urls[i] = target.String()
+	}
+	coordinator = shard.NewCoordinator(urls)
+
+	proxies := make([]*httputil.ReverseProxy, len(targets))
+	for i, target := range targets {
+		proxies[i] = httputil.NewSingleHostReverseProxy(target)
+		// The coordinator sets its own CORS headers; the shard's would be sent twice
+		proxies[i].ModifyResponse = func(resp *http.Response) error {
+			for name := range resp.Header {
+				if strings.HasPrefix(name, "Access-Control-") {
+					resp.Header.Del(name)
+				}
+			}
+			return nil
+		}
+	}
+
+	proxyToShard := func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(404, gin.H{"error": "Book not found"})
+			return
+		}
+		proxies[shard.Assign(id, len(proxies))].ServeHTTP(c.Writer, c.Request)
+	}
+
+	r.GET("/api/search", coordinatorSearchHandler)
+	r.GET("/api/suggest", coordinatorSuggestHandler)
+	r.GET("/api/book/:id", proxyToShard)
+	r.GET("/api/book/:id/search", proxyToShard)
+	r.GET("/api/book/:id/toc", proxyToShard)
+	r.GET("/api/book/:id/chapters/:n", proxyToShard)
+	r.GET("/api/recommendations/:id", proxyToShard)
+	r.GET("/api/content/:id", proxyToShard)
+	r.GET("/api/book/:id/text", proxyToShard)
+	r.HEAD("/api/book/:id/text", proxyToShard)
+
+	fmt.Printf("✓ Coordinator for %d shards\n", len(urls))
+	return nil
+}
+
+// parseShardURL parses the base URL of a shard, such as http://host:8081
+func parseShardURL(raw string) (*url.URL, error) {
+	target, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid shard URL %q: %v", raw, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("invalid shard URL %q: the scheme must be http or https", raw)
+	}
+	if target.Host == "" {
+		return nil, fmt.Errorf("invalid shard URL %q: missing host", raw)
+	}
+	return target, nil
+}
+
+// coordinatorSuggestHandler completes the search box with the words, titles and authors of every shard
+func coordinatorSuggestHandler(c *gin.Context) {
+	completions, err := coordinator.Complete(c.Request.Context(), c.Query("prefix"), suggestLimit(c))
+	if err != nil {
+		c.JSON(502, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, completions)
+}
+
+func coordinatorSearchHandler(c *gin.Context) {
+	query, searchType, p, err := searchParams(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Reject bad patterns here rather than getting the error back from every shard
+	if searchType == "regex" {
+		if _, err := regexp.Compile(query); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if searchType == "glob" {
+		if err := search.ValidateGlob(query); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Every shard must return enough results to fill the pages up to this one,
+	// or this page when it follows a cursor. The shards stop searching when the client goes away.
+	explain, _ := strconv.ParseBool(c.Query("explain"))
+	merged, err := coordinator.Search(c.Request.Context(), shard.SearchRequest{
+		Query:   query,
+		Type:    searchType,
+		Filters: searchFilters(c),
+		Limit:   p.limit(),
+		After:   p.cursor,
+		Explain: explain,
+	})
+	if err != nil {
+		if !searchCanceled(c, err) {
+			c.JSON(502, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	response := newSearchResponse(p.pick(merged.Results), merged.TotalCount, p)
+	response.Terms = merged.Terms
+	response.Variants = merged.Variants
+	response.Suggestions = merged.Suggestions
+	response.DidYouMean = search.CorrectQuery(query, merged.Suggestions)
+	response.Partial = merged.Partial
+
+	// Snippets are a nicety: the results are still worth showing without them
+	if err := coordinator.AddSnippets(c.Request.Context(), query, searchType, response.Results); err != nil {
+		log.Printf("Snippets failed: %v", err)
+	}
+	response.Facets = merged.Facets.Top(search.FacetLimit)
+	c.JSON(200, response)
+}
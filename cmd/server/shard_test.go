@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taqiyeddinedj/daar-project3/pkg/cache"
+	"github.com/taqiyeddinedj/daar-project3/pkg/graph"
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/library"
+	"github.com/taqiyeddinedj/daar-project3/pkg/search"
+	"github.com/taqiyeddinedj/daar-project3/pkg/shard"
+)
+
+// testDataMu serializes the requests of the test servers: the handlers serve the
+// package level data, which each server sets to its own before a request
+var testDataMu sync.Mutex
+
+// writeLibrary writes the books of the tests and returns their directory.
+// Books 7 and 12 have the same text, so they tie on relevance.
+func writeLibrary(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	words := []string{"whale", "ocean", "ship", "sailor", "harbour", "island", "storm", "captain"}
+	for id := 1; id <= 12; id++ {
+		text := id
+		if id == 12 {
+			text = 7
+		}
+		var body []string
+		for j, word := range words {
+			for n := 0; n < (text*(j+3))%5; n++ {
+				body = append(body, word)
+			}
+		}
+		for n := 0; n < text%4; n++ {
+			body = append(body, "the sea was calm and grey")
+		}
+
+		content := fmt.Sprintf("Title: Book %d\n\nAuthor: Test Author\n\nLanguage: English\n\n"+
+			"*** START OF THE PROJECT GUTENBERG EBOOK BOOK %d ***\n\n%s.\n\n"+
+			"*** END OF THE PROJECT GUTENBERG EBOOK BOOK %d ***\n", id, id, strings.Join(body, " "), id)
+		path := filepath.Join(dir, fmt.Sprintf("book_%d.txt", id))
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// buildData indexes the books of a directory that include accepts (all if nil).
+// PageRank is left empty so relevance is the BM25 score alone.
+func buildData(t *testing.T, booksDir string, include func(bookID int) bool) *serverData {
+	t.Helper()
+	idx := indexer.NewIndexer()
+	if err := idx.BuildIndexFromDirectory(booksDir, indexer.BuildOptions{Workers: 1, Include: include}); err != nil {
+		t.Fatal(err)
+	}
+	return &serverData{
+		idx:          idx,
+		jaccardGraph: &graph.JaccardGraph{},
+		pageRank:     map[int]float64{},
+		speller:      search.NewSpeller(idx),
+		completer:    search.NewCompleter(idx),
+	}
+}
+
+// serveData starts a server on its own data, like main does with -shard
+func serveData(t *testing.T, d *serverData, booksDir string) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	bookFiles = library.New(booksDir)
+	if resultCache == nil {
+		resultCache = cache.New[*rankedSearch](0)
+	}
+
+	r := gin.New()
+	setupServer(r, true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		testDataMu.Lock()
+		defer testDataMu.Unlock()
+		d.use()
+		r.ServeHTTP(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// getSearch runs a search on a test server
+func getSearch(t *testing.T, baseURL string, params url.Values) SearchResponse {
+	t.Helper()
+	resp, err := http.Get(baseURL + "/api/search?" + params.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("search %v: status %d", params, resp.StatusCode)
+	}
+
+	var response SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// startShards starts n shards over the books and a coordinator in front of them,
+// and returns the URL of the coordinator and of a server holding all the books
+func startShards(t *testing.T, n int) (coordinatorURL, fullURL string) {
+	t.Helper()
+	booksDir := writeLibrary(t)
+
+	var urls []string
+	for i := 0; i < n; i++ {
+		d := buildData(t, booksDir, func(bookID int) bool { return shard.Assign(bookID, n) == i })
+		urls = append(urls, serveData(t, d, booksDir).URL)
+	}
+
+	r := gin.New()
+	if err := setupCoordinator(r, urls); err != nil {
+		t.Fatal(err)
+	}
+	co := httptest.NewServer(r)
+	t.Cleanup(co.Close)
+
+	return co.URL, serveData(t, buildData(t, booksDir, nil), booksDir).URL
+}
+
+// shardedQueries are the searches compared between the coordinator and one index
+var shardedQueries = []url.Values{
+	{"q": {"whale"}},
+	{"q": {"ocean storm"}},
+	{"q": {"sailor harbour captain"}},
+	{"q": {"s.*"}, "type": {"regex"}},
+	{"q": {"sail*"}, "type": {"glob"}},
+}
+
+func TestCoordinatorMatchesSingleIndex(t *testing.T) {
+	coordinatorURL, fullURL := startShards(t, 3)
+
+	// The twin books are on different shards and must come in ID order
+	twins := getSearch(t, coordinatorURL, url.Values{"q": {"whale"}, "per_page": {"100"}})
+	var order []int
+	for _, r := range twins.Results {
+		order = append(order, r.Book.ID)
+	}
+	if !strings.Contains(fmt.Sprint(order), "7 12") {
+		t.Errorf("book 12 doesn't follow its twin book 7: %v", order)
+	}
+
+	for _, query := range shardedQueries {
+		params := url.Values{"q": query["q"], "type": query["type"], "per_page": {"100"}}
+		got := getSearch(t, coordinatorURL, params)
+		want := getSearch(t, fullURL, params)
+
+		if got.TotalCount != want.TotalCount {
+			t.Errorf("%v: total_count %d, want %d", params, got.TotalCount, want.TotalCount)
+		}
+		if len(got.Results) != len(want.Results) {
+			t.Errorf("%v: %d results, want %d", params, len(got.Results), len(want.Results))
+			continue
+		}
+		for i := range got.Results {
+			g, w := got.Results[i], want.Results[i]
+			if g.Book.ID != w.Book.ID || math.Abs(g.Relevance-w.Relevance) > 1e-9 {
+				t.Errorf("%v result %d: got book %d (%v), want book %d (%v)",
+					params, i, g.Book.ID, g.Relevance, w.Book.ID, w.Relevance)
+			}
+		}
+	}
+}
+
+func TestCoordinatorPaging(t *testing.T) {
+	coordinatorURL, fullURL := startShards(t, 3)
+	const perPage = 5
+
+	for _, params := range shardedQueries {
+		all := getSearch(t, fullURL, url.Values{"q": params["q"], "type": params["type"], "per_page": {"100"}})
+		var want []int
+		for _, r := range all.Results {
+			want = append(want, r.Book.ID)
+		}
+		if len(want) <= perPage {
+			t.Fatalf("%v: %d results, the test needs several pages", params, len(want))
+		}
+
+		for _, baseURL := range []string{coordinatorURL, fullURL} {
+			// By page number
+			var byPage []int
+			for page := 1; page <= (all.TotalCount+perPage-1)/perPage; page++ {
+				resp := getSearch(t, baseURL, url.Values{"q": params["q"], "type": params["type"],
+					"per_page": {fmt.Sprint(perPage)}, "page": {fmt.Sprint(page)}})
+				for _, r := range resp.Results {
+					byPage = append(byPage, r.Book.ID)
+				}
+			}
+			if fmt.Sprint(byPage) != fmt.Sprint(want) {
+				t.Errorf("%s %v by page: got %v, want %v", baseURL, params, byPage, want)
+			}
+
+			// Following next_cursor
+			var byCursor []int
+			cursor := ""
+			for pages := 0; pages <= len(want); pages++ {
+				query := url.Values{"q": params["q"], "type": params["type"], "per_page": {fmt.Sprint(perPage)}}
+				if cursor != "" {
+					query.Set("cursor", cursor)
+				}
+				resp := getSearch(t, baseURL, query)
+				for _, r := range resp.Results {
+					byCursor = append(byCursor, r.Book.ID)
+				}
+				if cursor = resp.NextCursor; cursor == "" {
+					break
+				}
+			}
+			if fmt.Sprint(byCursor) != fmt.Sprint(want) {
+				t.Errorf("%s %v by cursor: got %v, want %v", baseURL, params, byCursor, want)
+			}
+		}
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	coordinatorURL, fullURL := startShards(t, 2)
+
+	for _, baseURL := range []string{coordinatorURL, fullURL} {
+		for _, cursor := range []string{"garbage!", "TmFOOjEzNDI"} {
+			resp, err := http.Get(baseURL + "/api/search?" + url.Values{"q": {"whale"}, "cursor": {cursor}}.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != 400 {
+				t.Errorf("%s with cursor %q: status %d, want 400", baseURL, cursor, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestPageOutOfRange(t *testing.T) {
+	coordinatorURL, fullURL := startShards(t, 2)
+
+	for _, baseURL := range []string{coordinatorURL, fullURL} {
+		for _, page := range []string{"9223372036854775807", "10000000", "4"} {
+			resp := getSearch(t, baseURL, url.Values{"q": {"whale"}, "page": {page}, "per_page": {"100"}})
+			if len(resp.Results) != 0 || resp.NextCursor != "" {
+				t.Errorf("%s page %s: %d results, next cursor %q, want none", baseURL, page, len(resp.Results), resp.NextCursor)
+			}
+			if resp.TotalCount == 0 {
+				t.Errorf("%s page %s: total_count 0, want the matches", baseURL, page)
+			}
+		}
+	}
+}
+
+// checkExplanations checks that the parts of the explanation of each result add up to its relevance
+func checkExplanations(t *testing.T, name string, response SearchResponse) {
+	t.Helper()
+	if len(response.Results) == 0 {
+		t.Fatalf("%s: no results", name)
+	}
+	for _, r := range response.Results {
+		e := r.Explanation
+		if e == nil {
+			t.Errorf("%s, book %d: no explanation", name, r.Book.ID)
+			continue
+		}
+		sum := 0.0
+		for _, term := range e.Terms {
+			sum += term.Score
+		}
+		if math.Abs(sum-e.BM25) > 1e-9 {
+			t.Errorf("%s, book %d: terms sum to %v, BM25 is %v", name, r.Book.ID, sum, e.BM25)
+		}
+		if math.Abs(e.BM25+e.PageRankContribution-e.Relevance) > 1e-9 || math.Abs(e.BM25*e.PageRankFactor-e.Relevance) > 1e-9 {
+			t.Errorf("%s, book %d: BM25 %v + PageRank %v (× %v) is not %v", name, r.Book.ID, e.BM25, e.PageRankContribution, e.PageRankFactor, e.Relevance)
+		}
+		if math.Abs(e.Relevance-r.Relevance) > 1e-9 {
+			t.Errorf("%s, book %d: explained %v, ranked %v", name, r.Book.ID, e.Relevance, r.Relevance)
+		}
+	}
+}
+
+func TestExplainSumsToRelevance(t *testing.T) {
+	booksDir := writeLibrary(t)
+	d := buildData(t, booksDir, nil)
+	for id := range d.idx.Books {
+		d.pageRank[id] = float64(id) / 100
+	}
+	withPageRank := serveData(t, d, booksDir).URL
+	coordinatorURL, _ := startShards(t, 3)
+
+	for _, baseURL := range []string{withPageRank, coordinatorURL} {
+		for _, query := range shardedQueries {
+			params := url.Values{"q": query["q"], "type": query["type"], "per_page": {"100"}, "explain": {"true"}}
+			response := getSearch(t, baseURL, params)
+			checkExplanations(t, fmt.Sprint(params), response)
+			if baseURL == withPageRank && response.Results[0].Explanation.PageRankContribution == 0 {
+				t.Errorf("%v: no PageRank in the explanation", params)
+			}
+		}
+	}
+}
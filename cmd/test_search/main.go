@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
 	"github.com/taqiyeddinedj/daar-project3/pkg/search"
 	"github.com/taqiyeddinedj/daar-project3/pkg/storage"
 )
@@ -23,13 +25,33 @@ func main() {
 	fmt.Printf(" Index loaded: %d books, %d unique words\n\n",
 		len(idx.Books), idx.UniqueWords)
 
-	// Test searches
+	// Test searches: wha* is a glob (whale, what...), the others are regular expressions
 	//simpleQueries := []string{"whale", "captain", "treasure", "love", "love"}
-	complexQueries := []string{"wha*", "cap|tain", "trea.*", "love", "love^"}
-	for _, query := range complexQueries {
-		fmt.Printf("Searching for '%s'...\n", query)
-		results, _ := search.RegexSearch(idx, query)
-		//results, _ := search.RegexSearch(idx, query)
+	complexQueries := []struct {
+		query, searchType string
+	}{
+		{"wha*", "glob"},
+		{"*ness", "glob"},
+		{"cap|tain", "regex"},
+		{"trea.*", "regex"},
+		{"love", "regex"},
+		{"love^", "regex"},
+	}
+	for _, q := range complexQueries {
+		query := q.query
+		fmt.Printf("Searching for '%s' (%s)...\n", query, q.searchType)
+
+		var results []models.SearchResult
+		var err error
+		if q.searchType == "glob" {
+			results, err = search.GlobSearch(context.Background(), idx, query)
+		} else {
+			results, err = search.RegexSearch(context.Background(), idx, query)
+		}
+		if err != nil {
+			fmt.Printf("  Error: %v\n\n", err)
+			continue
+		}
 
 		if len(results) == 0 {
 			fmt.Printf("  No books found\n\n")
@@ -0,0 +1,123 @@
+// Package cache keeps recently computed values, such as ranked search results
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU caches values under string keys, evicting the least recently used ones
+// once their total size goes over a number of bytes. It is safe for concurrent use.
+type LRU[V any] struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	entries  map[string]*list.Element
+	order    *list.List // most recently used first
+
+	hits, misses, evictions, clears int64
+}
+
+type entry[V any] struct {
+	key   string
+	value V
+	size  int64
+}
+
+// Stats are the numbers of a cache since it was last cleared
+type Stats struct {
+	Entries   int     `json:"entries"`
+	Bytes     int64   `json:"bytes"`
+	MaxBytes  int64   `json:"max_bytes"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	HitRate   float64 `json:"hit_rate"`
+	Evictions int64   `json:"evictions"`
+	// Clears counts the times the whole cache was invalidated since it was created
+	Clears int64 `json:"clears"`
+}
+
+// New creates a cache holding values up to maxBytes in all, or nothing if maxBytes <= 0
+func New[V any](maxBytes int64) *LRU[V] {
+	return &LRU[V]{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the value cached under key, which becomes the most recently used
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, found := c.entries[key]; found {
+		c.hits++
+		c.order.MoveToFront(e)
+		return e.Value.(*entry[V]).value, true
+	}
+	c.misses++
+	var zero V
+	return zero, false
+}
+
+// Add caches a value of the given size (an estimate of the memory it holds),
+// evicting the least recently used values to make room. A value larger than
+// the whole cache is not kept.
+func (c *LRU[V]) Add(key string, value V, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if size > c.maxBytes {
+		return
+	}
+	if e, found := c.entries[key]; found {
+		c.remove(e)
+	}
+	c.entries[key] = c.order.PushFront(&entry[V]{key: key, value: value, size: size})
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// Clear empties the cache, when the values it holds are no longer valid.
+// Its hits, misses and evictions start again from zero: they were those of the old values.
+func (c *LRU[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+	c.hits, c.misses, c.evictions = 0, 0, 0
+	c.clears++
+}
+
+// Stats returns the numbers of the cache
+func (c *LRU[V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := Stats{
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Clears:    c.clears,
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
+
+func (c *LRU[V]) remove(e *list.Element) {
+	ent := c.order.Remove(e).(*entry[V])
+	delete(c.entries, ent.key)
+	c.bytes -= ent.size
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// keys returns the keys of the cache, most recently used first
+func keys[V any](c *LRU[V]) []string {
+	var keys []string
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*entry[V]).key)
+	}
+	return keys
+}
+
+func TestEvictionOrder(t *testing.T) {
+	c := New[int](10)
+	c.Add("a", 1, 4)
+	c.Add("b", 2, 4)
+	c.Add("c", 3, 2)
+
+	// 4 more bytes: a, the least recently used, makes room
+	c.Add("d", 4, 4)
+	if got := fmt.Sprint(keys(c)); got != "[d c b]" {
+		t.Errorf("keys = %s, want [d c b]", got)
+	}
+
+	// 8 more bytes: b then c are evicted, d stays
+	c.Add("e", 5, 6)
+	if got := fmt.Sprint(keys(c)); got != "[e d]" {
+		t.Errorf("keys = %s, want [e d]", got)
+	}
+
+	stats := c.Stats()
+	if stats.Entries != 2 || stats.Bytes != 10 || stats.Evictions != 3 {
+		t.Errorf("stats = %+v, want 2 entries, 10 bytes, 3 evictions", stats)
+	}
+}
+
+func TestGetRefreshesRecency(t *testing.T) {
+	c := New[int](9)
+	c.Add("a", 1, 3)
+	c.Add("b", 2, 3)
+	c.Add("c", 3, 3)
+
+	if v, found := c.Get("a"); !found || v != 1 {
+		t.Fatalf("Get(a) = %v, %v", v, found)
+	}
+	// b is now the least recently used
+	c.Add("d", 4, 3)
+	if _, found := c.Get("b"); found {
+		t.Error("b should have been evicted")
+	}
+	if _, found := c.Get("a"); !found {
+		t.Error("a was used last and should have been kept")
+	}
+
+	// Adding a key again replaces its value and size, and refreshes it
+	c.Add("c", 30, 1)
+	if v, _ := c.Get("c"); v != 30 {
+		t.Errorf("Get(c) = %v, want 30", v)
+	}
+	if got := fmt.Sprint(keys(c)); got != "[c a d]" {
+		t.Errorf("keys = %s, want [c a d]", got)
+	}
+	if stats := c.Stats(); stats.Bytes != 7 {
+		t.Errorf("bytes = %d, want 7", stats.Bytes)
+	}
+}
+
+func TestValueLargerThanCache(t *testing.T) {
+	c := New[int](10)
+	c.Add("a", 1, 5)
+	c.Add("huge", 2, 11)
+
+	if _, found := c.Get("huge"); found {
+		t.Error("a value larger than the cache was kept")
+	}
+	if _, found := c.Get("a"); !found {
+		t.Error("a value larger than the cache evicted the others")
+	}
+
+	// A value of exactly the size of the cache fits, alone
+	c.Add("full", 3, 10)
+	if got := fmt.Sprint(keys(c)); got != "[full]" {
+		t.Errorf("keys = %s, want [full]", got)
+	}
+
+	// Without room, nothing is cached
+	empty := New[int](0)
+	empty.Add("a", 1, 1)
+	if _, found := empty.Get("a"); found {
+		t.Error("a cache of 0 bytes kept a value")
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := New[int](4)
+	c.Add("a", 1, 2)
+	c.Add("b", 2, 2)
+	c.Add("c", 3, 2)
+	c.Get("c")
+	c.Get("x")
+
+	before := c.Stats()
+	if before.Hits != 1 || before.Misses != 1 || before.HitRate != 0.5 || before.Evictions != 1 {
+		t.Fatalf("stats before Clear = %+v", before)
+	}
+
+	c.Clear()
+	want := Stats{MaxBytes: 4, Clears: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("stats after Clear = %+v, want %+v", got, want)
+	}
+	if _, found := c.Get("c"); found {
+		t.Error("value still cached after Clear")
+	}
+
+	// The cache works as before
+	c.Add("d", 4, 4)
+	if v, found := c.Get("d"); !found || v != 4 {
+		t.Errorf("Get(d) after Clear = %v, %v", v, found)
+	}
+	c.Clear()
+	if got := c.Stats(); got.Clears != 2 || got.Entries != 0 || got.Hits != 0 {
+		t.Errorf("stats after a second Clear = %+v", got)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	c := New[int](100)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprint(i % 50)
+				if _, found := c.Get(key); !found {
+					c.Add(key, i, int64(1+i%5))
+				}
+				if i%300 == g {
+					c.Clear()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if stats := c.Stats(); stats.Bytes > stats.MaxBytes {
+		t.Errorf("cache over its size: %+v", stats)
+	}
+}
@@ -0,0 +1,294 @@
+package catalog
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// testdataDir is laid out like the official archive: cache/epub/<id>/pg<id>.rdf
+const testdataDir = "testdata"
+
+func rdfPath(id string) string {
+	return filepath.Join(testdataDir, "cache", "epub", id, "pg"+id+".rdf")
+}
+
+func TestParseRDF(t *testing.T) {
+	tests := []struct {
+		file string
+		want Entry
+	}{
+		{
+			// The author is described inside the creator element
+			file: "1342",
+			want: Entry{
+				ID:          1342,
+				Title:       "Pride and Prejudice",
+				Authors:     []models.Person{{Name: "Austen, Jane", BirthYear: 1775, DeathYear: 1817}},
+				Subjects:    []string{"Courtship -- Fiction", "England -- Fiction", "Sisters -- Fiction"},
+				LCC:         []string{"PR"},
+				Bookshelves: []string{"Best Books Ever Listings", "Harvard Classics"},
+				Language:    "en",
+				Issued:      "1998-06-01",
+			},
+		},
+		{
+			// The translator is not an author
+			file: "2554",
+			want: Entry{
+				ID:          2554,
+				Title:       "Crime and Punishment",
+				Authors:     []models.Person{{Name: "Dostoyevsky, Fyodor", BirthYear: 1821, DeathYear: 1881}},
+				Subjects:    []string{"Murder -- Fiction", "Saint Petersburg (Russia) -- Fiction"},
+				LCC:         []string{"PG"},
+				Bookshelves: []string{"Crime Fiction"},
+				Language:    "en",
+				Issued:      "2001-03-01",
+			},
+		},
+		{
+			// The creator refers to an agent described after the ebook
+			file: "2701",
+			want: Entry{
+				ID:          2701,
+				Title:       "Moby Dick; Or, The Whale",
+				Authors:     []models.Person{{Name: "Melville, Herman", BirthYear: 1819, DeathYear: 1891}},
+				Subjects:    []string{"Whaling -- Fiction", "Sea stories"},
+				LCC:         []string{"PS"},
+				Bookshelves: []string{"Adventure"},
+				Language:    "en",
+				Issued:      "2001-07-01",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			file, err := os.Open(rdfPath(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			got, err := ParseRDF(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRDFInvalid(t *testing.T) {
+	for _, content := range []string{
+		"not xml",
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><ebook rdf:about="ebooks/abc"/></rdf:RDF>`,
+	} {
+		if _, err := ParseRDF(strings.NewReader(content)); err == nil {
+			t.Errorf("ParseRDF(%q) succeeded, want an error", content)
+		}
+	}
+}
+
+// testFiles are the files of the catalogs loaded by the tests: the testdata,
+// a file that doesn't parse, and a file not named pg<id>.rdf, which has to be
+// parsed to know its book
+func testFiles(t *testing.T) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{"cache/epub/9999/pg9999.rdf": []byte("<rdf:RDF>truncated")}
+	for _, id := range []string{"1342", "2554", "2701"} {
+		content, err := os.ReadFile(rdfPath(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files["cache/epub/"+id+"/pg"+id+".rdf"] = content
+		if id == "2701" {
+			files["extra/moby.rdf"] = content
+		}
+	}
+	return files
+}
+
+func writeDir(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func writeTarball(t *testing.T, name string, files map[string][]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var w io.Writer = file
+	if strings.HasSuffix(name, ".gz") {
+		gz := gzip.NewWriter(file)
+		defer gz.Close()
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	if err := tw.WriteHeader(&tar.Header{Name: "cache/epub/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		header := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	files := testFiles(t)
+	sources := map[string]string{
+		"dir":    writeDir(t, files),
+		"tar":    writeTarball(t, "rdf-files.tar", files),
+		"tar.gz": writeTarball(t, "rdf-files.tar.gz", files),
+	}
+
+	tests := []struct {
+		name  string
+		want  func(id int) bool
+		ids   []int
+		stats LoadStats
+	}{
+		{
+			name:  "all",
+			ids:   []int{1342, 2554, 2701, 2701},
+			stats: LoadStats{Files: 5, Loaded: 4, Failed: 1},
+		},
+		{
+			// pg9999.rdf is skipped by its name without being parsed;
+			// moby.rdf is parsed, then skipped
+			name:  "wanted",
+			want:  func(id int) bool { return id == 1342 },
+			ids:   []int{1342},
+			stats: LoadStats{Files: 5, Loaded: 1, Skipped: 4},
+		},
+	}
+
+	for source, path := range sources {
+		for _, tt := range tests {
+			t.Run(source+"/"+tt.name, func(t *testing.T) {
+				entries, stats, err := Load(path, tt.want)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stats != tt.stats {
+					t.Errorf("stats = %+v, want %+v", stats, tt.stats)
+				}
+
+				count := make(map[int]int)
+				for _, entry := range entries {
+					count[entry.ID]++
+				}
+				want := make(map[int]int)
+				for _, id := range tt.ids {
+					want[id]++
+				}
+				if !reflect.DeepEqual(count, want) {
+					t.Errorf("loaded books %v, want %v", count, want)
+				}
+			})
+		}
+	}
+
+	if _, _, err := Load(filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+		t.Error("loading a missing catalog should fail")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	books := map[int]string{
+		// No header: the catalog fills it in
+		1342: "It is a truth universally acknowledged, that a single man in possession of a good fortune...\n",
+		// The book's own header is kept
+		2701: "Title: Moby-Dick\n\nAuthor: H. Melville\n\nRelease date: June 1, 1991\n\nLanguage: English\n\n" +
+			"*** START OF THE PROJECT GUTENBERG EBOOK MOBY-DICK ***\n\nCall me Ishmael.\n\n" +
+			"*** END OF THE PROJECT GUTENBERG EBOOK MOBY-DICK ***\n",
+	}
+	idx := indexer.NewIndexer()
+	for id, content := range books {
+		path := filepath.Join(dir, "book.txt")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.IndexBook(id, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, _, err := Load(testdataDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := Merge(idx, entries)
+	if want := (MergeStats{Merged: 2, NotInIndex: 1}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	pride := idx.Books[1342]
+	if pride.Title != "Pride and Prejudice" || pride.Author != "Jane Austen" ||
+		pride.Language != "en" || pride.ReleaseDate != "1998-06-01" {
+		t.Errorf("header of 1342 not filled from the catalog: %+v", pride)
+	}
+	if !reflect.DeepEqual(pride.Subjects, []string{"Courtship -- Fiction", "England -- Fiction", "Sisters -- Fiction"}) ||
+		!reflect.DeepEqual(pride.LCC, []string{"PR"}) || len(pride.Bookshelves) != 2 {
+		t.Errorf("catalog metadata of 1342 not merged: %+v", pride)
+	}
+
+	moby := idx.Books[2701]
+	if moby.Title != "Moby-Dick" || moby.Author != "H. Melville" || moby.ReleaseDate != "June 1, 1991" {
+		t.Errorf("header of 2701 replaced by the catalog: %+v", moby)
+	}
+	if len(moby.Authors) != 1 || moby.Authors[0].Name != "Melville, Herman" || moby.Authors[0].BirthYear != 1819 {
+		t.Errorf("catalog authors of 2701 not merged: %+v", moby.Authors)
+	}
+	if moby.FilePath == "" || moby.WordCount == 0 {
+		t.Errorf("indexing data of 2701 lost: %+v", moby)
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := map[string]string{
+		"Austen, Jane":           "Jane Austen",
+		"Melville, Herman":       "Herman Melville",
+		"Homer":                  "Homer",
+		"Dumas, Alexandre, père": "Dumas, Alexandre, père",
+	}
+	for name, want := range tests {
+		if got := displayName(name); got != want {
+			t.Errorf("displayName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package catalog
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadStats counts the files seen while loading a catalog
+type LoadStats struct {
+	Files   int // RDF files found
+	Loaded  int // entries returned
+	Skipped int // entries not wanted
+	Failed  int // files that could not be parsed
+}
+
+// Load reads the catalog entries of a directory of .rdf files (searched recursively,
+// like the cache/epub/<id>/pg<id>.rdf layout of the official archive) or of a
+// tarball of them (.tar, .tar.gz, .tgz or .tar.bz2 like rdf-files.tar.bz2).
+// Only the books for which want returns true are kept (all if want is nil):
+// the full catalog has tens of thousands of books.
+func Load(path string, want func(id int) bool) ([]Entry, LoadStats, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, LoadStats{}, err
+	}
+
+	l := &loader{want: want}
+	if info.IsDir() {
+		err = l.loadDir(path)
+	} else {
+		err = l.loadTarball(path)
+	}
+	return l.entries, l.stats, err
+}
+
+type loader struct {
+	want    func(id int) bool
+	entries []Entry
+	stats   LoadStats
+}
+
+// add parses one RDF file. Files named pg<id>.rdf that are not wanted are skipped
+// without being parsed, which is most of them when importing for a small library.
+func (l *loader) add(name string, r io.Reader) {
+	l.stats.Files++
+
+	var id int
+	if _, err := fmt.Sscanf(filepath.Base(name), "pg%d.rdf", &id); err == nil && l.want != nil && !l.want(id) {
+		l.stats.Skipped++
+		return
+	}
+
+	entry, err := ParseRDF(r)
+	if err != nil {
+		l.stats.Failed++
+		return
+	}
+	if l.want != nil && !l.want(entry.ID) {
+		l.stats.Skipped++
+		return
+	}
+
+	l.entries = append(l.entries, entry)
+	l.stats.Loaded++
+}
+
+func (l *loader) loadDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".rdf") {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		l.add(path, file)
+		return nil
+	})
+}
+
+func (l *loader) loadTarball(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	switch {
+	case strings.HasSuffix(path, ".gz"), strings.HasSuffix(path, ".tgz"):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	case strings.HasSuffix(path, ".bz2"):
+		r = bzip2.NewReader(file)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if header.Typeflag == tar.TypeReg && strings.HasSuffix(header.Name, ".rdf") {
+			l.add(header.Name, tr)
+		}
+	}
+}
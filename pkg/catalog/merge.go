@@ -0,0 +1,61 @@
+package catalog
+
+import (
+	"strings"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+)
+
+// MergeStats counts how many catalog entries matched an indexed book
+type MergeStats struct {
+	Merged     int
+	NotInIndex int
+}
+
+// Merge adds the catalog metadata to the books of the index, matched by Gutenberg ID.
+// Catalog values replace those of a previous import; the title, author, language and
+// release date from the book's own header are only filled in when it had none.
+func Merge(idx *indexer.Indexer, entries []Entry) MergeStats {
+	var stats MergeStats
+
+	for _, entry := range entries {
+		book, exists := idx.Books[entry.ID]
+		if !exists {
+			stats.NotInIndex++
+			continue
+		}
+
+		book.Authors = entry.Authors
+		book.Subjects = entry.Subjects
+		book.Bookshelves = entry.Bookshelves
+		book.LCC = entry.LCC
+
+		if (book.Title == "" || book.Title == "Unknown") && entry.Title != "" {
+			book.Title = entry.Title
+		}
+		if (book.Author == "" || book.Author == "Unknown") && len(entry.Authors) > 0 {
+			book.Author = displayName(entry.Authors[0].Name)
+		}
+		if book.Language == "" {
+			book.Language = indexer.LanguageCode(entry.Language)
+		}
+		if book.ReleaseDate == "" {
+			book.ReleaseDate = entry.Issued
+		}
+
+		if err := idx.UpdateMetadata(book); err == nil {
+			stats.Merged++
+		}
+	}
+
+	return stats
+}
+
+// displayName turns the catalog's "Austen, Jane" into "Jane Austen"
+func displayName(name string) string {
+	parts := strings.Split(name, ", ")
+	if len(parts) != 2 {
+		return name
+	}
+	return parts[1] + " " + parts[0]
+}
@@ -0,0 +1,132 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// Entry is the catalog record of one book
+type Entry struct {
+	ID          int
+	Title       string
+	Authors     []models.Person
+	Subjects    []string // Library of Congress subject headings
+	LCC         []string // Library of Congress classification
+	Bookshelves []string
+	Language    string // ISO code, e.g. "en"
+	Issued      string // date the ebook was released, yyyy-mm-dd
+}
+
+// The RDF of a book (https://www.gutenberg.org/ebooks/1342.rdf), reduced to what we use.
+// Elements are matched by local name, without their namespaces (pgterms, dcterms, rdf...).
+type rdfDocument struct {
+	Ebook  rdfEbook   `xml:"ebook"`
+	Agents []rdfAgent `xml:"agent"`
+}
+
+type rdfEbook struct {
+	About       string       `xml:"about,attr"` // "ebooks/1342"
+	Titles      []string     `xml:"title"`
+	Creators    []rdfCreator `xml:"creator"`
+	Subjects    []rdfValue   `xml:"subject>Description"`
+	Bookshelves []rdfValue   `xml:"bookshelf>Description"`
+	Languages   []string     `xml:"language>Description>value"`
+	Issued      string       `xml:"issued"`
+}
+
+// rdfCreator holds its agent, or refers to an agent described elsewhere in the file
+type rdfCreator struct {
+	Resource string    `xml:"resource,attr"`
+	Agent    *rdfAgent `xml:"agent"`
+}
+
+type rdfAgent struct {
+	About     string `xml:"about,attr"`
+	Name      string `xml:"name"`
+	BirthDate string `xml:"birthdate"`
+	DeathDate string `xml:"deathdate"`
+}
+
+// rdfValue is a value with the vocabulary it belongs to (LCSH, LCC, Bookshelf...)
+type rdfValue struct {
+	MemberOf struct {
+		Resource string `xml:"resource,attr"`
+	} `xml:"memberOf"`
+	Value string `xml:"value"`
+}
+
+// ParseRDF reads the catalog record of one book
+func ParseRDF(r io.Reader) (Entry, error) {
+	var doc rdfDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return Entry{}, fmt.Errorf("invalid RDF: %w", err)
+	}
+
+	ebook := doc.Ebook
+	id, err := strconv.Atoi(path.Base(ebook.About))
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid ebook id %q", ebook.About)
+	}
+
+	entry := Entry{
+		ID:     id,
+		Issued: strings.TrimSpace(ebook.Issued),
+	}
+	if len(ebook.Titles) > 0 {
+		entry.Title = strings.Join(strings.Fields(ebook.Titles[0]), " ")
+	}
+	if len(ebook.Languages) > 0 {
+		entry.Language = strings.TrimSpace(ebook.Languages[0])
+	}
+
+	agents := make(map[string]rdfAgent, len(doc.Agents))
+	for _, agent := range doc.Agents {
+		agents[agent.About] = agent
+	}
+	for _, creator := range ebook.Creators {
+		agent, found := agents[creator.Resource]
+		if creator.Agent != nil {
+			agent, found = *creator.Agent, true
+		}
+		if found && agent.Name != "" {
+			entry.Authors = append(entry.Authors, models.Person{
+				Name:      strings.TrimSpace(agent.Name),
+				BirthYear: parseYear(agent.BirthDate),
+				DeathYear: parseYear(agent.DeathDate),
+			})
+		}
+	}
+
+	for _, subject := range ebook.Subjects {
+		value := strings.TrimSpace(subject.Value)
+		switch {
+		case value == "":
+		case strings.HasSuffix(subject.MemberOf.Resource, "/LCC"):
+			entry.LCC = append(entry.LCC, value)
+		case strings.HasSuffix(subject.MemberOf.Resource, "/LCSH"):
+			entry.Subjects = append(entry.Subjects, value)
+		}
+	}
+	for _, shelf := range ebook.Bookshelves {
+		if value := strings.TrimSpace(shelf.Value); value != "" {
+			entry.Bookshelves = append(entry.Bookshelves, value)
+		}
+	}
+
+	return entry, nil
+}
+
+// parseYear reads a birth or death year; years BC are negative, unknown years are 0
+func parseYear(value string) int {
+	year, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0
+	}
+	return year
+}
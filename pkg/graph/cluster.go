@@ -0,0 +1,70 @@
+package graph
+
+import "sort"
+
+// maxClusterIterations bounds label propagation, which usually settles in a few rounds
+const maxClusterIterations = 20
+
+// FindClusters groups the books of the graph into clusters of similar books with
+// label propagation: every book starts in its own cluster, then repeatedly joins the
+// cluster its neighbours belong to, weighted by similarity, until nothing changes.
+//
+// It returns the cluster of each book in bookIDs. A cluster is identified by its most
+// connected book, so it can be named after that book. Books without edges are alone
+// in their cluster.
+func FindClusters(graph *JaccardGraph, bookIDs []int) map[int]int {
+	ids := append([]int(nil), bookIDs...)
+	sort.Ints(ids)
+
+	label := make(map[int]int, len(ids))
+	for _, id := range ids {
+		label[id] = id
+	}
+
+	// Books are visited in ID order and ties go to the smallest label,
+	// so the same graph always gives the same clusters
+	for iteration := 0; iteration < maxClusterIterations; iteration++ {
+		changed := false
+		for _, id := range ids {
+			weights := make(map[int]float64)
+			for _, edge := range graph.Edges[id] {
+				if l, found := label[edge.Target]; found {
+					weights[l] += edge.Similarity
+				}
+			}
+			if len(weights) == 0 {
+				continue
+			}
+
+			best, bestWeight := label[id], weights[label[id]]
+			for l, w := range weights {
+				if w > bestWeight || (w == bestWeight && l < best) {
+					best, bestWeight = l, w
+				}
+			}
+			if best != label[id] {
+				label[id] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	// Name each cluster after its book with the most edges
+	center := make(map[int]int)
+	for _, id := range ids {
+		l := label[id]
+		c, found := center[l]
+		if !found || len(graph.Edges[id]) > len(graph.Edges[c]) {
+			center[l] = id
+		}
+	}
+
+	clusters := make(map[int]int, len(ids))
+	for _, id := range ids {
+		clusters[id] = center[label[id]]
+	}
+	return clusters
+}
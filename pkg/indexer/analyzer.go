@@ -0,0 +1,303 @@
+package indexer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultMinWordLength keeps two-letter words like "ox" or "go"
+const DefaultMinWordLength = 2
+
+// Token is one word of a text after analysis
+type Token struct {
+	Term    string `json:"term"`    // form stored in the index (e.g. the stem)
+	Surface string `json:"surface"` // lowercased word as written in the text, for display
+	Start   int    `json:"start"`   // byte offsets of the word in the original text
+	End     int    `json:"end"`
+	// Alias marks an extra term for the same word (e.g. its form without accents):
+	// it is indexed but not counted as a word, and not searched for in queries
+	Alias bool `json:"alias,omitempty"`
+}
+
+// CharFilter rewrites each rune of the text before it is tokenized.
+// Filtering rune by rune keeps token offsets valid in the original text.
+type CharFilter func(r rune) rune
+
+// Tokenizer cuts a text into tokens, passing every rune through the char filter first
+type Tokenizer func(text string, charFilter CharFilter) []Token
+
+// TokenFilter transforms a stream of tokens; it may drop or rewrite them
+type TokenFilter func(tokens []Token) []Token
+
+// Analyzer turns text into index terms.
+// The same analyzer must be used at index and query time so both sides agree on the terms.
+type Analyzer interface {
+	Name() string
+	Analyze(text string) []Token
+}
+
+// Chain is the usual analyzer: char filters, then a tokenizer, then token filters
+type Chain struct {
+	ChainName   string
+	CharFilters []CharFilter
+	Tokenizer   Tokenizer
+	Filters     []TokenFilter
+}
+
+// Name returns the name of the chain
+func (c *Chain) Name() string {
+	return c.ChainName
+}
+
+// Analyze runs the whole chain on a text
+func (c *Chain) Analyze(text string) []Token {
+	charFilter := func(r rune) rune {
+		for _, f := range c.CharFilters {
+			r = f(r)
+		}
+		return r
+	}
+
+	tokens := c.Tokenizer(text, charFilter)
+	for _, filter := range c.Filters {
+		tokens = filter(tokens)
+	}
+	return tokens
+}
+
+// AnalyzeTerms returns the distinct terms of a query, in order of first appearance.
+// Aliases are left out: "godel" finds books with "gödel" through the index's
+// folded alias, while "gödel" only finds the accented spelling.
+func AnalyzeTerms(a Analyzer, text string) []string {
+	seen := make(map[string]bool)
+	terms := []string{}
+	for _, token := range a.Analyze(text) {
+		if !token.Alias && !seen[token.Term] {
+			seen[token.Term] = true
+			terms = append(terms, token.Term)
+		}
+	}
+	return terms
+}
+
+// LowercaseFilter is a char filter lowercasing every letter
+func LowercaseFilter(r rune) rune {
+	return unicode.ToLower(r)
+}
+
+// isIdeograph reports runes that are words on their own (Chinese, Japanese kanji)
+func isIdeograph(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// CountWords returns the number of words of a token stream, not counting aliases
+func CountWords(tokens []Token) int {
+	n := 0
+	for _, token := range tokens {
+		if !token.Alias {
+			n++
+		}
+	}
+	return n
+}
+
+// WordTokenizer emits runs of letters and digits, with their combining accents.
+// Ideographs are emitted one by one, since Chinese text has no spaces between words.
+func WordTokenizer(text string, charFilter CharFilter) []Token {
+	var tokens []Token
+	var current []rune
+	start := -1
+
+	flush := func(end int) {
+		if start >= 0 {
+			word := string(current)
+			tokens = append(tokens, Token{Term: word, Surface: word, Start: start, End: end})
+		}
+		current = current[:0]
+		start = -1
+	}
+
+	for i, r := range text {
+		r = charFilter(r)
+		switch {
+		case isIdeograph(r):
+			flush(i)
+			word := string(r)
+			tokens = append(tokens, Token{Term: word, Surface: word, Start: i, End: i + utf8.RuneLen(r)})
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if start < 0 {
+				start = i
+			}
+			current = append(current, r)
+		case unicode.IsMark(r) && start >= 0:
+			current = append(current, r)
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+
+	return tokens
+}
+
+// StopFilter drops stop words
+func StopFilter(stopWords map[string]bool) TokenFilter {
+	return func(tokens []Token) []Token {
+		kept := tokens[:0]
+		for _, token := range tokens {
+			if !stopWords[token.Term] {
+				kept = append(kept, token)
+			}
+		}
+		return kept
+	}
+}
+
+// LengthFilter drops words of less than minLength letters.
+// A single ideograph is a whole word and is always kept.
+func LengthFilter(minLength int) TokenFilter {
+	return func(tokens []Token) []Token {
+		kept := tokens[:0]
+		for _, token := range tokens {
+			n := utf8.RuneCountInString(token.Term)
+			if n >= minLength || (n == 1 && isIdeograph([]rune(token.Term)[0])) {
+				kept = append(kept, token)
+			}
+		}
+		return kept
+	}
+}
+
+// NormalizeFilter puts terms in Unicode NFKC form, so that "café" typed with a
+// combining accent, ligatures ("ﬁ") or full-width letters match the usual spelling
+func NormalizeFilter(tokens []Token) []Token {
+	for i := range tokens {
+		tokens[i].Term = normalize(tokens[i].Term)
+		tokens[i].Surface = normalize(tokens[i].Surface)
+	}
+	return tokens
+}
+
+func normalize(word string) string {
+	if isASCII(word) {
+		return word
+	}
+	return strings.ToLower(norm.NFKC.String(word))
+}
+
+// FoldFilter adds, after every word with diacritics, an alias without them
+// ("gödel" -> "godel"), so that both spellings can be searched
+func FoldFilter(tokens []Token) []Token {
+	out := make([]Token, 0, len(tokens))
+	for _, token := range tokens {
+		out = append(out, token)
+		if folded := FoldDiacritics(token.Term); folded != token.Term {
+			alias := token
+			alias.Term = folded
+			alias.Alias = true
+			out = append(out, alias)
+		}
+	}
+	return out
+}
+
+// Letters that are not a base letter plus a combining accent in Unicode
+var foldedLetters = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ł': "l", 'þ': "th", 'ð': "d", 'ı': "i",
+}
+
+// FoldDiacritics removes the accents of a lowercase word ("élève" -> "eleve")
+func FoldDiacritics(word string) string {
+	if isASCII(word) {
+		return word
+	}
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(word) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if folded, found := foldedLetters[r]; found {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(b.String())
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// StemFilter replaces each term by its Porter2 stem, keeping the surface form
+func StemFilter(tokens []Token) []Token {
+	for i := range tokens {
+		tokens[i].Term = Stem(tokens[i].Term)
+	}
+	return tokens
+}
+
+// SimpleAnalyzer is what indexes built before analyzers existed used:
+// lowercase, the original English stop words and words of 3 bytes or more.
+// It is kept so that those indexes are still queried the same way.
+var SimpleAnalyzer Analyzer = &Chain{
+	ChainName:   "simple",
+	CharFilters: []CharFilter{LowercaseFilter},
+	Tokenizer:   WordTokenizer,
+	Filters: []TokenFilter{
+		func(tokens []Token) []Token {
+			kept := tokens[:0]
+			for _, token := range tokens {
+				if len(token.Term) > 2 && !legacyStopWords[token.Term] {
+					kept = append(kept, token)
+				}
+			}
+			return kept
+		},
+	},
+}
+
+// AnalyzerOptions are the settings of the language analyzers
+type AnalyzerOptions struct {
+	// MinLength is the minimum number of letters of a word (DefaultMinWordLength if 0)
+	MinLength int
+	// FoldAccents also indexes words without their diacritics
+	FoldAccents bool
+}
+
+// NewLanguageAnalyzer builds the analyzer of a language (see Languages): lowercase,
+// NFKC, minimum length, the language's stop words, accent folding if enabled
+// and, for English, stemming
+func NewLanguageAnalyzer(language string, opts AnalyzerOptions) Analyzer {
+	minLength := opts.MinLength
+	if minLength <= 0 {
+		minLength = DefaultMinWordLength
+	}
+
+	chain := &Chain{
+		ChainName:   language,
+		CharFilters: []CharFilter{LowercaseFilter},
+		Tokenizer:   WordTokenizer,
+		Filters: []TokenFilter{
+			NormalizeFilter,
+			LengthFilter(minLength),
+			StopFilter(StopWordsFor(LanguageCode(language))),
+		},
+	}
+	if opts.FoldAccents {
+		chain.Filters = append(chain.Filters, FoldFilter)
+	}
+	if language == "english" {
+		chain.Filters = append(chain.Filters, StemFilter)
+	}
+	return chain
+}
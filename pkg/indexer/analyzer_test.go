@@ -0,0 +1,69 @@
+package indexer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"cafe\u0301": "café", // combining accent
+		"café":       "café",
+		"ﬁsh":        "fish",  // ligature
+		"ＷＨＡＬＥ":      "whale", // full width
+		"Gödel":      "gödel",
+		"whale":      "whale",
+	}
+	for word, want := range tests {
+		if got := normalize(word); got != want {
+			t.Errorf("normalize(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestFoldDiacritics(t *testing.T) {
+	tests := map[string]string{
+		"gödel":   "godel",
+		"élève":   "eleve",
+		"café":    "cafe",
+		"straße":  "strasse",
+		"œuvre":   "oeuvre",
+		"søren":   "soren",
+		"łódź":    "lodz",
+		"whale":   "whale",
+		"москва":  "москва",
+		"ἀρχή":    "αρχη",
+		"naïveté": "naivete",
+	}
+	for word, want := range tests {
+		if got := FoldDiacritics(word); got != want {
+			t.Errorf("FoldDiacritics(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestAnalyzeAccents(t *testing.T) {
+	analyzer := NewIndexer().AnalyzerFor("en")
+
+	// The precomposed and combining spellings give the same terms, with an alias without the accent
+	precomposed := analyzer.Analyze("café Gödel")
+	combining := analyzer.Analyze("cafe\u0301 Go\u0308del")
+	var terms, aliases []string
+	for i, token := range precomposed {
+		if token.Term != combining[i].Term || token.Alias != combining[i].Alias {
+			t.Errorf("token %d: %+v and %+v differ", i, token, combining[i])
+		}
+		if token.Alias {
+			aliases = append(aliases, token.Term)
+		} else {
+			terms = append(terms, token.Term)
+		}
+	}
+	if !reflect.DeepEqual(terms, []string{"café", "gödel"}) || !reflect.DeepEqual(aliases, []string{"cafe", "godel"}) {
+		t.Errorf("terms %v, aliases %v", terms, aliases)
+	}
+	// Aliases are not words of the book
+	if n := CountWords(precomposed); n != 2 {
+		t.Errorf("CountWords = %d, want 2", n)
+	}
+}
@@ -0,0 +1,258 @@
+package indexer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+const (
+	// maxHeadingLength is the length above which a line is text, not a heading
+	maxHeadingLength = 80
+
+	// contentsGap is the distance under which headings following each other are
+	// taken for a table of contents rather than for chapters
+	contentsGap = 400
+
+	// sectionSize is the size of the sections a book without headings is cut into
+	sectionSize = 64 * 1024
+)
+
+// numberPattern matches the number of a chapter: roman, arabic or in words
+const numberPattern = `(?:[IVXLCDM]+|[ivxlcdm]+|\d+|(?i:the\s+)?(?i:one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve|thirteen|fourteen|fifteen|sixteen|seventeen|eighteen|nineteen|twenty|first|second|third|fourth|fifth|sixth|seventh|eighth|ninth|tenth|last|premier|première|premiere|erstes))`
+
+// Headings of the parts of a book (level 1) and of its chapters (level 2),
+// in the languages of the index
+var (
+	partHeading = regexp.MustCompile(`^(?:BOOK|Book|PART|Part|VOLUME|Volume|ACT|Act|LIVRE|Livre|PARTIE|Partie|TOME|Tome|BUCH|Buch|TEIL|Teil|LIBRO|Libro|PARTE|Parte|DEEL|Deel|OSA|Osa)\s+` + numberPattern + `\b[.:]?\s*(.*)$`)
+
+	chapterHeading = regexp.MustCompile(`^(?:CHAPTER|Chapter|SECTION|Section|LETTER|Letter|CANTO|Canto|STAVE|Stave|SCENE|Scene|CHAPITRE|Chapitre|KAPITEL|Kapitel|CAPÍTULO|Capítulo|CAPITULO|Capitulo|CAPITOLO|Capitolo|HOOFDSTUK|Hoofdstuk|LUKU|Luku)\s+` + numberPattern + `\b[.:]?\s*(.*)$`)
+
+	// Chinese novels: 第一章 (chapter), 第一回 (episode)
+	chineseHeading = regexp.MustCompile(`^第[一二三四五六七八九十百千零〇0-9]+[章回节]\s*(.*)$`)
+
+	// Headings without a number, in capitals as they are printed
+	namedHeading = regexp.MustCompile(`^(?:PREFACE|INTRODUCTION|PROLOGUE|EPILOGUE|CONCLUSION|ETYMOLOGY|EXTRACTS|APPENDIX|POSTSCRIPT|AFTERWORD|FOREWORD)\.?$`)
+
+	// A roman numeral alone on its line, used when a book has no other heading
+	numeralHeading = regexp.MustCompile(`^[IVXLC]+\.?$`)
+)
+
+// heading is a heading line found in a book
+type heading struct {
+	title string
+	level int
+	start int // offset of the line in the file
+	// key is the heading without its title ("chapter i"), to find it in a table of contents
+	key string
+	// bare means the heading is only a number, its title may be on the next line
+	bare bool
+}
+
+// DetectChapters cuts a book into chapters from their headings ("CHAPTER I.", "BOOK II",
+// "PREFACE"...). The chapters cover the whole file: the text before the first heading
+// (title page, contents) and the license after the book are chapters too.
+// A book without headings is cut into sections of about sectionSize bytes.
+func DetectChapters(content string, text GutenbergText) []models.Chapter {
+	bodyEnd := text.BodyStart + len(text.Body)
+
+	headings := findHeadings(content, text.BodyStart, bodyEnd, false)
+	if len(headings) == 0 {
+		headings = findHeadings(content, text.BodyStart, bodyEnd, true)
+	}
+	headings = dropContents(headings)
+
+	var chapters []models.Chapter
+	add := func(title string, level, start, end int) {
+		if strings.TrimSpace(content[start:end]) != "" {
+			chapters = append(chapters, models.Chapter{Title: title, Level: level, Start: start, End: end})
+		}
+	}
+
+	if len(headings) == 0 {
+		for i, section := range splitSections(content, text.BodyStart, bodyEnd) {
+			add(sectionTitle(i+1), 1, section[0], section[1])
+		}
+	} else {
+		add("Beginning", 1, text.BodyStart, headings[0].start)
+		for i, h := range headings {
+			end := bodyEnd
+			if i+1 < len(headings) {
+				end = headings[i+1].start
+			}
+			add(h.title, h.level, h.start, end)
+		}
+	}
+
+	// The Gutenberg header and license stay readable, attached to the first and last chapters
+	if len(chapters) == 0 {
+		chapters = []models.Chapter{{Title: "Text", Level: 1, Start: 0, End: len(content)}}
+	}
+	chapters[0].Start = 0
+	chapters[len(chapters)-1].End = len(content)
+	countCharacters(content, chapters)
+	return chapters
+}
+
+// countCharacters sets the character offsets and lengths of chapters, counted once
+// here rather than on every page the reader shows
+func countCharacters(content string, chapters []models.Chapter) {
+	chars, pos := 0, 0
+	for i := range chapters {
+		ch := &chapters[i]
+		chars += utf8.RuneCountInString(content[pos:ch.Start])
+		ch.Offset = chars
+		ch.Length = utf8.RuneCountInString(content[ch.Start:ch.End])
+		chars += ch.Length
+		pos = ch.End
+	}
+}
+
+// findHeadings returns the heading lines between start and end. A heading is a short
+// line after a blank line. Bare roman numerals only count when numerals is true.
+func findHeadings(content string, start, end int, numerals bool) []heading {
+	var headings []heading
+
+	previousBlank := true
+	offset := start
+	for offset < end {
+		next := end
+		if i := strings.IndexByte(content[offset:end], '\n'); i >= 0 {
+			next = offset + i + 1
+		}
+		line := strings.TrimSpace(content[offset:next])
+
+		if line != "" && previousBlank && len(line) <= maxHeadingLength {
+			if h, ok := parseHeading(line, numerals); ok {
+				h.start = offset
+				h.title = headingTitle(h, content, next, end)
+				headings = append(headings, h)
+			}
+		}
+
+		previousBlank = line == ""
+		offset = next
+	}
+	return headings
+}
+
+// parseHeading recognizes a heading line
+func parseHeading(line string, numerals bool) (heading, bool) {
+	line = strings.Join(strings.Fields(line), " ")
+
+	for _, kind := range []struct {
+		pattern *regexp.Regexp
+		level   int
+	}{{partHeading, 1}, {chapterHeading, 2}, {chineseHeading, 2}} {
+		if m := kind.pattern.FindStringSubmatch(line); m != nil {
+			return heading{
+				title: line,
+				level: kind.level,
+				key:   headingKey(strings.TrimSuffix(line, m[1])),
+				bare:  m[1] == "",
+			}, true
+		}
+	}
+
+	if namedHeading.MatchString(line) {
+		return heading{title: line, level: 1, key: headingKey(line)}, true
+	}
+	if numerals && numeralHeading.MatchString(line) {
+		return heading{title: line, level: 2, key: headingKey(line), bare: true}, true
+	}
+	return heading{}, false
+}
+
+func headingKey(line string) string {
+	return strings.TrimRight(strings.ToLower(strings.TrimSpace(line)), ".: ")
+}
+
+// headingTitle adds its title to a heading that is only a number ("CHAPTER I"),
+// when the title is on the next line: a short line followed by a blank line
+func headingTitle(h heading, content string, offset, end int) string {
+	if !h.bare {
+		return h.title
+	}
+
+	var lines []string
+	for offset < end && len(lines) < 4 {
+		next := end
+		if i := strings.IndexByte(content[offset:end], '\n'); i >= 0 {
+			next = offset + i + 1
+		}
+		lines = append(lines, strings.TrimSpace(content[offset:next]))
+		offset = next
+	}
+
+	// skip the blank lines between the heading and its title
+	i := 0
+	for i < len(lines) && lines[i] == "" {
+		i++
+	}
+	if i == len(lines) || i+1 >= len(lines) || lines[i+1] != "" {
+		return h.title
+	}
+	title := lines[i]
+	if len(title) > maxHeadingLength/2 {
+		return h.title
+	}
+	if _, isHeading := parseHeading(title, true); isHeading {
+		return h.title
+	}
+	return strings.TrimRight(h.title, ".:") + ". " + title
+}
+
+// dropContents removes the headings of a table of contents: a run of headings close
+// to each other, each of which appears again later in the book
+func dropContents(headings []heading) []heading {
+	later := make(map[string]int)
+	for _, h := range headings {
+		later[h.key]++
+	}
+
+	var kept []heading
+	i := 0
+	for i < len(headings) {
+		// the run of headings close to each other starting at i
+		j := i
+		for j+1 < len(headings) && headings[j+1].start-headings[j].start < contentsGap {
+			j++
+		}
+
+		for k := i; k <= j; k++ {
+			later[headings[k].key]--
+			if j-i+1 >= 3 && later[headings[k].key] > 0 {
+				continue
+			}
+			kept = append(kept, headings[k])
+		}
+		i = j + 1
+	}
+	return kept
+}
+
+// splitSections cuts a text into parts of about sectionSize bytes, at blank lines
+func splitSections(content string, start, end int) [][2]int {
+	var sections [][2]int
+	for start < end {
+		cut := end
+		if end-start > sectionSize {
+			cut = start + sectionSize
+			if i := strings.Index(content[cut:end], "\n\n"); i >= 0 {
+				cut += i + 2
+			} else {
+				cut = end
+			}
+		}
+		sections = append(sections, [2]int{start, cut})
+		start = cut
+	}
+	return sections
+}
+
+func sectionTitle(n int) string {
+	return "Section " + strconv.Itoa(n)
+}
@@ -0,0 +1,162 @@
+package indexer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// paragraph returns text long enough to keep headings apart, unlike those of a table of contents
+func paragraph(words string) string {
+	return strings.TrimSpace(strings.Repeat(words+" ", 500/len(words)+1)) + "\n\n"
+}
+
+// gutenbergBook wraps a body between the Gutenberg header and license
+func gutenbergBook(body string) string {
+	return "The Project Gutenberg eBook of Test\n\nTitle: Test\n\n" +
+		"*** START OF THE PROJECT GUTENBERG EBOOK TEST ***\n\n" + body +
+		"*** END OF THE PROJECT GUTENBERG EBOOK TEST ***\n\nThe license of Project Gutenberg.\n"
+}
+
+type titleLevel struct {
+	title string
+	level int
+}
+
+func chapterHeadings(chapters []models.Chapter) []titleLevel {
+	var headings []titleLevel
+	for _, ch := range chapters {
+		headings = append(headings, titleLevel{ch.Title, ch.Level})
+	}
+	return headings
+}
+
+// checkChapters checks that the chapters follow each other over the file, with
+// their headings in the body, and that their offsets in characters are right
+func checkChapters(t *testing.T, content string, chapters []models.Chapter) {
+	t.Helper()
+	text := SplitGutenberg(content)
+	bodyEnd := text.BodyStart + len(text.Body)
+
+	if len(chapters) == 0 || chapters[0].Start != 0 || chapters[len(chapters)-1].End != len(content) {
+		t.Fatalf("chapters don't cover the file of %d bytes: %+v", len(content), chapters)
+	}
+	end := 0
+	for i, ch := range chapters {
+		if ch.Start < end || ch.End < ch.Start {
+			t.Errorf("chapter %d (%q) at %d-%d overlaps the previous one, ending at %d", i+1, ch.Title, ch.Start, ch.End, end)
+		}
+		if i > 0 && (ch.Start < text.BodyStart || ch.Start >= bodyEnd) {
+			t.Errorf("chapter %d (%q) starts at %d, out of the body %d-%d", i+1, ch.Title, ch.Start, text.BodyStart, bodyEnd)
+		}
+		if want := utf8.RuneCountInString(content[:ch.Start]); ch.Offset != want {
+			t.Errorf("chapter %d (%q) at character %d, want %d", i+1, ch.Title, ch.Offset, want)
+		}
+		if want := utf8.RuneCountInString(content[ch.Start:ch.End]); ch.Length != want {
+			t.Errorf("chapter %d (%q) of %d characters, want %d", i+1, ch.Title, ch.Length, want)
+		}
+		end = ch.End
+	}
+}
+
+func TestDetectChapters(t *testing.T) {
+	content := gutenbergBook("MOBY-DICK; or, THE WHALE.\n\n" +
+		// The table of contents: headings close together, each found again below
+		"CONTENTS\n\nETYMOLOGY.\n\nCHAPTER 1. Loomings.\n\nCHAPTER 2. The Carpet-Bag.\n\nCHAPTER 3. The Spouter-Inn.\n\n" +
+		"ETYMOLOGY.\n\n" + paragraph("The pale Usher—threadbare in coat, heart, body, and brain.") +
+		"BOOK I\n\n" +
+		"CHAPTER 1. Loomings.\n\n" + paragraph("Call me Ishmael. Some years ago, never mind how long.") +
+		// Mentions of chapters that are not headings: inside a paragraph, and too long
+		"He had read it all before, and he would say so again to anyone at the inn:\n" +
+		"Chapter 2 of that book was the best, he said.\n\n" +
+		"Chapter 3 of the sailors' almanac, which nobody aboard had ever read to the end, was on the tides.\n\n" +
+		"CHAPTER 2. The Carpet-Bag.\n\n" + paragraph("I stuffed a shirt or two into my old carpet-bag.") +
+		"BOOK II\n\n" +
+		"CHAPTER 3. The Spouter-Inn.\n\n" + paragraph("Entering that gable-ended Spouter-Inn, you found yourself in a wide hall."))
+
+	chapters := DetectChapters(content, SplitGutenberg(content))
+	want := []titleLevel{
+		{"Beginning", 1},
+		{"ETYMOLOGY.", 1},
+		{"BOOK I", 1},
+		{"CHAPTER 1. Loomings.", 2},
+		{"CHAPTER 2. The Carpet-Bag.", 2},
+		{"BOOK II", 1},
+		{"CHAPTER 3. The Spouter-Inn.", 2},
+	}
+	if got := chapterHeadings(chapters); !reflect.DeepEqual(got, want) {
+		t.Errorf("chapters = %v, want %v", got, want)
+	}
+	checkChapters(t, content, chapters)
+
+	// The table of contents stays in the first chapter, with the header
+	if !strings.Contains(content[chapters[0].Start:chapters[0].End], "CHAPTER 3. The Spouter-Inn.") {
+		t.Error("the table of contents is not in the first chapter")
+	}
+	// The license is in the last
+	if !strings.HasSuffix(content[chapters[len(chapters)-1].Start:], "The license of Project Gutenberg.\n") {
+		t.Error("the license is not in the last chapter")
+	}
+}
+
+func TestDetectChaptersTitles(t *testing.T) {
+	// Headings that are only a number take the title on the line after them
+	content := gutenbergBook("CHAPTER I.\n\nThe Old Sea-Dog at the Admiral Benbow\n\n" + paragraph("Squire Trelawney asked me to write it down.") +
+		"CHAPTER II.\n\nBlack Dog Appears and Disappears\n\n" + paragraph("It was not very long after this."))
+
+	chapters := DetectChapters(content, SplitGutenberg(content))
+	want := []titleLevel{
+		{"CHAPTER I. The Old Sea-Dog at the Admiral Benbow", 2},
+		{"CHAPTER II. Black Dog Appears and Disappears", 2},
+	}
+	if got := chapterHeadings(chapters); !reflect.DeepEqual(got, want) {
+		t.Errorf("chapters = %v, want %v", got, want)
+	}
+	checkChapters(t, content, chapters)
+}
+
+func TestDetectChaptersNumerals(t *testing.T) {
+	// Roman numerals alone on their lines only count when there is no other heading
+	body := "I.\n\n" + paragraph("Happy families are all alike; every unhappy family is unhappy in its own way.") +
+		"II.\n\n" + paragraph("Three days after the quarrel, Prince Stepan Arkadyevitch Oblonsky woke up.") +
+		"III.\n\n" + paragraph("Être ou ne pas être, dit-il en français, à l'école du soir.")
+	content := gutenbergBook(body)
+
+	chapters := DetectChapters(content, SplitGutenberg(content))
+	want := []titleLevel{{"I.", 2}, {"II.", 2}, {"III.", 2}}
+	if got := chapterHeadings(chapters); !reflect.DeepEqual(got, want) {
+		t.Errorf("chapters = %v, want %v", got, want)
+	}
+	checkChapters(t, content, chapters)
+
+	withHeading := gutenbergBook("PREFACE\n\n" + body)
+	chapters = DetectChapters(withHeading, SplitGutenberg(withHeading))
+	if got := chapterHeadings(chapters); !reflect.DeepEqual(got, []titleLevel{{"PREFACE", 1}}) {
+		t.Errorf("chapters with a preface = %v, want the preface alone", got)
+	}
+	checkChapters(t, withHeading, chapters)
+}
+
+func TestDetectChaptersWithoutHeadings(t *testing.T) {
+	// A book without headings is cut into sections at blank lines
+	content := gutenbergBook(strings.Repeat(paragraph("No headings here, only a long text about the sea."), 300))
+
+	chapters := DetectChapters(content, SplitGutenberg(content))
+	if len(chapters) < 2 {
+		t.Fatalf("%d chapters, want sections", len(chapters))
+	}
+	for i, ch := range chapters {
+		if ch.Title != sectionTitle(i+1) || ch.End-ch.Start > sectionSize+1024 {
+			t.Errorf("section %d = %q, %d bytes", i+1, ch.Title, ch.End-ch.Start)
+		}
+	}
+	checkChapters(t, content, chapters)
+
+	// An empty file is one chapter
+	if chapters := DetectChapters("", SplitGutenberg("")); len(chapters) != 1 || chapters[0].End != 0 {
+		t.Errorf("chapters of an empty file = %+v", chapters)
+	}
+}
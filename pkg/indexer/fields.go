@@ -0,0 +1,156 @@
+package indexer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// MetadataFields are the fields of a book that can be searched on their own
+var MetadataFields = []string{
+	"title", "subtitle", "author", "translator", "illustrator", "editor", "credits", "publication",
+	"subject", "bookshelf",
+}
+
+// IsMetadataField reports whether name is one of MetadataFields
+func IsMetadataField(name string) bool {
+	for _, field := range MetadataFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldTerm is the name under which a term of a metadata field is indexed ("title:whale").
+// The tokenizer never keeps ":" so these can't be mistaken for words of the text.
+func FieldTerm(field, term string) string {
+	return field + ":" + term
+}
+
+// SplitFieldTerm returns the field and the term of a field term,
+// or "" and the term itself for a term of the text
+func SplitFieldTerm(term string) (field, word string) {
+	if i := strings.IndexByte(term, ':'); i >= 0 {
+		return term[:i], term[i+1:]
+	}
+	return "", term
+}
+
+// bookFields returns the values of the metadata fields of a book
+func bookFields(book models.Book) map[string]string {
+	return map[string]string{
+		"title":       book.Title,
+		"subtitle":    book.Subtitle,
+		"author":      book.Author,
+		"translator":  book.Translator,
+		"illustrator": book.Illustrator,
+		"editor":      book.Editor,
+		"credits":     book.Credits,
+		"publication": book.OriginalPublication,
+		"subject":     strings.Join(book.Subjects, "; "),
+		"bookshelf":   strings.Join(book.Bookshelves, "; "),
+	}
+}
+
+// fieldTerms analyzes the metadata fields of a book and counts their terms
+func fieldTerms(book models.Book, analyzer Analyzer) map[string]int {
+	terms := make(map[string]int)
+	for field, value := range bookFields(book) {
+		if value == "" || value == "Unknown" {
+			continue
+		}
+		for _, token := range analyzer.Analyze(value) {
+			terms[FieldTerm(field, token.Term)]++
+		}
+	}
+	return terms
+}
+
+// removeFields drops a book from the postings of the metadata fields
+func (idx *Indexer) removeFields(bookID int) {
+	for term, books := range idx.Fields {
+		if _, found := books[bookID]; !found {
+			continue
+		}
+		delete(books, bookID)
+		if len(books) == 0 {
+			delete(idx.Fields, term)
+		}
+	}
+}
+
+// addFields adds the field terms of a book to the postings
+func (idx *Indexer) addFields(bookID int, terms map[string]int) {
+	if idx.Fields == nil {
+		idx.Fields = make(map[string]map[int]int)
+	}
+	for term, count := range terms {
+		if idx.Fields[term] == nil {
+			idx.Fields[term] = make(map[int]int)
+		}
+		idx.Fields[term][bookID] = count
+	}
+}
+
+// UpdateMetadata replaces the metadata of an indexed book (e.g. from the catalog)
+// and reindexes its fields. The postings of the text are left alone, so the
+// file path and word count of the book are kept.
+func (idx *Indexer) UpdateMetadata(book models.Book) error {
+	old, exists := idx.Books[book.ID]
+	if !exists {
+		return fmt.Errorf("book %d is not indexed", book.ID)
+	}
+	book.FilePath = old.FilePath
+	book.WordCount = old.WordCount
+
+	idx.removeFields(book.ID)
+	idx.addFields(book.ID, fieldTerms(book, idx.AnalyzerFor(book.Language)))
+	idx.Books[book.ID] = book
+	return nil
+}
+
+// keepCatalog copies the catalog metadata of the previous version of a book,
+// which is not in the book's file and would be lost when the file is reindexed
+func keepCatalog(book *models.Book, old models.Book) {
+	book.Authors = old.Authors
+	book.Subjects = old.Subjects
+	book.Bookshelves = old.Bookshelves
+	book.LCC = old.LCC
+}
+
+// KeepCatalog copies the catalog metadata of the books of a previous index, such as
+// the one import_catalog completed, to the same books of this one and reindexes
+// their fields. It returns the number of books that had some.
+func (idx *Indexer) KeepCatalog(previous *Indexer) int {
+	kept := 0
+	for id, book := range idx.Books {
+		old, found := previous.Books[id]
+		if !found || len(old.Authors)+len(old.Subjects)+len(old.Bookshelves)+len(old.LCC) == 0 {
+			continue
+		}
+
+		analyzer := idx.AnalyzerFor(book.Language)
+		for term := range fieldTerms(book, analyzer) {
+			delete(idx.Fields[term], id)
+			if len(idx.Fields[term]) == 0 {
+				delete(idx.Fields, term)
+			}
+		}
+		keepCatalog(&book, old)
+		idx.addFields(id, fieldTerms(book, analyzer))
+		idx.Books[id] = book
+		kept++
+	}
+	return kept
+}
+
+// Postings returns the books containing a term and how many times,
+// looking in the metadata fields for field terms
+func (idx *Indexer) Postings(term string) map[int]int {
+	if field, _ := SplitFieldTerm(term); field != "" {
+		return idx.Fields[term]
+	}
+	return idx.WordToBooks[term]
+}
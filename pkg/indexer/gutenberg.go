@@ -0,0 +1,78 @@
+package indexer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Lines that end the Project Gutenberg header, from the current
+// "*** START OF THE PROJECT GUTENBERG EBOOK X ***" to the "small print" of the 90s
+var gutenbergStartMarkers = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^\s*\*+\s*START OF (THE |THIS )?(COPYRIGHTED )?PROJECT GUTENBERG`),
+	regexp.MustCompile(`(?i)^\s*\*END\*\s*THE SMALL PRINT`),
+}
+
+// Lines that start the license at the end of the book.
+// Older files have a plain "End of the Project Gutenberg EBook of X" line.
+var gutenbergEndMarkers = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^\s*\*+\s*END OF (THE |THIS )?(COPYRIGHTED )?PROJECT GUTENBERG`),
+	regexp.MustCompile(`(?i)^\s*END OF (THE )?PROJECT GUTENBERG`),
+}
+
+// GutenbergText is a book file cut into its parts
+type GutenbergText struct {
+	// Header is the text up to the START marker: metadata and license notice.
+	// It is empty if the file has no START marker.
+	Header string
+	// Body is the book itself, the whole file if no marker was found
+	Body string
+	// Footer is the text from the END marker: the full license
+	Footer string
+	// BodyStart is the byte offset of the body in the file
+	BodyStart int
+}
+
+// SplitGutenberg separates the Project Gutenberg header and footer from the
+// text of the book, so that the license isn't indexed as part of every book
+func SplitGutenberg(content string) GutenbergText {
+	bodyStart, bodyEnd := 0, len(content)
+
+	offset := 0
+	for offset < len(content) {
+		lineEnd := strings.IndexByte(content[offset:], '\n')
+		next := len(content)
+		if lineEnd >= 0 {
+			next = offset + lineEnd + 1
+		}
+		line := content[offset:next]
+
+		if bodyStart == 0 && matchesAny(gutenbergStartMarkers, line) {
+			bodyStart = next
+		} else if matchesAny(gutenbergEndMarkers, line) {
+			bodyEnd = offset
+			break
+		}
+		offset = next
+	}
+
+	return GutenbergText{
+		Header:    content[:bodyStart],
+		Body:      content[bodyStart:bodyEnd],
+		Footer:    content[bodyEnd:],
+		BodyStart: bodyStart,
+	}
+}
+
+func matchesAny(patterns []*regexp.Regexp, line string) bool {
+	// All markers start with "*" or "End", skip the regexps for other lines
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || (trimmed[0] != '*' && trimmed[0] != 'E' && trimmed[0] != 'e') {
+		return false
+	}
+	for _, pattern := range patterns {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const gutenbergLicense = "Updated editions will replace the previous one. This license applies to the " +
+	"Project Gutenberg trademark; redistribution is subject to the license terms.\n"
+
+func TestSplitGutenberg(t *testing.T) {
+	body := "\nCall me Ishmael. Some years ago, never mind how long precisely.\n\n"
+
+	tests := []struct {
+		name, header, footer string
+	}{
+		{
+			"current markers",
+			"The Project Gutenberg eBook of Moby Dick\n\nTitle: Moby Dick\n\n*** START OF THE PROJECT GUTENBERG EBOOK MOBY DICK ***\n",
+			"*** END OF THE PROJECT GUTENBERG EBOOK MOBY DICK ***\n\n" + gutenbergLicense,
+		},
+		{
+			"this project gutenberg",
+			"Title: Moby Dick\n\n*** START OF THIS PROJECT GUTENBERG EBOOK MOBY DICK ***\n",
+			"*** END OF THIS PROJECT GUTENBERG EBOOK MOBY DICK ***\n" + gutenbergLicense,
+		},
+		{
+			"old markers without spaces",
+			"Title: Moby Dick\n\n***START OF THIS PROJECT GUTENBERG EBOOK MOBY DICK***\n",
+			"End of the Project Gutenberg EBook of Moby Dick\n\n" + gutenbergLicense,
+		},
+		{
+			"plain end line",
+			"Title: Moby Dick\n\n*** START OF THE PROJECT GUTENBERG EBOOK MOBY DICK ***\n",
+			"End of Project Gutenberg's Moby Dick, by Herman Melville\n\n" + gutenbergLicense,
+		},
+		{
+			"small print of the 90s",
+			"Title: Moby Dick\n\n" + gutenbergLicense + "*END*THE SMALL PRINT! FOR PUBLIC DOMAIN ETEXTS*Ver.04.29.93*END*\n",
+			"End of The Project Gutenberg Etext of Moby Dick\n",
+		},
+		{
+			"copyrighted",
+			"Title: Moby Dick\n\n*** START OF THE COPYRIGHTED PROJECT GUTENBERG EBOOK MOBY DICK ***\n",
+			"*** END OF THE COPYRIGHTED PROJECT GUTENBERG EBOOK MOBY DICK ***\n",
+		},
+		{"no markers", "", ""},
+	}
+	for _, tt := range tests {
+		content := tt.header + body + tt.footer
+		text := SplitGutenberg(content)
+		if text.Header != tt.header || text.Body != body || text.Footer != tt.footer {
+			t.Errorf("%s: header %q, body %q, footer %q", tt.name, text.Header, text.Body, text.Footer)
+		}
+		if content[text.BodyStart:text.BodyStart+len(text.Body)] != text.Body {
+			t.Errorf("%s: body not at %d", tt.name, text.BodyStart)
+		}
+	}
+
+	// A mention of the markers in the text doesn't cut it: they start their line
+	content := "*** START OF THE PROJECT GUTENBERG EBOOK X ***\nHe wrote: *** END OF THE PROJECT GUTENBERG EBOOK X ***\n"
+	if text := SplitGutenberg(content); text.Footer != "" {
+		t.Errorf("footer cut inside a line: %q", text.Footer)
+	}
+}
+
+func TestBoilerplateNotIndexed(t *testing.T) {
+	dir := t.TempDir()
+	for id, content := range []string{
+		"Title: Moby Dick\n\n*** START OF THE PROJECT GUTENBERG EBOOK MOBY DICK ***\n\nCall me Ishmael.\n\n" +
+			"*** END OF THE PROJECT GUTENBERG EBOOK MOBY DICK ***\n\n" + gutenbergLicense,
+		"Title: Emma\n\n***START OF THIS PROJECT GUTENBERG EBOOK EMMA***\n\nEmma Woodhouse, handsome.\n\n" +
+			"End of the Project Gutenberg EBook of Emma\n\n" + gutenbergLicense,
+	} {
+		path := filepath.Join(dir, "book.txt")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		idx := NewIndexer()
+		if err := idx.IndexBook(id+1, path); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, word := range []string{"license", "gutenberg", "trademark", "editions"} {
+			for _, term := range idx.QueryTerms(word) {
+				if _, found := idx.WordToBooks[term]; found {
+					t.Errorf("book %d: %s of the boilerplate indexed", id+1, word)
+				}
+			}
+		}
+		body := strings.Fields(SplitGutenberg(content).Body)[0]
+		if _, found := idx.WordToBooks[idx.QueryTerms(body)[0]][id+1]; !found {
+			t.Errorf("book %d: %s of the body not indexed", id+1, body)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/taqiyeddinedj/daar-project3/pkg/models"
 )
@@ -13,92 +14,312 @@ type Indexer struct {
 	Books       map[int]models.Book    `json:"books"`
 	TotalWords  int                    `json:"total_words"`
 	UniqueWords int                    `json:"unique_words"`
+	// Analyzer is the name of the analyzer used for books of unknown language
+	// ("simple" or empty for indexes built before analyzers, see AnalyzerFor)
+	Analyzer string `json:"analyzer,omitempty"`
+	// MinWordLength is the minimum number of letters of an indexed word
+	MinWordLength int `json:"min_word_length,omitempty"`
+	// FoldAccents means words are also indexed without diacritics ("gödel" -> "godel")
+	FoldAccents bool `json:"fold_accents,omitempty"`
+	// Surfaces lists, for each term, the words of the books that produced it
+	// (e.g. "whale" -> whale, whales, whaling), so results can show real words
+	Surfaces map[string][]string `json:"surfaces,omitempty"`
+	// Fields holds the postings of the metadata fields, by field term ("title:whale", see FieldTerm)
+	Fields map[string]map[int]int `json:"fields,omitempty"`
+	// Chapters is the table of contents of each book (see DetectChapters).
+	// It is kept out of models.Book so that search results don't carry it.
+	Chapters map[int][]models.Chapter `json:"chapters,omitempty"`
+
+	vocabulary vocabularyCache
+}
+
+// parsedBook is everything extracted from a book file before it goes into the index
+type parsedBook struct {
+	book      models.Book
+	wordCount map[string]int
+	surfaces  map[string]map[string]bool
+	fields    map[string]int
+	chapters  []models.Chapter
 }
 
 // NewIndexer creates a new empty indexer
 func NewIndexer() *Indexer {
 	return &Indexer{
-		WordToBooks: make(map[string]map[int]int),
-		Books:       make(map[int]models.Book),
+		WordToBooks:   make(map[string]map[int]int),
+		Books:         make(map[int]models.Book),
+		Analyzer:      "english",
+		MinWordLength: DefaultMinWordLength,
+		FoldAccents:   true,
+		Surfaces:      make(map[string][]string),
+		Fields:        make(map[string]map[int]int),
+		Chapters:      make(map[int][]models.Chapter),
 	}
 }
 
-// IndexBook reads a book file and adds it to the index
+// IndexBook reads a book file and adds it to the index.
+// If the book is already indexed, its old postings are replaced.
 func (idx *Indexer) IndexBook(bookID int, filepath string) error {
+	parsed, err := readBook(bookID, filepath, idx.AnalyzerFor)
+	if err != nil {
+		return err
+	}
+
+	idx.replaceBook(parsed)
+	return nil
+}
+
+// UpdateBook re-reads the file of an already indexed book and replaces its postings.
+// The old postings are kept if the new file cannot be read.
+func (idx *Indexer) UpdateBook(bookID int, filepath string) error {
+	if _, exists := idx.Books[bookID]; !exists {
+		return fmt.Errorf("book %d is not indexed", bookID)
+	}
+
+	parsed, err := readBook(bookID, filepath, idx.AnalyzerFor)
+	if err != nil {
+		return err
+	}
+
+	idx.replaceBook(parsed)
+	return nil
+}
+
+// RemoveBook deletes a book and all of its postings from the index.
+// It returns false if the book was not indexed.
+func (idx *Indexer) RemoveBook(bookID int) bool {
+	book, exists := idx.Books[bookID]
+	if !exists {
+		return false
+	}
+
+	// We don't keep a book -> words map (it would double the size of index.json),
+	// so we walk the vocabulary once and drop this book from every posting list.
+	for word, books := range idx.WordToBooks {
+		if _, found := books[bookID]; !found {
+			continue
+		}
+		delete(books, bookID)
+		if len(books) == 0 {
+			delete(idx.WordToBooks, word)
+			delete(idx.Surfaces, word)
+		}
+	}
+
+	idx.removeFields(bookID)
+	delete(idx.Chapters, bookID)
+	idx.resetVocabulary()
+
+	delete(idx.Books, bookID)
+	idx.TotalWords -= book.WordCount
+	idx.UniqueWords = len(idx.WordToBooks)
+	return true
+}
+
+// readBook reads a book file and counts its words without touching the index
+func readBook(bookID int, filepath string, analyzerFor func(language string) Analyzer) (parsedBook, error) {
 	content, err := os.ReadFile(filepath)
 	if err != nil {
-		return fmt.Errorf("failed to read book %d: %w", bookID, err)
+		return parsedBook{}, fmt.Errorf("failed to read book %d: %w", bookID, err)
 	}
+	return parseBook(bookID, filepath, string(content), analyzerFor)
+}
+
+// parseBook extracts the metadata of a book from its Gutenberg header, detects its
+// language and chapters, then analyzes the body (without the license) with the analyzer of that language
+func parseBook(bookID int, filepath string, content string, analyzerFor func(language string) Analyzer) (parsedBook, error) {
+	text := SplitGutenberg(content)
+
+	meta := ExtractMetadata(headerOf(content, text))
+	language := bookLanguage(text.Body, meta.Language)
 
-	words := Tokenize(string(content))
-	if len(words) == 0 {
-		return fmt.Errorf("book %d has no valid words", bookID)
+	analyzer := analyzerFor(language)
+	tokens := analyzer.Analyze(text.Body)
+	if len(tokens) == 0 {
+		return parsedBook{}, fmt.Errorf("book %d has no valid words", bookID)
 	}
 
-	title := ExtractTitle(filepath)
-	author := ExtractAuthor(filepath)
+	book := models.Book{
+		ID:                  bookID,
+		Title:               meta.Title,
+		Subtitle:            meta.Subtitle,
+		Author:              meta.Author,
+		Language:            language,
+		Translator:          meta.Translator,
+		Illustrator:         meta.Illustrator,
+		Editor:              meta.Editor,
+		ReleaseDate:         meta.ReleaseDate,
+		OriginalPublication: meta.OriginalPublication,
+		Credits:             meta.Credits,
+		FilePath:            filepath,
+		WordCount:           CountWords(tokens),
+	}
 
-	idx.Books[bookID] = models.Book{
-		ID:        bookID,
-		Title:     title,
-		Author:    author,
-		FilePath:  filepath,
-		WordCount: len(words),
+	parsed := parsedBook{
+		book:      book,
+		wordCount: make(map[string]int),
+		surfaces:  make(map[string]map[string]bool),
+		fields:    fieldTerms(book, analyzer),
+		chapters:  DetectChapters(content, text),
 	}
 
-	wordCount := make(map[string]int)
-	for _, word := range words {
-		wordCount[word]++
+	for _, token := range tokens {
+		parsed.wordCount[token.Term]++
+
+		if parsed.surfaces[token.Term] == nil {
+			parsed.surfaces[token.Term] = make(map[string]bool)
+		}
+		parsed.surfaces[token.Term][token.Surface] = true
 	}
 
-	for word, count := range wordCount {
+	return parsed, nil
+}
+
+// replaceBook adds a book, removing its previous version first if it was indexed
+func (idx *Indexer) replaceBook(parsed parsedBook) {
+	if old, exists := idx.Books[parsed.book.ID]; exists {
+		keepCatalog(&parsed.book, old)
+		idx.RemoveBook(old.ID)
+		// the catalog's subjects and bookshelves are indexed fields too
+		parsed.fields = fieldTerms(parsed.book, idx.AnalyzerFor(parsed.book.Language))
+	}
+	idx.addBook(parsed)
+}
+
+// addBook stores a book and its word counts in the index
+func (idx *Indexer) addBook(parsed parsedBook) {
+	book := parsed.book
+	idx.Books[book.ID] = book
+	idx.resetVocabulary()
+
+	for word, count := range parsed.wordCount {
 		if idx.WordToBooks[word] == nil {
 			idx.WordToBooks[word] = make(map[int]int)
 		}
-		idx.WordToBooks[word][bookID] = count
+		idx.WordToBooks[word][book.ID] = count
 	}
 
-	idx.TotalWords += len(words)
-	return nil
+	idx.addFields(book.ID, parsed.fields)
+
+	if idx.Chapters == nil {
+		idx.Chapters = make(map[int][]models.Chapter)
+	}
+	idx.Chapters[book.ID] = parsed.chapters
+
+	for word, forms := range parsed.surfaces {
+		for form := range forms {
+			idx.addSurface(word, form)
+		}
+	}
+
+	idx.TotalWords += book.WordCount
+	idx.UniqueWords = len(idx.WordToBooks)
 }
 
-// BuildIndexFromDirectory scans all books in a directory
-func (idx *Indexer) BuildIndexFromDirectory(dir string) error {
-	files, err := filepath.Glob(filepath.Join(dir, "book_*.txt"))
-	if err != nil {
-		return err
+// addSurface records a surface form of a term, keeping the list sorted.
+// Forms are never removed one by one: a form can stay listed after the last book
+// using it was removed, until the term itself disappears from the index.
+func (idx *Indexer) addSurface(word, form string) {
+	if idx.Surfaces == nil {
+		idx.Surfaces = make(map[string][]string)
+	}
+	forms := idx.Surfaces[word]
+	i := sort.SearchStrings(forms, form)
+	if i < len(forms) && forms[i] == form {
+		return
+	}
+	forms = append(forms, "")
+	copy(forms[i+1:], forms[i:])
+	forms[i] = form
+	idx.Surfaces[word] = forms
+}
+
+// SurfaceForms returns the words of the books that produced a term
+func (idx *Indexer) SurfaceForms(term string) []string {
+	// Field terms share the surface forms of the same term in the text
+	_, term = SplitFieldTerm(term)
+	if forms, found := idx.Surfaces[term]; found {
+		return forms
 	}
+	// Indexes built without an analyzer store the words themselves
+	return []string{term}
+}
 
-	fmt.Printf("Found %d book files to index...\n", len(files))
+// MergeFrom copies the books and postings of another index into this one.
+// Books for which skip returns true are left out; books already present are replaced.
+func (idx *Indexer) MergeFrom(other *Indexer, skip func(bookID int) bool) {
+	if len(idx.Books) == 0 {
+		idx.Analyzer = other.Analyzer
+		idx.MinWordLength = other.MinWordLength
+		idx.FoldAccents = other.FoldAccents
+	}
 
-	for i, file := range files {
-		var bookID int
-		_, err := fmt.Sscanf(filepath.Base(file), "book_%d.txt", &bookID)
-		if err != nil {
-			fmt.Printf("Skipping invalid filename: %s\n", file)
+	for bookID := range other.Books {
+		if skip != nil && skip(bookID) {
 			continue
 		}
+		if _, exists := idx.Books[bookID]; exists {
+			idx.RemoveBook(bookID)
+		}
+	}
+
+	for word, books := range other.WordToBooks {
+		for bookID, count := range books {
+			if skip != nil && skip(bookID) {
+				continue
+			}
+			if idx.WordToBooks[word] == nil {
+				idx.WordToBooks[word] = make(map[int]int)
+			}
+			idx.WordToBooks[word][bookID] = count
+		}
+		if idx.WordToBooks[word] != nil {
+			for _, form := range other.Surfaces[word] {
+				idx.addSurface(word, form)
+			}
+		}
+	}
 
-		// Print a message every 100 books to show progress.
-		if (i+1)%100 == 0 || i == 0 {
-			fmt.Printf("Indexing book %d/%d (ID: %d)...\n", i+1, len(files), bookID)
+	if idx.Fields == nil {
+		idx.Fields = make(map[string]map[int]int)
+	}
+	for term, books := range other.Fields {
+		for bookID, count := range books {
+			if skip != nil && skip(bookID) {
+				continue
+			}
+			if idx.Fields[term] == nil {
+				idx.Fields[term] = make(map[int]int)
+			}
+			idx.Fields[term][bookID] = count
 		}
+	}
 
-		err = idx.IndexBook(bookID, file)
-		if err != nil {
-			fmt.Printf("Error indexing book %d: %v\n", bookID, err)
+	if idx.Chapters == nil {
+		idx.Chapters = make(map[int][]models.Chapter)
+	}
+	for bookID, book := range other.Books {
+		if skip != nil && skip(bookID) {
 			continue
 		}
+		idx.Books[bookID] = book
+		if chapters, found := other.Chapters[bookID]; found {
+			idx.Chapters[bookID] = chapters
+		}
+		idx.TotalWords += book.WordCount
 	}
 
 	idx.UniqueWords = len(idx.WordToBooks)
+	idx.resetVocabulary()
+}
 
-	fmt.Printf("\n Indexing complete!\n")
-	fmt.Printf("  Total books: %d\n", len(idx.Books))
-	fmt.Printf("  Total words: %d\n", idx.TotalWords)
-	fmt.Printf("  Unique words: %d\n", idx.UniqueWords)
-
-	return nil
+// BookIDFromPath parses the book ID out of a "book_<id>.txt" filename
+func BookIDFromPath(path string) (int, error) {
+	var bookID int
+	_, err := fmt.Sscanf(filepath.Base(path), "book_%d.txt", &bookID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid book filename %s: %w", path, err)
+	}
+	return bookID, nil
 }
 
 // GetStats returns index statistics
@@ -0,0 +1,224 @@
+package indexer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeBook writes a small Gutenberg-like book file and returns its path
+func writeBook(t *testing.T, dir string, id int, title, body string) string {
+	t.Helper()
+	content := fmt.Sprintf(`The Project Gutenberg eBook of %[1]s
+
+Title: %[1]s
+
+Author: Test Author %[2]d
+
+Language: English
+
+*** START OF THE PROJECT GUTENBERG EBOOK %[1]s ***
+
+CHAPTER I. The Beginning
+
+%[3]s
+
+*** END OF THE PROJECT GUTENBERG EBOOK %[1]s ***
+`, title, id, body)
+
+	path := filepath.Join(dir, fmt.Sprintf("book_%d.txt", id))
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// setModTime moves the mtime of a file, as an editor or a copy would
+func setModTime(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestIncrementalMatchesFullBuild indexes a directory, then adds, updates and removes
+// books and checks the index against one built from scratch out of the final files
+func TestIncrementalMatchesFullBuild(t *testing.T) {
+	dir := t.TempDir()
+	writeBook(t, dir, 1, "Whales", "The captain watched the whales swimming across the grey ocean.")
+	updated := writeBook(t, dir, 2, "Quixotic Voyage", "A quixotic sailor dreamed of islands and treasure.")
+	removed := writeBook(t, dir, 3, "Zanzibar", "Zanzibar merchants traded spices with sailors.")
+
+	idx := NewIndexer()
+	m := NewManifest()
+	stats, err := idx.UpdateFromDirectory(dir, m, BuildOptions{Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (IncrementalStats{Added: 3}); stats != want {
+		t.Fatalf("first run: got %+v, want %+v", stats, want)
+	}
+
+	writeBook(t, dir, 2, "Gentle Voyage", "A gentle sailor dreamed of harbours and treasure, watching whales.")
+	setModTime(t, updated, time.Now().Add(time.Hour))
+	if err := os.Remove(removed); err != nil {
+		t.Fatal(err)
+	}
+	writeBook(t, dir, 4, "Lighthouse", "The keeper of the lighthouse counted passing ships.")
+
+	stats, err = idx.UpdateFromDirectory(dir, m, BuildOptions{Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (IncrementalStats{Added: 1, Updated: 1, Removed: 1, Unchanged: 1}); stats != want {
+		t.Fatalf("second run: got %+v, want %+v", stats, want)
+	}
+
+	fresh := NewIndexer()
+	if err := fresh.BuildIndexFromDirectory(dir, BuildOptions{Workers: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(idx.Books, fresh.Books) {
+		t.Errorf("books differ:\n got %+v\nwant %+v", idx.Books, fresh.Books)
+	}
+	if !reflect.DeepEqual(idx.WordToBooks, fresh.WordToBooks) {
+		t.Errorf("postings differ:\n got %v\nwant %v", idx.WordToBooks, fresh.WordToBooks)
+	}
+	if !reflect.DeepEqual(idx.Fields, fresh.Fields) {
+		t.Errorf("fields differ:\n got %v\nwant %v", idx.Fields, fresh.Fields)
+	}
+	if !reflect.DeepEqual(idx.Chapters, fresh.Chapters) {
+		t.Errorf("chapters differ:\n got %v\nwant %v", idx.Chapters, fresh.Chapters)
+	}
+	if idx.TotalWords != fresh.TotalWords || idx.UniqueWords != fresh.UniqueWords {
+		t.Errorf("counters: got %d words, %d unique, want %d, %d",
+			idx.TotalWords, idx.UniqueWords, fresh.TotalWords, fresh.UniqueWords)
+	}
+
+	// Terms gone from every book lose their surface forms; the others keep at
+	// least the forms of a fresh build (see addSurface)
+	if got, want := sortedKeys(idx.Surfaces), sortedKeys(fresh.Surfaces); !reflect.DeepEqual(got, want) {
+		t.Errorf("surface terms differ:\n got %v\nwant %v", got, want)
+	}
+	for term, forms := range fresh.Surfaces {
+		for _, form := range forms {
+			if !contains(idx.Surfaces[term], form) {
+				t.Errorf("surface form %q of %q missing", form, term)
+			}
+		}
+	}
+	for _, word := range []string{"zanzibar", "quixot"} {
+		for term := range idx.WordToBooks {
+			if strings.HasPrefix(term, word) {
+				t.Errorf("term %q of a removed or updated text still indexed", term)
+			}
+		}
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRemoveBook(t *testing.T) {
+	dir := t.TempDir()
+	first := writeBook(t, dir, 1, "Whales", "The captain watched the whales swimming across the ocean.")
+	second := writeBook(t, dir, 2, "Islands", "Sailors found islands beyond the ocean.")
+
+	idx := NewIndexer()
+	for i, path := range []string{first, second} {
+		if err := idx.IndexBook(i+1, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !idx.RemoveBook(2) {
+		t.Fatal("RemoveBook(2) = false, want true")
+	}
+	if idx.RemoveBook(2) {
+		t.Error("removing a book twice should return false")
+	}
+	if err := idx.UpdateBook(2, second); err == nil {
+		t.Error("UpdateBook of a removed book should fail")
+	}
+
+	only := NewIndexer()
+	if err := only.IndexBook(1, first); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(idx.WordToBooks, only.WordToBooks) || !reflect.DeepEqual(idx.Fields, only.Fields) {
+		t.Error("postings after removal differ from an index of the remaining book")
+	}
+	if idx.TotalWords != only.TotalWords || idx.UniqueWords != only.UniqueWords {
+		t.Errorf("counters: got %d, %d, want %d, %d", idx.TotalWords, idx.UniqueWords, only.TotalWords, only.UniqueWords)
+	}
+	if _, found := idx.Chapters[2]; found {
+		t.Error("chapters of the removed book are still there")
+	}
+}
+
+func TestManifestChanges(t *testing.T) {
+	dir := t.TempDir()
+	touched := writeBook(t, dir, 1, "Whales", "The captain watched the whales.")
+	edited := writeBook(t, dir, 2, "Islands", "Sailors found islands.")
+	deleted := writeBook(t, dir, 3, "Harbour", "Ships waited in the harbour.")
+
+	idx := NewIndexer()
+	m := NewManifest()
+	if _, err := idx.UpdateFromDirectory(dir, m, BuildOptions{Workers: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{touched, edited, deleted} {
+		if entry := m.Files[path]; entry.Hash == "" || entry.Size == 0 {
+			t.Fatalf("manifest entry of %s not recorded: %+v", path, entry)
+		}
+	}
+
+	later := time.Now().Add(time.Hour)
+
+	// Same content with a new mtime: hashed, not reindexed, mtime remembered
+	setModTime(t, touched, later)
+	// Same size, different content
+	writeBook(t, dir, 2, "Islands", "Sailors found islanbs.")
+	setModTime(t, edited, later)
+	if err := os.Remove(deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, removed, unchanged, err := m.Changes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{edited}) {
+		t.Errorf("changed = %v, want [%s]", changed, edited)
+	}
+	if !reflect.DeepEqual(removed, []string{deleted}) {
+		t.Errorf("removed = %v, want [%s]", removed, deleted)
+	}
+	if unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", unchanged)
+	}
+	if !m.Files[touched].ModTime.Equal(later) {
+		t.Errorf("mtime of the touched file not updated: %v", m.Files[touched].ModTime)
+	}
+}
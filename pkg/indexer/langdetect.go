@@ -0,0 +1,198 @@
+package indexer
+
+import (
+	"bufio"
+	"embed"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Language identification with character n-gram profiles (Cavnar & Trenkle, 1994).
+// A text is summarized by its most frequent 1- to 3-grams in rank order, and its
+// language is the one whose profile ranks these n-grams closest ("out-of-place" distance).
+
+const (
+	profileMaxN = 3   // longest n-gram of a profile
+	profileSize = 300 // number of n-grams kept in a profile
+
+	// detectSample is how much of a book is looked at: its language doesn't change halfway
+	detectSample = 64 * 1024
+
+	// minDetectNGrams is the number of n-grams below which a text is too short to tell
+	minDetectNGrams = 100
+
+	// MinDetectConfidence is the confidence below which the header's Language field is trusted instead
+	MinDetectConfidence = 0.05
+)
+
+// Language profiles, one file per language code, generated with BuildProfile
+// from a few pages of text in each language
+//
+//go:embed langprofiles/*.txt
+var profileFiles embed.FS
+
+// Profile lists the most frequent n-grams of a text, most frequent first
+type Profile []string
+
+var languageProfiles = loadProfiles()
+
+func loadProfiles() map[string]map[string]int {
+	profiles := make(map[string]map[string]int)
+
+	entries, _ := profileFiles.ReadDir("langprofiles")
+	for _, entry := range entries {
+		file, err := profileFiles.Open("langprofiles/" + entry.Name())
+		if err != nil {
+			continue
+		}
+
+		var profile Profile
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				profile = append(profile, line)
+			}
+		}
+		file.Close()
+
+		profiles[strings.TrimSuffix(entry.Name(), ".txt")] = profile.ranks()
+	}
+	return profiles
+}
+
+// ranks maps each n-gram of the profile to its position
+func (p Profile) ranks() map[string]int {
+	ranks := make(map[string]int, len(p))
+	for i, gram := range p {
+		ranks[gram] = i
+	}
+	return ranks
+}
+
+// countNGrams counts the 1- to 3-grams of the words of a text. Words are padded
+// with "_" so that n-grams at the start and end of words are told apart.
+func countNGrams(text string) map[string]int {
+	counts := make(map[string]int)
+
+	var word []rune
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		padded := append(append([]rune{'_'}, word...), '_')
+		for n := 1; n <= profileMaxN; n++ {
+			for i := 0; i+n <= len(padded); i++ {
+				gram := string(padded[i : i+n])
+				if gram != "_" {
+					counts[gram]++
+				}
+			}
+		}
+		word = word[:0]
+	}
+
+	for _, r := range norm.NFKC.String(text) {
+		if unicode.IsLetter(r) {
+			word = append(word, unicode.ToLower(r))
+		} else {
+			flush()
+		}
+	}
+	flush()
+
+	return counts
+}
+
+// topNGrams returns the n-grams of a count map, most frequent first
+func topNGrams(counts map[string]int) Profile {
+	profile := make(Profile, 0, len(counts))
+	for gram := range counts {
+		profile = append(profile, gram)
+	}
+	sort.Slice(profile, func(i, j int) bool {
+		if counts[profile[i]] != counts[profile[j]] {
+			return counts[profile[i]] > counts[profile[j]]
+		}
+		return profile[i] < profile[j]
+	})
+
+	if len(profile) > profileSize {
+		profile = profile[:profileSize]
+	}
+	return profile
+}
+
+// BuildProfile computes the profile of a text, as stored in the langprofiles files
+func BuildProfile(text string) Profile {
+	return topNGrams(countNGrams(text))
+}
+
+// DetectLanguage returns the code of the language of a text, and a confidence
+// between 0 and 1 (how much closer the best language is than the second best).
+// It returns "" and 0 if the text is too short.
+func DetectLanguage(text string) (string, float64) {
+	counts := countNGrams(text)
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total < minDetectNGrams {
+		return "", 0
+	}
+
+	profile := topNGrams(counts)
+
+	best, bestDistance, secondDistance := "", -1, -1
+	for language, ranks := range languageProfiles {
+		distance := 0
+		for i, gram := range profile {
+			rank, found := ranks[gram]
+			switch {
+			case !found:
+				distance += profileSize
+			case rank > i:
+				distance += rank - i
+			default:
+				distance += i - rank
+			}
+		}
+
+		switch {
+		case bestDistance < 0 || distance < bestDistance || (distance == bestDistance && language < best):
+			secondDistance = bestDistance
+			best, bestDistance = language, distance
+		case secondDistance < 0 || distance < secondDistance:
+			secondDistance = distance
+		}
+	}
+
+	if best == "" || secondDistance <= 0 {
+		return best, 1
+	}
+	return best, float64(secondDistance-bestDistance) / float64(secondDistance)
+}
+
+// languageSample returns the middle of a book, away from the (English) Gutenberg
+// header and license, limited to detectSample bytes
+func languageSample(content string) string {
+	if len(content) <= detectSample {
+		return content
+	}
+	start := (len(content) - detectSample) / 2
+	return content[start : start+detectSample]
+}
+
+// bookLanguage decides the language of a book: detected from its text, or the
+// header's language when detection is unsure
+func bookLanguage(content, headerLanguage string) string {
+	detected, confidence := DetectLanguage(languageSample(content))
+	if detected != "" && confidence >= MinDetectConfidence {
+		return detected
+	}
+	return headerLanguage
+}
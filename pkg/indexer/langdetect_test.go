@@ -0,0 +1,88 @@
+package indexer
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Short passages of public domain books, one per language
+var languagePassages = map[string]string{
+	"en": "It is a truth universally acknowledged, that a single man in possession of a good " +
+		"fortune, must be in want of a wife. However little known the feelings or views of such " +
+		"a man may be on his first entering a neighbourhood, this truth is so well fixed in the " +
+		"minds of the surrounding families, that he is considered the rightful property of some " +
+		"one or other of their daughters.",
+	"fr": "En 1815, M. Charles-François-Bienvenu Myriel était évêque de Digne. C'était un " +
+		"vieillard d'environ soixante-quinze ans; il occupait le siège de Digne depuis 1806. " +
+		"Quoique ce détail ne touche en aucune manière au fond même de ce que nous avons à " +
+		"raconter, il n'est peut-être pas inutile, ne fût-ce que pour être exact en tout, " +
+		"d'indiquer ici les bruits et les propos qui avaient couru sur son compte.",
+	"de": "Als Gregor Samsa eines Morgens aus unruhigen Träumen erwachte, fand er sich in seinem " +
+		"Bett zu einem ungeheueren Ungeziefer verwandelt. Er lag auf seinem panzerartig harten " +
+		"Rücken und sah, wenn er den Kopf ein wenig hob, seinen gewölbten, braunen, von " +
+		"bogenförmigen Versteifungen geteilten Bauch, auf dessen Höhe sich die Bettdecke, zum " +
+		"gänzlichen Niedergleiten bereit, kaum noch erhalten konnte.",
+	"fi": "Jukolan talo, eteläisessä Hämeessä, seisoo erään mäen pohjoisella rinteellä, liki " +
+		"Toukolan kylää. Sen läheisin ympäristö on kivinen tanner, mutta alempana alkaa pellot, " +
+		"joissa, ennenkuin talo oli häviöön mennyt, aaltoili teräinen vilja. Peltojen alla on " +
+		"niittu, apilaäyräinen, halkileikkaama monipolvisen ojan.",
+}
+
+func TestDetectLanguage(t *testing.T) {
+	for want, passage := range languagePassages {
+		language, confidence := DetectLanguage(passage)
+		if language != want {
+			t.Errorf("DetectLanguage of the %s passage = %s (%.2f)", want, language, confidence)
+		}
+		if confidence < MinDetectConfidence {
+			t.Errorf("DetectLanguage of the %s passage is unsure: %.2f", want, confidence)
+		}
+	}
+
+	// Too short to tell
+	if language, confidence := DetectLanguage("Chapter one"); language != "" || confidence != 0 {
+		t.Errorf("DetectLanguage of two words = %q, %.2f; want nothing", language, confidence)
+	}
+}
+
+func TestBookLanguage(t *testing.T) {
+	tests := []struct {
+		name, text, header, want string
+	}{
+		{"header agrees", languagePassages["fr"], "fr", "fr"},
+		{"no header", languagePassages["de"], "", "de"},
+		// Gutenberg headers are sometimes wrong: the text wins
+		{"header wrong", languagePassages["fi"], "en", "fi"},
+		// Detection can't tell from a few words: the header wins
+		{"text too short", "Fin.", "fr", "fr"},
+		{"nothing to go on", "Fin.", "", ""},
+	}
+	for _, tt := range tests {
+		if got := bookLanguage(tt.text, tt.header); got != tt.want {
+			t.Errorf("%s: bookLanguage = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIndexedLanguage(t *testing.T) {
+	dir := t.TempDir()
+	// The header says English, the book is in French
+	path := writeBook(t, dir, 1, "Les Misérables", languagePassages["fr"])
+
+	idx := NewIndexer()
+	if err := idx.IndexBook(1, path); err != nil {
+		t.Fatal(err)
+	}
+	if got := idx.Books[1].Language; got != "fr" {
+		t.Errorf("language of %s = %q, want fr", filepath.Base(path), got)
+	}
+	// Its words go through the French analyzer: stop words are French ones
+	for _, word := range []string{"nous", "avons", "pour"} {
+		if _, found := idx.WordToBooks[word]; found {
+			t.Errorf("the French stop word %s was indexed", word)
+		}
+	}
+	if _, found := idx.WordToBooks["vieillard"]; !found {
+		t.Error("vieillard was not indexed")
+	}
+}
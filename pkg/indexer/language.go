@@ -0,0 +1,90 @@
+package indexer
+
+import (
+	"sort"
+	"strings"
+)
+
+// Languages maps the language codes stored on books to analyzer names
+var Languages = map[string]string{
+	"en": "english",
+	"fr": "french",
+	"de": "german",
+	"fi": "finnish",
+	"es": "spanish",
+	"it": "italian",
+	"nl": "dutch",
+	"pt": "portuguese",
+	"zh": "chinese",
+}
+
+// LanguageCode returns the code of a language name ("French" -> "fr"), or "" if unknown
+func LanguageCode(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, isCode := Languages[name]; isCode {
+		return name
+	}
+	for code, language := range Languages {
+		if language == name {
+			return code
+		}
+	}
+	return ""
+}
+
+// AnalyzerFor returns the analyzer used for books of a language.
+// Books of unknown language use the index's default analyzer.
+func (idx *Indexer) AnalyzerFor(language string) Analyzer {
+	if idx.Analyzer == "" || idx.Analyzer == SimpleAnalyzer.Name() {
+		return SimpleAnalyzer
+	}
+
+	name, found := Languages[language]
+	if !found {
+		name = idx.Analyzer
+	}
+	return NewLanguageAnalyzer(name, AnalyzerOptions{
+		MinLength:   idx.MinWordLength,
+		FoldAccents: idx.FoldAccents,
+	})
+}
+
+// Languages returns the language codes of the indexed books ("" for unknown)
+func (idx *Indexer) Languages() []string {
+	seen := make(map[string]bool)
+	for _, book := range idx.Books {
+		seen[book.Language] = true
+	}
+
+	languages := make([]string, 0, len(seen))
+	for language := range seen {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// QueryTerms analyzes a query the same way the books were analyzed.
+// Since each language has its own analyzer, the query is analyzed once per
+// language of the index and the terms are combined.
+func (idx *Indexer) QueryTerms(query string) []string {
+	seen := make(map[string]bool)
+	terms := []string{}
+
+	analyzed := make(map[string]bool)
+	for _, language := range append([]string{""}, idx.Languages()...) {
+		analyzer := idx.AnalyzerFor(language)
+		if analyzed[analyzer.Name()] {
+			continue
+		}
+		analyzed[analyzer.Name()] = true
+
+		for _, term := range AnalyzeTerms(analyzer, query) {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
@@ -0,0 +1,195 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestEntry describes a book file as it was when it was last indexed
+type ManifestEntry struct {
+	BookID  int       `json:"book_id"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash"`
+}
+
+// Manifest keeps track of indexed files so that the next run
+// only has to reindex the files that changed
+type Manifest struct {
+	Files map[string]ManifestEntry `json:"files"`
+}
+
+// IncrementalStats summarizes what an incremental run did
+type IncrementalStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// NewManifest creates an empty manifest
+func NewManifest() *Manifest {
+	return &Manifest{Files: make(map[string]ManifestEntry)}
+}
+
+// LoadManifest reads a manifest from disk.
+// A missing file is not an error: it just means nothing was indexed yet.
+func LoadManifest(filename string) (*Manifest, error) {
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewManifest(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer file.Close()
+
+	m := NewManifest()
+	if err := json.NewDecoder(file).Decode(m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]ManifestEntry)
+	}
+	return m, nil
+}
+
+// SaveToFile writes the manifest to disk
+func (m *Manifest) SaveToFile(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+// changed reports whether a file differs from its manifest entry.
+// The mtime and size are checked first; the file is only hashed when they differ,
+// so a `touch` doesn't trigger a reindex.
+func (m *Manifest) changed(path string, info os.FileInfo) (bool, error) {
+	entry, found := m.Files[path]
+	if !found {
+		return true, nil
+	}
+	if entry.ModTime.Equal(info.ModTime()) && entry.Size == info.Size() {
+		return false, nil
+	}
+
+	hash, err := hashFile(path)
+	if err != nil {
+		return false, err
+	}
+	if hash != entry.Hash {
+		return true, nil
+	}
+
+	// Same content, only the mtime moved: remember it to skip the hash next time
+	entry.ModTime = info.ModTime()
+	entry.Size = info.Size()
+	m.Files[path] = entry
+	return false, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Changes compares a directory with the manifest. It returns the files that are
+// new or modified, and the manifest paths whose file no longer exists.
+func (m *Manifest) Changes(dir string) (changed, removed []string, unchanged int, err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "book_*.txt"))
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	seen := make(map[string]bool)
+	for _, file := range files {
+		seen[file] = true
+
+		info, err := os.Stat(file)
+		if err != nil {
+			continue // deleted since the glob, picked up by the next run
+		}
+
+		isChanged, err := m.changed(file, info)
+		if err != nil || isChanged {
+			// Let the indexer read it and report any error
+			changed = append(changed, file)
+			continue
+		}
+		unchanged++
+	}
+
+	for path := range m.Files {
+		if !seen[path] {
+			removed = append(removed, path)
+		}
+	}
+
+	return changed, removed, unchanged, nil
+}
+
+// UpdateFromDirectory reindexes only the books of a directory that were added,
+// modified or deleted since the manifest was written, and updates the manifest.
+// Unchanged files are not reported to opts.Progress.
+func (idx *Indexer) UpdateFromDirectory(dir string, m *Manifest, opts BuildOptions) (IncrementalStats, error) {
+	var stats IncrementalStats
+
+	changedFiles, removed, unchanged, err := m.Changes(dir)
+	if err != nil {
+		return stats, err
+	}
+	stats.Unchanged = unchanged
+
+	// Files that disappeared from the directory are removed from the index
+	for _, path := range removed {
+		if idx.RemoveBook(m.Files[path].BookID) {
+			stats.Removed++
+		}
+		delete(m.Files, path)
+	}
+
+	existed := make(map[int]bool)
+	for bookID := range idx.Books {
+		existed[bookID] = true
+	}
+
+	progress := opts.Progress
+	opts.Manifest = m
+	opts.Progress = func(p Progress) {
+		if p.Err == nil {
+			if existed[p.BookID] {
+				stats.Updated++
+			} else {
+				stats.Added++
+			}
+		}
+		if progress != nil {
+			progress(p)
+		}
+	}
+	idx.IndexFiles(changedFiles, opts)
+
+	return stats, nil
+}
@@ -3,37 +3,158 @@ package indexer
 import (
 	"bufio"
 	"os"
+	"regexp"
 	"strings"
 )
 
+// metadataLines is how many lines are scanned for metadata in files without a Gutenberg header
+const metadataLines = 30
+
 // ExtractTitle tries to extract book title from first 30 lines
 func ExtractTitle(filepath string) string {
-	file, err := os.Open(filepath)
-	if err != nil {
-		return "Unknown"
-	}
-	defer file.Close()
+	return extractFieldFromFile(filepath, "Title:")
+}
 
-	scanner := bufio.NewScanner(file)
-	lineCount := 0
+// ExtractAuthor tries to extract author from first 30 lines
+func ExtractAuthor(filepath string) string {
+	return extractFieldFromFile(filepath, "Author:")
+}
 
-	for scanner.Scan() && lineCount < 30 {
-		line := scanner.Text()
-		lineCount++
+// Metadata is what the Gutenberg header says about a book
+type Metadata struct {
+	Title    string
+	Subtitle string
+	Author   string
+	// Language is the ISO code of the "Language:" field ("" if unknown)
+	Language            string
+	Translator          string
+	Illustrator         string
+	Editor              string
+	ReleaseDate         string
+	OriginalPublication string
+	Credits             string
+}
+
+// Header labels we read, lowercased. Older headers say "Posting Date" for the release date.
+var headerLabels = map[string]bool{
+	"title": true, "author": true, "language": true, "translator": true,
+	"illustrator": true, "editor": true, "release date": true, "posting date": true,
+	"original publication": true, "credits": true,
+}
+
+// headerLine matches a "Label: value" line of the header
+var headerLine = regexp.MustCompile(`^([A-Za-z][A-Za-z ]{0,30}):\s*(.*)$`)
+
+// ebookNumber matches the "[eBook #1661]" after the release date
+var ebookNumber = regexp.MustCompile(`(?i)\s*\[e-?book #\d+\]`)
+
+// ExtractMetadata parses the header of a book (see SplitGutenberg).
+// A field may continue on the following indented lines, as long titles and credits do.
+func ExtractMetadata(header string) Metadata {
+	fields := make(map[string][]string)
 
-		if strings.HasPrefix(line, "Title:") {
-			title := strings.TrimSpace(strings.TrimPrefix(line, "Title:"))
-			if title != "" {
-				return title
+	current := ""
+	for _, line := range strings.Split(header, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			current = ""
+		case line[0] == ' ' || line[0] == '\t':
+			// continuation of the previous field
+			if current != "" {
+				values := fields[current]
+				values[len(values)-1] += "\n" + trimmed
+			}
+		default:
+			current = ""
+			m := headerLine.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			label := strings.ToLower(strings.TrimSpace(m[1]))
+			if !headerLabels[label] {
+				continue
 			}
+			current = label
+			fields[label] = append(fields[label], strings.TrimSpace(m[2]))
 		}
 	}
 
-	return "Unknown"
+	meta := Metadata{Title: "Unknown", Author: "Unknown"}
+
+	if titles := fields["title"]; len(titles) > 0 && titles[0] != "" {
+		meta.Title, meta.Subtitle = splitTitle(titles[0])
+	}
+	if author := joinValues(fields["author"]); author != "" {
+		meta.Author = author
+	}
+	meta.Language = LanguageCode(firstLine(fields["language"]))
+	meta.Translator = joinValues(fields["translator"])
+	meta.Illustrator = joinValues(fields["illustrator"])
+	meta.Editor = joinValues(fields["editor"])
+	meta.OriginalPublication = joinValues(fields["original publication"])
+	meta.Credits = joinValues(fields["credits"])
+
+	// "March 1, 1999 [eBook #1661]" followed by a "Most recently updated" line
+	date := firstLine(fields["release date"])
+	if date == "" {
+		date = firstLine(fields["posting date"])
+	}
+	meta.ReleaseDate = strings.TrimSpace(ebookNumber.ReplaceAllString(date, ""))
+
+	return meta
 }
 
-// ExtractAuthor tries to extract author from first 30 lines
-func ExtractAuthor(filepath string) string {
+// splitTitle separates a title from its subtitle: the subtitle is either on the
+// following lines, or after "; or," as in "Moby Dick; Or, The Whale"
+func splitTitle(value string) (title, subtitle string) {
+	lines := strings.SplitN(value, "\n", 2)
+	if len(lines) == 2 {
+		return lines[0], strings.Join(strings.Fields(lines[1]), " ")
+	}
+
+	if i := strings.Index(strings.ToLower(value), "; or, "); i > 0 {
+		return value[:i], value[i+len("; or, "):]
+	}
+	return value, ""
+}
+
+// joinValues joins the values of a field given on several lines or several times
+func joinValues(values []string) string {
+	var parts []string
+	for _, v := range values {
+		if v = strings.Join(strings.Fields(v), " "); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// firstLine returns the first line of the first value of a field,
+// for fields where the following lines are not part of the value
+func firstLine(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.SplitN(values[0], "\n", 2)[0]
+}
+
+// headerOf returns the part of a book where metadata is looked for:
+// the Gutenberg header if there is one, the first lines otherwise
+func headerOf(content string, text GutenbergText) string {
+	if text.Header != "" {
+		return text.Header
+	}
+	lines := strings.SplitN(content, "\n", metadataLines+1)
+	if len(lines) > metadataLines {
+		lines = lines[:metadataLines]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func extractFieldFromFile(filepath, prefix string) string {
 	file, err := os.Open(filepath)
 	if err != nil {
 		return "Unknown"
@@ -43,17 +164,23 @@ func ExtractAuthor(filepath string) string {
 	scanner := bufio.NewScanner(file)
 	lineCount := 0
 
-	for scanner.Scan() && lineCount < 30 {
+	for scanner.Scan() && lineCount < metadataLines {
 		line := scanner.Text()
 		lineCount++
 
-		if strings.HasPrefix(line, "Author:") {
-			author := strings.TrimSpace(strings.TrimPrefix(line, "Author:"))
-			if author != "" {
-				return author
-			}
+		if value, ok := fieldValue(line, prefix); ok {
+			return value
 		}
 	}
 
 	return "Unknown"
 }
+
+// fieldValue returns the value of a "Prefix: value" header line
+func fieldValue(line, prefix string) (string, bool) {
+	if !strings.HasPrefix(line, prefix) {
+		return "", false
+	}
+	value := strings.TrimSpace(strings.TrimPrefix(line, prefix))
+	return value, value != ""
+}
@@ -0,0 +1,85 @@
+package indexer
+
+import "testing"
+
+func TestExtractMetadata(t *testing.T) {
+	header := "The Project Gutenberg eBook of Frankenstein\r\n" +
+		"\r\n" +
+		"This ebook is for the use of anyone anywhere in the United States.\r\n" +
+		"\r\n" +
+		"Title: Frankenstein\r\n" +
+		"       or, The Modern Prometheus\r\n" +
+		"\r\n" +
+		"Author: Mary Wollstonecraft Shelley\r\n" +
+		"\r\n" +
+		"Release date: October 1, 1993 [eBook #84]\r\n" +
+		"                Most recently updated: August 26, 2023\r\n" +
+		"\r\n" +
+		"Language: English\r\n" +
+		"\r\n" +
+		"Credits: Judith Boss, Christy Phillips, Lynn Hanninen\r\n" +
+		"         and David Meltzer\r\n" +
+		"\r\n" +
+		"Translator: Nobody\r\n" +
+		"Translator: Somebody Else\r\n"
+
+	want := Metadata{
+		Title:       "Frankenstein",
+		Subtitle:    "or, The Modern Prometheus",
+		Author:      "Mary Wollstonecraft Shelley",
+		Language:    "en",
+		Translator:  "Nobody; Somebody Else",
+		ReleaseDate: "October 1, 1993",
+		Credits:     "Judith Boss, Christy Phillips, Lynn Hanninen and David Meltzer",
+	}
+	if got := ExtractMetadata(header); got != want {
+		t.Errorf("ExtractMetadata =\n%+v, want\n%+v", got, want)
+	}
+}
+
+func TestExtractMetadataTitles(t *testing.T) {
+	tests := []struct {
+		header, title, subtitle string
+	}{
+		{"Title: Moby Dick; Or, The Whale\n", "Moby Dick", "The Whale"},
+		{"Title: Pride and Prejudice\n", "Pride and Prejudice", ""},
+		// A continuation ends at a blank line or at the next field
+		{"Title: The Iliad\n\n    of Homer\n", "The Iliad", ""},
+		{"Title: Walden\n  and On The Duty\n  of Civil Disobedience\nAuthor: Thoreau\n", "Walden", "and On The Duty of Civil Disobedience"},
+		{"Posting Date: May 2008\nTitle:\n", "Unknown", ""},
+		{"", "Unknown", ""},
+	}
+	for _, tt := range tests {
+		meta := ExtractMetadata(tt.header)
+		if meta.Title != tt.title || meta.Subtitle != tt.subtitle {
+			t.Errorf("ExtractMetadata(%q) title %q, subtitle %q, want %q, %q", tt.header, meta.Title, meta.Subtitle, tt.title, tt.subtitle)
+		}
+	}
+}
+
+func TestExtractMetadataOldHeader(t *testing.T) {
+	header := "The Project Gutenberg EBook of Emma, by Jane Austen\n" +
+		"\n" +
+		"Title: Emma\n" +
+		"\n" +
+		"Author: Jane Austen\n" +
+		"\n" +
+		"Posting Date: August 4, 2008 [EBook #158]\n" +
+		"Release Date: January, 1994\n" +
+		"\n" +
+		"Language: French\n"
+
+	meta := ExtractMetadata(header)
+	if meta.ReleaseDate != "January, 1994" {
+		t.Errorf("release date %q, want the Release Date over the Posting Date", meta.ReleaseDate)
+	}
+	if meta.Author != "Jane Austen" || meta.Language != "fr" {
+		t.Errorf("author %q, language %q", meta.Author, meta.Language)
+	}
+
+	// Without a release date, the posting date is used
+	meta = ExtractMetadata("Title: Emma\nPosting Date: August 4, 2008 [EBook #158]\n")
+	if meta.ReleaseDate != "August 4, 2008" {
+		t.Errorf("release date %q from the posting date", meta.ReleaseDate)
+	}
+}
@@ -0,0 +1,170 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// Progress describes one processed file of an indexing run
+type Progress struct {
+	Done   int    // files processed so far, including this one
+	Total  int    // files to process in this run
+	BookID int    // 0 if the filename could not be parsed
+	Path   string // file that was processed
+	Err    error  // non-nil if the file was skipped
+}
+
+// ProgressFunc is called once per processed file.
+// Calls always come from a single goroutine, so it doesn't need to be thread safe.
+type ProgressFunc func(p Progress)
+
+// BuildOptions configures the indexing pipeline
+type BuildOptions struct {
+	// Workers is the number of files read and tokenized in parallel (default: number of CPUs)
+	Workers int
+	// Progress is notified after each file (optional)
+	Progress ProgressFunc
+	// Manifest, if set, records every indexed file for later incremental runs
+	Manifest *Manifest
+	// Include, if set, restricts indexing to the books it returns true for
+	// (used to build one shard out of the whole library)
+	Include func(bookID int) bool
+}
+
+// indexedFile is what a worker hands over to the writer
+type indexedFile struct {
+	parsedBook
+	path string
+	info os.FileInfo
+	hash string
+	err  error
+}
+
+// BuildIndexFromDirectory scans all books in a directory
+func (idx *Indexer) BuildIndexFromDirectory(dir string, opts BuildOptions) error {
+	files, err := filepath.Glob(filepath.Join(dir, "book_*.txt"))
+	if err != nil {
+		return err
+	}
+
+	idx.IndexFiles(files, opts)
+	return nil
+}
+
+// IndexFiles indexes a list of book files with a pool of workers.
+// Each worker reads a file once, tokenizes it and extracts its metadata;
+// the calling goroutine is the only one writing to the index.
+// Files that fail are reported through opts.Progress and skipped.
+func (idx *Indexer) IndexFiles(files []string, opts BuildOptions) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if opts.Include != nil {
+		var included []string
+		for _, path := range files {
+			bookID, err := BookIDFromPath(path)
+			if err != nil || opts.Include(bookID) {
+				included = append(included, path)
+			}
+		}
+		files = included
+	}
+
+	analyzerFor := idx.AnalyzerFor
+
+	paths := make(chan string)
+	parsed := make(chan indexedFile, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				parsed <- indexFile(path, analyzerFor, opts.Manifest != nil)
+			}
+		}()
+	}
+
+	go func() {
+		for _, path := range files {
+			paths <- path
+		}
+		close(paths)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(parsed)
+	}()
+
+	done := 0
+	for f := range parsed {
+		done++
+		if f.err == nil {
+			idx.replaceBook(f.parsedBook)
+
+			if opts.Manifest != nil {
+				opts.Manifest.Files[f.path] = ManifestEntry{
+					BookID:  f.book.ID,
+					ModTime: f.info.ModTime(),
+					Size:    f.info.Size(),
+					Hash:    f.hash,
+				}
+			}
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(Progress{
+				Done:   done,
+				Total:  len(files),
+				BookID: f.book.ID,
+				Path:   f.path,
+				Err:    f.err,
+			})
+		}
+	}
+}
+
+// indexFile does all the per-file work that can run in parallel
+func indexFile(path string, analyzerFor func(language string) Analyzer, withHash bool) indexedFile {
+	f := indexedFile{path: path}
+
+	bookID, err := BookIDFromPath(path)
+	if err != nil {
+		f.err = err
+		return f
+	}
+	f.book.ID = bookID
+
+	// Stat before reading: if the file changes while we read it,
+	// the next incremental run sees a newer mtime and checks it again
+	if withHash {
+		f.info, err = os.Stat(path)
+		if err != nil {
+			f.err = fmt.Errorf("failed to read book %d: %w", bookID, err)
+			return f
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		f.err = fmt.Errorf("failed to read book %d: %w", bookID, err)
+		return f
+	}
+
+	f.parsedBook, f.err = parseBook(bookID, path, string(content), analyzerFor)
+	f.book.ID = bookID
+	if f.err == nil && withHash {
+		sum := sha256.Sum256(content)
+		f.hash = hex.EncodeToString(sum[:])
+	}
+	return f
+}
@@ -0,0 +1,72 @@
+package indexer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestWorkersMatchSequentialBuild indexes the same books with one worker and with
+// several, and checks that the order files are handled in doesn't show in the index
+func TestWorkersMatchSequentialBuild(t *testing.T) {
+	dir := t.TempDir()
+	words := []string{"whale", "whales", "whaling", "ship", "shipping", "sea", "captain", "harbour", "sailor", "sailors"}
+	for id := 1; id <= 40; id++ {
+		body := ""
+		for i := 0; i < 30; i++ {
+			body += words[(id*7+i*3)%len(words)] + " "
+		}
+		writeBook(t, dir, id, fmt.Sprintf("Voyage %d", id), body)
+	}
+	// A file that fails is skipped by both builds
+	if err := os.WriteFile(filepath.Join(dir, "book_x.txt"), []byte("no id"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sequential := NewIndexer()
+	if err := sequential.BuildIndexFromDirectory(dir, BuildOptions{Workers: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 5; run++ {
+		var failed, done int
+		parallel := NewIndexer()
+		err := parallel.BuildIndexFromDirectory(dir, BuildOptions{Workers: 4, Progress: func(p Progress) {
+			done++
+			if p.Done != done || p.Total != 41 {
+				t.Errorf("progress %d/%d after %d files", p.Done, p.Total, done)
+			}
+			if p.Err != nil {
+				failed++
+			}
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done != 41 || failed != 1 {
+			t.Errorf("run %d: %d files done, %d failed, want 41 and 1", run, done, failed)
+		}
+
+		if !reflect.DeepEqual(parallel.Books, sequential.Books) {
+			t.Errorf("run %d: books differ", run)
+		}
+		if !reflect.DeepEqual(parallel.WordToBooks, sequential.WordToBooks) {
+			t.Errorf("run %d: postings differ", run)
+		}
+		if !reflect.DeepEqual(parallel.Fields, sequential.Fields) {
+			t.Errorf("run %d: fields differ", run)
+		}
+		if !reflect.DeepEqual(parallel.Chapters, sequential.Chapters) {
+			t.Errorf("run %d: chapters differ", run)
+		}
+		if parallel.TotalWords != sequential.TotalWords || parallel.UniqueWords != sequential.UniqueWords {
+			t.Errorf("run %d: %d words, %d unique, want %d, %d", run,
+				parallel.TotalWords, parallel.UniqueWords, sequential.TotalWords, sequential.UniqueWords)
+		}
+		if !reflect.DeepEqual(parallel.Surfaces, sequential.Surfaces) {
+			t.Errorf("run %d: surface forms differ:\n got %v\nwant %v", run, parallel.Surfaces, sequential.Surfaces)
+		}
+	}
+}
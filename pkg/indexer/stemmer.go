@@ -0,0 +1,305 @@
+package indexer
+
+import "strings"
+
+// Porter2 ("English") stemmer, following the Snowball description:
+// https://snowballstem.org/algorithms/english/stemmer.html
+//
+// The word must already be lowercased. Words with non-ASCII letters are
+// returned unchanged, the rules only make sense for English.
+
+// Words that the algorithm would get wrong
+var stemExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli",
+	"singly": "singl", "sky": "sky", "news": "news", "howe": "howe",
+	"atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// Words left alone after step 1a
+var stemExceptions1a = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+// Stem returns the Porter2 stem of a lowercase English word
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] >= 0x80 {
+			return word
+		}
+	}
+	if stem, found := stemExceptions[word]; found {
+		return stem
+	}
+
+	w := []byte(word)
+
+	// Mark consonant y's as Y so that they are not treated as vowels
+	if w[0] == 'y' {
+		w[0] = 'Y'
+	}
+	for i := 1; i < len(w); i++ {
+		if w[i] == 'y' && isVowel(w[i-1]) {
+			w[i] = 'Y'
+		}
+	}
+
+	r1, r2 := stemRegions(w)
+
+	w = stemStep1a(w)
+	if stemExceptions1a[string(w)] {
+		return string(w)
+	}
+	w = stemStep1b(w, r1)
+	w = stemStep1c(w)
+	w = stemStep2(w, r1)
+	w = stemStep3(w, r1, r2)
+	w = stemStep4(w, r2)
+	w = stemStep5(w, r1, r2)
+
+	return strings.ReplaceAll(string(w), "Y", "y")
+}
+
+func isVowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+func isDouble(w []byte) bool {
+	if len(w) < 2 || w[len(w)-1] != w[len(w)-2] {
+		return false
+	}
+	switch w[len(w)-1] {
+	case 'b', 'd', 'f', 'g', 'm', 'n', 'p', 'r', 't':
+		return true
+	}
+	return false
+}
+
+func isValidLiEnding(c byte) bool {
+	switch c {
+	case 'c', 'd', 'e', 'g', 'h', 'k', 'm', 'n', 'r', 't':
+		return true
+	}
+	return false
+}
+
+// stemRegions returns the start of R1 and R2.
+// R1 is the region after the first non-vowel following a vowel, R2 is the same inside R1.
+func stemRegions(w []byte) (int, int) {
+	r1 := len(w)
+	s := string(w)
+	switch {
+	case strings.HasPrefix(s, "gener"), strings.HasPrefix(s, "arsen"):
+		r1 = 5
+	case strings.HasPrefix(s, "commun"):
+		r1 = 6
+	default:
+		r1 = regionAfter(w, 0)
+	}
+	return r1, regionAfter(w, r1)
+}
+
+func regionAfter(w []byte, start int) int {
+	for i := start + 1; i < len(w); i++ {
+		if !isVowel(w[i]) && isVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// endsWithShortSyllable: a vowel followed by a non-vowel other than w, x or Y,
+// preceded by a non-vowel; or a vowel then a non-vowel at the start of the word
+func endsWithShortSyllable(w []byte) bool {
+	n := len(w)
+	if n == 2 {
+		return isVowel(w[0]) && !isVowel(w[1])
+	}
+	if n < 3 {
+		return false
+	}
+	c := w[n-1]
+	return !isVowel(w[n-3]) && isVowel(w[n-2]) && !isVowel(c) && c != 'w' && c != 'x' && c != 'Y'
+}
+
+func isShortWord(w []byte, r1 int) bool {
+	return r1 >= len(w) && endsWithShortSyllable(w)
+}
+
+func hasSuffix(w []byte, suffix string) bool {
+	return strings.HasSuffix(string(w), suffix)
+}
+
+func containsVowel(w []byte) bool {
+	for _, c := range w {
+		if isVowel(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// longestSuffix returns the longest of the suffixes the word ends with, or ""
+func longestSuffix(w []byte, suffixes []string) string {
+	best := ""
+	for _, s := range suffixes {
+		if len(s) > len(best) && hasSuffix(w, s) {
+			best = s
+		}
+	}
+	return best
+}
+
+func stemStep1a(w []byte) []byte {
+	// Step 0 removes apostrophes; the tokenizer never keeps them so there is nothing to do
+	switch {
+	case hasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case hasSuffix(w, "ied"), hasSuffix(w, "ies"):
+		if len(w) > 4 {
+			return append(w[:len(w)-3], 'i')
+		}
+		return append(w[:len(w)-3], 'i', 'e')
+	case hasSuffix(w, "us"), hasSuffix(w, "ss"):
+		return w
+	case hasSuffix(w, "s"):
+		// Delete if the part before the s has a vowel that is not just before it
+		if len(w) >= 3 && containsVowel(w[:len(w)-2]) {
+			return w[:len(w)-1]
+		}
+	}
+	return w
+}
+
+func stemStep1b(w []byte, r1 int) []byte {
+	suffix := longestSuffix(w, []string{"eed", "eedly", "ed", "edly", "ing", "ingly"})
+	switch suffix {
+	case "":
+		return w
+	case "eed", "eedly":
+		if len(w)-len(suffix) >= r1 {
+			return append(w[:len(w)-len(suffix)], 'e', 'e')
+		}
+		return w
+	}
+
+	stem := w[:len(w)-len(suffix)]
+	if !containsVowel(stem) {
+		return w
+	}
+
+	switch {
+	case hasSuffix(stem, "at"), hasSuffix(stem, "bl"), hasSuffix(stem, "iz"):
+		return append(stem, 'e')
+	case isDouble(stem):
+		return stem[:len(stem)-1]
+	case isShortWord(stem, r1):
+		return append(stem, 'e')
+	}
+	return stem
+}
+
+func stemStep1c(w []byte) []byte {
+	n := len(w)
+	if n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+	return w
+}
+
+var stemStep2Rules = map[string]string{
+	"tional": "tion", "enci": "ence", "anci": "ance", "abli": "able", "entli": "ent",
+	"izer": "ize", "ization": "ize", "ational": "ate", "ation": "ate", "ator": "ate",
+	"alism": "al", "aliti": "al", "alli": "al", "fulness": "ful", "ousli": "ous",
+	"ousness": "ous", "iveness": "ive", "iviti": "ive", "biliti": "ble", "bli": "ble",
+	"ogi": "og", "fulli": "ful", "lessli": "less", "li": "",
+}
+
+var stemStep2Suffixes = mapKeys(stemStep2Rules)
+
+func stemStep2(w []byte, r1 int) []byte {
+	suffix := longestSuffix(w, stemStep2Suffixes)
+	if suffix == "" || len(w)-len(suffix) < r1 {
+		return w
+	}
+
+	stem := w[:len(w)-len(suffix)]
+	switch suffix {
+	case "ogi":
+		if !hasSuffix(stem, "l") {
+			return w
+		}
+	case "li":
+		if len(stem) == 0 || !isValidLiEnding(stem[len(stem)-1]) {
+			return w
+		}
+	}
+	return append(stem, stemStep2Rules[suffix]...)
+}
+
+var stemStep3Rules = map[string]string{
+	"tional": "tion", "ational": "ate", "alize": "al", "icate": "ic", "iciti": "ic",
+	"ical": "ic", "ful": "", "ness": "", "ative": "",
+}
+
+var stemStep3Suffixes = mapKeys(stemStep3Rules)
+
+func stemStep3(w []byte, r1, r2 int) []byte {
+	suffix := longestSuffix(w, stemStep3Suffixes)
+	if suffix == "" || len(w)-len(suffix) < r1 {
+		return w
+	}
+	if suffix == "ative" && len(w)-len(suffix) < r2 {
+		return w
+	}
+	return append(w[:len(w)-len(suffix)], stemStep3Rules[suffix]...)
+}
+
+var stemStep4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion",
+}
+
+func stemStep4(w []byte, r2 int) []byte {
+	suffix := longestSuffix(w, stemStep4Suffixes)
+	if suffix == "" || len(w)-len(suffix) < r2 {
+		return w
+	}
+
+	stem := w[:len(w)-len(suffix)]
+	if suffix == "ion" && !hasSuffix(stem, "s") && !hasSuffix(stem, "t") {
+		return w
+	}
+	return stem
+}
+
+func stemStep5(w []byte, r1, r2 int) []byte {
+	n := len(w)
+	switch {
+	case hasSuffix(w, "e"):
+		if n-1 >= r2 || (n-1 >= r1 && !endsWithShortSyllable(w[:n-1])) {
+			return w[:n-1]
+		}
+	case hasSuffix(w, "l"):
+		if n-1 >= r2 && hasSuffix(w[:n-1], "l") {
+			return w[:n-1]
+		}
+	}
+	return w
+}
+
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,65 @@
+package indexer
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	// From the sample vocabulary of the Porter2 description, with its output
+	tests := map[string]string{
+		"consign": "consign", "consigned": "consign", "consigning": "consign", "consignment": "consign",
+		"consist": "consist", "consisted": "consist", "consistency": "consist", "consistent": "consist",
+		"consistently": "consist", "consisting": "consist", "consists": "consist",
+		"consolation": "consol", "consolations": "consol", "consolatory": "consolatori",
+		"console": "consol", "consoled": "consol", "consoles": "consol", "consolidate": "consolid",
+		"consolidated": "consolid", "consolidating": "consolid", "consoling": "consol",
+		"consolingly": "consol", "consols": "consol", "consonant": "conson",
+		"consort": "consort", "consorted": "consort", "consorting": "consort",
+		"conspicuous": "conspicu", "conspicuously": "conspicu", "conspiracy": "conspiraci",
+		"conspirator": "conspir", "conspirators": "conspir", "conspire": "conspir",
+		"conspired": "conspir", "conspiring": "conspir", "constable": "constabl",
+		"constables": "constabl", "constance": "constanc", "constancy": "constanc", "constant": "constant",
+		"knack": "knack", "knackeries": "knackeri", "knacks": "knack", "knag": "knag",
+		"knave": "knave", "knaves": "knave", "knavish": "knavish", "kneaded": "knead",
+		"kneading": "knead", "knee": "knee", "kneel": "kneel", "kneeled": "kneel",
+		"kneeling": "kneel", "kneels": "kneel", "knees": "knee", "knell": "knell",
+		"knelt": "knelt", "knew": "knew", "knick": "knick", "knif": "knif", "knife": "knife",
+		"knight": "knight", "knightly": "knight", "knights": "knight", "knit": "knit",
+		"knits": "knit", "knitted": "knit", "knitting": "knit", "knives": "knive",
+		"knob": "knob", "knobs": "knob", "knock": "knock", "knocked": "knock",
+		"knocker": "knocker", "knockers": "knocker", "knocking": "knock", "knocks": "knock",
+		"knopp": "knopp", "knot": "knot", "knots": "knot",
+
+		// Step 1a: s, ies and the vowel before them
+		"caresses": "caress", "ponies": "poni", "ties": "tie", "cried": "cri",
+		"gas": "gas", "this": "this", "gaps": "gap", "kiwis": "kiwi",
+		// Step 1b and 1c, y as a consonant
+		"agreed": "agre", "plastered": "plaster", "motoring": "motor", "hoping": "hope",
+		"hopping": "hop", "happy": "happi", "cry": "cri", "by": "by", "say": "say",
+		"enjoy": "enjoy", "youth": "youth", "yelled": "yell", "sayings": "say",
+		// Steps 2 to 5
+		"relational": "relat", "conditional": "condit", "rational": "ration",
+		"hopefulness": "hope", "goodness": "good", "allowance": "allow",
+		"replacement": "replac", "adoption": "adopt", "sensibility": "sensibl",
+		"electrical": "electr", "luxuriate": "luxuri", "luxurious": "luxuri",
+		// R1 after the prefixes gener, commun and arsen
+		"generous": "generous", "generously": "generous", "generate": "generat",
+		"generation": "generat", "general": "general", "arsenal": "arsenal",
+		"commune": "commune", "communication": "communic",
+
+		// Exceptional forms
+		"skis": "ski", "skies": "sky", "sky": "sky", "dying": "die", "lying": "lie",
+		"tying": "tie", "idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli",
+		"only": "onli", "singly": "singl", "news": "news", "howe": "howe", "atlas": "atlas",
+		"cosmos": "cosmos", "bias": "bias", "andes": "andes",
+		"inning": "inning", "outing": "outing", "canning": "canning", "herring": "herring",
+		"earring": "earring", "proceed": "proceed", "exceed": "exceed", "succeed": "succeed",
+		"succeeded": "succeed",
+
+		// Left alone: short words, and words that are not English
+		"ox": "ox", "été": "été", "naïve": "naïve",
+	}
+	for word, want := range tests {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package indexer
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Default stop word lists, one file per language code (en.txt, fr.txt...)
+//
+//go:embed stopwords/*.txt
+var stopWordFiles embed.FS
+
+var (
+	stopWordsMu sync.RWMutex
+	stopWords   = loadEmbeddedStopWords()
+)
+
+func loadEmbeddedStopWords() map[string]map[string]bool {
+	lists := make(map[string]map[string]bool)
+
+	entries, _ := stopWordFiles.ReadDir("stopwords")
+	for _, entry := range entries {
+		file, err := stopWordFiles.Open("stopwords/" + entry.Name())
+		if err != nil {
+			continue
+		}
+		lists[strings.TrimSuffix(entry.Name(), ".txt")] = readStopWords(file)
+		file.Close()
+	}
+	return lists
+}
+
+// readStopWords reads one word per line; blank lines and # comments are ignored
+func readStopWords(r io.Reader) map[string]bool {
+	words := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, word := range strings.Fields(line) {
+			words[strings.ToLower(word)] = true
+		}
+	}
+	return words
+}
+
+// LoadStopWords replaces the stop words of a language with the words of a file
+func LoadStopWords(language, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open stop words: %w", err)
+	}
+	defer file.Close()
+
+	words := readStopWords(file)
+
+	stopWordsMu.Lock()
+	stopWords[language] = words
+	stopWordsMu.Unlock()
+	return nil
+}
+
+// LoadStopWordsDir loads every <code>.txt file of a directory, overriding the
+// bundled lists. It returns the number of files loaded; a missing directory loads nothing.
+// The index and the server must load the same lists, or queries won't match.
+func LoadStopWordsDir(dir string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, file := range files {
+		language := strings.TrimSuffix(filepath.Base(file), ".txt")
+		if err := LoadStopWords(language, file); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// StopWordsFor returns the stop words of a language code (empty if there is no list)
+func StopWordsFor(language string) map[string]bool {
+	stopWordsMu.RLock()
+	defer stopWordsMu.RUnlock()
+
+	if words, found := stopWords[language]; found {
+		return words
+	}
+	return map[string]bool{}
+}
@@ -1,12 +1,8 @@
 package indexer
 
-import (
-	"strings"
-	"unicode"
-)
-
-// Stop words to skip during indexing
-var StopWords = map[string]bool{
+// Stop words of the simple analyzer. Newer indexes use the per-language
+// lists of the stopwords directory instead (see StopWordsFor).
+var legacyStopWords = map[string]bool{
 	"the": true, "a": true, "an": true, "and": true, "or": true,
 	"but": true, "in": true, "on": true, "at": true, "to": true,
 	"for": true, "of": true, "as": true, "by": true, "is": true,
@@ -19,29 +15,13 @@ var StopWords = map[string]bool{
 	"his": true, "her": true, "their": true, "its": true, "our": true,
 }
 
-// Tokenize converts text into cleaned words
+// Tokenize converts text into cleaned words (lowercased, without stop words, not stemmed)
 func Tokenize(text string) []string {
-	var words []string
-	var current strings.Builder
-
-	for _, r := range text {
-		if unicode.IsLetter(r) || unicode.IsNumber(r) {
-			current.WriteRune(unicode.ToLower(r))
-		} else if current.Len() > 0 {
-			word := current.String()
-			if len(word) > 2 && !StopWords[word] {
-				words = append(words, word)
-			}
-			current.Reset()
-		}
-	}
+	tokens := SimpleAnalyzer.Analyze(text)
 
-	if current.Len() > 0 {
-		word := current.String()
-		if len(word) > 2 && !StopWords[word] {
-			words = append(words, word)
-		}
+	words := make([]string, len(tokens))
+	for i, token := range tokens {
+		words[i] = token.Term
 	}
-
 	return words
 }
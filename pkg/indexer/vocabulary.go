@@ -0,0 +1,107 @@
+package indexer
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Vocabulary is the sorted list of the words of the books (the surface forms of
+// the terms), for the searches that look for words close to the query's rather
+// than equal to them: sorted words sharing a prefix follow each other, so whole
+// ranges of words can be skipped.
+type Vocabulary struct {
+	Words []string
+	// Reversed holds the words written backwards, sorted, so that the words
+	// ending the same way follow each other too
+	Reversed []string
+	// terms maps each word to the terms it was indexed as
+	terms map[string][]string
+}
+
+// vocabularyCache holds the vocabulary of an index once it has been built
+type vocabularyCache struct {
+	mu         sync.Mutex
+	vocabulary *Vocabulary
+}
+
+// Vocabulary returns the vocabulary of the index. It is built on first use
+// and kept until books are added or removed.
+func (idx *Indexer) Vocabulary() *Vocabulary {
+	cache := &idx.vocabulary
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.vocabulary == nil {
+		cache.vocabulary = buildVocabulary(idx)
+	}
+	return cache.vocabulary
+}
+
+// resetVocabulary drops the vocabulary after a change to the index
+func (idx *Indexer) resetVocabulary() {
+	idx.vocabulary.mu.Lock()
+	idx.vocabulary.vocabulary = nil
+	idx.vocabulary.mu.Unlock()
+}
+
+func buildVocabulary(idx *Indexer) *Vocabulary {
+	v := &Vocabulary{terms: make(map[string][]string, len(idx.WordToBooks))}
+	for term := range idx.WordToBooks {
+		for _, form := range idx.SurfaceForms(term) {
+			if _, found := v.terms[form]; !found {
+				v.Words = append(v.Words, form)
+			}
+			v.terms[form] = append(v.terms[form], term)
+		}
+	}
+
+	sort.Strings(v.Words)
+	for _, terms := range v.terms {
+		sort.Strings(terms)
+	}
+
+	v.Reversed = make([]string, len(v.Words))
+	for i, word := range v.Words {
+		v.Reversed[i] = Reverse(word)
+	}
+	sort.Strings(v.Reversed)
+	return v
+}
+
+// WithPrefix returns the words starting with prefix, a range of the sorted words
+func (v *Vocabulary) WithPrefix(prefix string) []string {
+	return prefixRange(v.Words, prefix)
+}
+
+// WithSuffix returns the words ending with suffix, found as a range of the reversed words
+func (v *Vocabulary) WithSuffix(suffix string) []string {
+	reversed := prefixRange(v.Reversed, Reverse(suffix))
+	words := make([]string, len(reversed))
+	for i, r := range reversed {
+		words[i] = Reverse(r)
+	}
+	return words
+}
+
+// prefixRange returns the strings of a sorted list starting with prefix
+func prefixRange(sorted []string, prefix string) []string {
+	start := sort.SearchStrings(sorted, prefix)
+	end := start + sort.Search(len(sorted)-start, func(i int) bool {
+		return !strings.HasPrefix(sorted[start+i], prefix)
+	})
+	return sorted[start:end]
+}
+
+// Reverse writes a word backwards, letter by letter
+func Reverse(word string) string {
+	runes := []rune(word)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// Terms returns the terms a word of the vocabulary was indexed as
+func (v *Vocabulary) Terms(word string) []string {
+	return v.terms[word]
+}
@@ -0,0 +1,73 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// Library gives access to the files of the indexed books, all stored as
+// book_<id>.txt in one root directory (data/books by default).
+//
+// The paths recorded in the index depend on the directory build_index was run
+// from, so only their file name is kept: the index can be built in one place
+// and served from another.
+type Library struct {
+	Root string
+}
+
+// New creates a library rooted at a directory
+func New(root string) *Library {
+	return &Library{Root: root}
+}
+
+// Path returns where the file of a book is, always inside the root: a recorded
+// path with no file name (.., /) gives the default book_<id>.txt
+func (l *Library) Path(book models.Book) string {
+	name := filepath.Base(book.FilePath)
+	if book.FilePath == "" || name == "." || name == ".." || name == string(filepath.Separator) {
+		name = fmt.Sprintf("book_%d.txt", book.ID)
+	}
+	return filepath.Join(l.Root, name)
+}
+
+// Open opens the file of a book and returns its size and modification time.
+// The error satisfies os.IsNotExist if the file is missing.
+func (l *Library) Open(book models.Book) (*os.File, os.FileInfo, error) {
+	file, err := os.Open(l.Path(book))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// ReadFile reads the whole file of a book
+func (l *Library) ReadFile(book models.Book) ([]byte, error) {
+	return os.ReadFile(l.Path(book))
+}
+
+// Check returns an error if the root directory doesn't exist
+func (l *Library) Check() error {
+	info, err := os.Stat(l.Root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", l.Root)
+	}
+	return nil
+}
+
+// ETag returns an entity tag for a book file, from its modification time and size.
+// It changes when the file is replaced, without reading the file to hash it.
+func ETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
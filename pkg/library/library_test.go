@@ -0,0 +1,66 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+func TestPath(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "books")
+	lib := New(root)
+
+	tests := []struct {
+		filePath, want string
+	}{
+		{"", "book_7.txt"},
+		{"data/books/book_7.txt", "book_7.txt"},
+		{"/srv/books/book_7.txt", "book_7.txt"},
+		{"../../etc/passwd", "passwd"},
+		{"..", "book_7.txt"},
+		{"../..", "book_7.txt"},
+		{".", "book_7.txt"},
+		{"/", "book_7.txt"},
+		{"books/../../secret.txt", "secret.txt"},
+	}
+	for _, tt := range tests {
+		path := lib.Path(models.Book{ID: 7, FilePath: tt.filePath})
+		if path != filepath.Join(root, tt.want) {
+			t.Errorf("Path(%q) = %s, want %s in the root", tt.filePath, path, tt.want)
+		}
+		if rel, err := filepath.Rel(root, path); err != nil || strings.HasPrefix(rel, "..") {
+			t.Errorf("Path(%q) = %s, outside %s", tt.filePath, path, root)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "book_1.txt"), []byte("Call me Ishmael."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A file next to the root, that a recorded path must not reach
+	if err := os.WriteFile(filepath.Join(filepath.Dir(root), "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lib := New(root)
+
+	file, info, err := lib.Open(models.Book{ID: 1, FilePath: "old/place/book_1.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if info.Size() != 16 {
+		t.Errorf("size %d, want 16", info.Size())
+	}
+
+	if _, _, err := lib.Open(models.Book{ID: 2, FilePath: "../secret.txt"}); !os.IsNotExist(err) {
+		t.Errorf("Open(../secret.txt) = %v, want a missing file", err)
+	}
+	if _, err := lib.ReadFile(models.Book{ID: 2}); !os.IsNotExist(err) {
+		t.Errorf("ReadFile of a missing book = %v", err)
+	}
+}
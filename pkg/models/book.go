@@ -1,15 +1,114 @@
 package models
 
 type Book struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title"`
-	Author    string `json:"author"`
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	Author   string `json:"author"`
+	Language string `json:"language,omitempty"` // ISO 639-1 code, e.g. "en"
+	// Metadata read from the Gutenberg header, empty when absent
+	Subtitle            string `json:"subtitle,omitempty"`
+	Translator          string `json:"translator,omitempty"`
+	Illustrator         string `json:"illustrator,omitempty"`
+	Editor              string `json:"editor,omitempty"`
+	ReleaseDate         string `json:"release_date,omitempty"`
+	OriginalPublication string `json:"original_publication,omitempty"`
+	Credits             string `json:"credits,omitempty"`
+	// Metadata from the Gutenberg RDF catalog (see cmd/import_catalog)
+	Authors     []Person `json:"authors,omitempty"`
+	Subjects    []string `json:"subjects,omitempty"`    // Library of Congress subject headings
+	Bookshelves []string `json:"bookshelves,omitempty"` // Gutenberg bookshelves
+	LCC         []string `json:"lcc,omitempty"`         // Library of Congress classification
+
 	FilePath  string `json:"file_path"`
 	WordCount int    `json:"word_count"`
+	// Cluster is the ID of the book its cluster of similar books is named after.
+	// It is computed from the Jaccard graph when the server starts, not stored in the index.
+	Cluster int `json:"cluster,omitempty"`
+}
+
+// Chapter is a part of a book, found from its heading (see indexer.DetectChapters)
+type Chapter struct {
+	Title string `json:"title"`
+	Level int    `json:"level"` // 1 for parts of the book (BOOK I, PREFACE), 2 for chapters
+	Start int    `json:"start"` // byte offsets of the chapter in the book file
+	End   int    `json:"end"`
+	// Offset and Length are the same in characters, as the reader shows them
+	Offset int `json:"offset"`
+	Length int `json:"length"`
+}
+
+// Person is an author as described in the catalog
+type Person struct {
+	Name      string `json:"name"` // "Austen, Jane"
+	BirthYear int    `json:"birth_year,omitempty"`
+	DeathYear int    `json:"death_year,omitempty"`
 }
 
 type SearchResult struct {
 	Book        Book    `json:"book"`
 	Occurrences int     `json:"occurrences"`
 	Relevance   float64 `json:"relevance"`
+	// Snippets are passages of the book around the words that matched,
+	// only filled for the results of the requested page
+	Snippets []Snippet `json:"snippets,omitempty"`
+	// Explanation breaks Relevance down, only filled when asked for (explain=true)
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// Snippet is a passage of a book with the matched words marked
+type Snippet struct {
+	// Fragment is the passage as HTML: escaped text with the matches in <mark> tags
+	Fragment string `json:"fragment"`
+	// Offset is the byte offset of the passage in the book file
+	Offset int `json:"offset"`
+}
+
+// Match is where a search inside a book found the query
+type Match struct {
+	Line   int `json:"line"`   // line of the book file, from 1
+	Column int `json:"column"` // characters before the match on its line
+	Offset int `json:"offset"` // characters before the match in the file
+	Length int `json:"length"` // characters of the match
+	// Chapter is the number of the chapter of the match, from 1 (see Chapter),
+	// and ChapterOffset the characters before the match in that chapter
+	Chapter       int    `json:"chapter"`
+	ChapterOffset int    `json:"chapter_offset"`
+	Text          string `json:"text"`
+	// Context is the text around the match, as HTML with the match in a <mark> tag
+	Context string `json:"context"`
+}
+
+// Explanation shows how the relevance of a result was computed: the BM25 score,
+// sum of the contributions of the matched terms, multiplied by a PageRank factor
+type Explanation struct {
+	Terms []TermScore `json:"terms"`
+	BM25  float64     `json:"bm25"`
+	// K1, B and AvgLength are the BM25 parameters and average book length the terms were scored with
+	K1        float64 `json:"k1"`
+	B         float64 `json:"b"`
+	AvgLength float64 `json:"avg_length"`
+	PageRank  float64 `json:"pagerank"`
+	// PageRankFactor multiplies BM25; PageRankContribution is what it adds to it
+	PageRankFactor       float64 `json:"pagerank_factor"`
+	PageRankContribution float64 `json:"pagerank_contribution"`
+	Relevance            float64 `json:"relevance"`
+	// Formula is the computation of Relevance with the numbers of the result
+	Formula string `json:"formula"`
+}
+
+// TermScore is the contribution of one matched term to a BM25 score:
+// Score = IDF × Boost × TF
+type TermScore struct {
+	Term string `json:"term"`
+	// Field is the metadata field the term was found in, "" for the text
+	Field       string `json:"field,omitempty"`
+	Occurrences int    `json:"occurrences"`
+	// DocFreq is the number of books with the term, from which IDF is computed
+	DocFreq int     `json:"doc_freq"`
+	IDF     float64 `json:"idf"`
+	Boost   float64 `json:"boost"`
+	// TF is the saturated, length normalized frequency of the term, for a book of Length words
+	TF     float64 `json:"tf"`
+	Length int     `json:"length"`
+	Score  float64 `json:"score"`
 }
@@ -1,10 +1,11 @@
 package ranking
 
 import (
-	"sort"
+	"fmt"
 
 	"github.com/taqiyeddinedj/daar-project3/pkg/graph"
 	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+	"github.com/taqiyeddinedj/daar-project3/pkg/search"
 )
 
 // CalculatePageRank computes PageRank scores for all books
@@ -50,21 +51,54 @@ func CalculatePageRank(jaccardGraph *graph.JaccardGraph, iterations int, damping
 	return pageRank
 }
 
-// RankResults sorts search results by PageRank
+// pageRankWeight is how much PageRank counts against BM25 in RankResults
+const pageRankWeight = 10
+
+// PageRankFactor is the factor by which RankResults multiplies the BM25 score of a book
+func PageRankFactor(pr float64) float64 {
+	return 1.0 + pr*pageRankWeight
+}
+
+// Profile describes how results are ranked: the field boosts of their BM25 score and
+// the weight of PageRank. Results ranked with another profile are not the same.
+func Profile() string {
+	return fmt.Sprintf("bm25 %v × (1 + %d × pagerank)", search.FieldBoosts, pageRankWeight)
+}
+
+// RankResults sorts search results by PageRank.
+// It must be called once on results fresh from the search package.
 func RankResults(results []models.SearchResult, pageRank map[int]float64) []models.SearchResult {
-	// Update relevance with PageRank
+	ApplyPageRank(results, pageRank)
+
+	// Sort by new relevance
+	search.SortResults(results)
+
+	return results
+}
+
+// ApplyPageRank updates the relevance of results like RankResults, leaving them in their order.
+// It must be called once on results fresh from the search package.
+func ApplyPageRank(results []models.SearchResult, pageRank map[int]float64) {
 	for i := range results {
 		bookID := results[i].Book.ID
 		pr := pageRank[bookID]
 
-		// Combine occurrence count with PageRank
-		results[i].Relevance = float64(results[i].Occurrences) * (1.0 + pr*10)
+		// Combine the BM25 score with PageRank
+		results[i].Relevance = results[i].Relevance * PageRankFactor(pr)
 	}
+}
 
-	// Sort by new relevance
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Relevance > results[j].Relevance
-	})
-
-	return results
+// Explain completes the explanation of a result (see search.Explain)
+// with the PageRank part of its relevance, as computed by RankResults
+func Explain(r *models.SearchResult, pageRank map[int]float64) {
+	e := r.Explanation
+	if e == nil {
+		return
+	}
+	e.PageRank = pageRank[r.Book.ID]
+	e.PageRankFactor = PageRankFactor(e.PageRank)
+	e.Relevance = e.BM25 * e.PageRankFactor
+	e.PageRankContribution = e.Relevance - e.BM25
+	e.Formula = fmt.Sprintf("relevance = bm25 × (1 + %d × pagerank) = %.4f × (1 + %d × %.6f) = %.4f",
+		pageRankWeight, e.BM25, pageRankWeight, e.PageRank, e.Relevance)
 }
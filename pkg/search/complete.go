@@ -0,0 +1,318 @@
+package search
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+)
+
+const (
+	// MaxCompletions is the number of completions of each kind returned at most
+	MaxCompletions = 20
+
+	// minCompletionFrequency is the number of books a word must be in to be completed to
+	minCompletionFrequency = 2
+)
+
+// Completion is a text the search box may be completed with.
+// Weight is the number of books with that word, title or author.
+type Completion struct {
+	Text   string `json:"text"`
+	Weight int    `json:"weight"`
+}
+
+// Completions are the completions of a prefix, best first
+type Completions struct {
+	// Words complete the last word of the prefix, the words before it kept as typed
+	Words   []Completion `json:"words"`
+	Titles  []Completion `json:"titles"`
+	Authors []Completion `json:"authors"`
+}
+
+// Completer completes the beginning of a query with the words of the books,
+// their titles and their authors. Titles and authors also complete from any of
+// their words: "mel" gives Herman Melville.
+type Completer struct {
+	words, titles, authors *trie
+}
+
+// NewCompleter builds the prefix trees of the words, titles and authors of an index
+func NewCompleter(idx *indexer.Indexer) *Completer {
+	var words []trieKey
+	vocabulary := idx.Vocabulary()
+	for _, word := range vocabulary.Words {
+		if frequency := bookFrequency(idx, vocabulary.Terms(word)); frequency >= minCompletionFrequency {
+			words = append(words, trieKey{key: word, completion: Completion{Text: word, Weight: frequency}})
+		}
+	}
+
+	titles := make(map[string]int)
+	authors := make(map[string]int)
+	for _, book := range idx.Books {
+		if book.Title != "" && book.Title != "Unknown" {
+			titles[book.Title]++
+		}
+		if book.Author != "" && book.Author != "Unknown" {
+			authors[book.Author]++
+		}
+	}
+
+	return &Completer{
+		words:   newTrie(words),
+		titles:  newTrie(wordStartKeys(titles)),
+		authors: newTrie(wordStartKeys(authors)),
+	}
+}
+
+// Size returns the number of nodes of the prefix trees
+func (c *Completer) Size() int {
+	return len(c.words.nodes) + len(c.titles.nodes) + len(c.authors.nodes)
+}
+
+// Complete returns the best completions of each kind for what was typed so far
+func (c *Completer) Complete(prefix string, limit int) Completions {
+	completions := Completions{Words: []Completion{}, Titles: []Completion{}, Authors: []Completion{}}
+	key := completionKey(prefix)
+	if key == "" {
+		return completions
+	}
+
+	// the last word, unless the prefix ends with a space or is a field (author:mel)
+	head := prefix[:strings.LastIndexAny(prefix, " \t")+1]
+	if word := normalizeWord(prefix[len(head):]); word != "" && !strings.Contains(word, ":") {
+		for _, w := range c.words.complete(word, limit) {
+			completions.Words = append(completions.Words, Completion{Text: head + w.Text, Weight: w.Weight})
+		}
+	}
+
+	completions.Titles = append(completions.Titles, c.titles.complete(key, limit)...)
+	completions.Authors = append(completions.Authors, c.authors.complete(key, limit)...)
+	return completions
+}
+
+// MergeCompletions adds up the completions of several parts of the collection (shards)
+func MergeCompletions(lists []Completions, limit int) Completions {
+	var words, titles, authors [][]Completion
+	for _, l := range lists {
+		words = append(words, l.Words)
+		titles = append(titles, l.Titles)
+		authors = append(authors, l.Authors)
+	}
+	return Completions{
+		Words:   mergeCompletionList(words, limit),
+		Titles:  mergeCompletionList(titles, limit),
+		Authors: mergeCompletionList(authors, limit),
+	}
+}
+
+func mergeCompletionList(lists [][]Completion, limit int) []Completion {
+	weights := make(map[string]int)
+	for _, list := range lists {
+		for _, c := range list {
+			weights[c.Text] += c.Weight
+		}
+	}
+
+	merged := make([]Completion, 0, len(weights))
+	for text, weight := range weights {
+		merged = append(merged, Completion{Text: text, Weight: weight})
+	}
+	sortCompletions(merged)
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+func sortCompletions(completions []Completion) {
+	sort.Slice(completions, func(a, b int) bool {
+		if completions[a].Weight != completions[b].Weight {
+			return completions[a].Weight > completions[b].Weight
+		}
+		return completions[a].Text < completions[b].Text
+	})
+}
+
+// completionKey normalizes typed text like the keys of the titles and authors,
+// keeping a final space: "moby " completes to Moby Dick, not to Mobydick
+func completionKey(text string) string {
+	key := strings.Join(strings.Fields(normalizeWord(text)), " ")
+	if key != "" && strings.TrimRight(text, " \t") != text {
+		key += " "
+	}
+	return key
+}
+
+// wordStartKeys returns the keys of titles or authors with their number of books:
+// one for the whole text and one starting at each of its other words
+func wordStartKeys(counts map[string]int) []trieKey {
+	var keys []trieKey
+	for text, count := range counts {
+		words := strings.Fields(normalizeWord(text))
+		for i := range words {
+			keys = append(keys, trieKey{
+				key:        strings.Join(words[i:], " "),
+				completion: Completion{Text: text, Weight: count},
+			})
+		}
+	}
+	return keys
+}
+
+// trie is a compact prefix tree: its nodes are stored in one slice, the children
+// of a node next to each other and sorted by byte, and each node knows the highest
+// weight under it, so the best completions of a prefix are found without visiting
+// all of them.
+type trie struct {
+	nodes []trieNode
+	// entries holds the completions sorted by key; the ones whose key ends at a node
+	// are entries[firstEntry:lastEntry]
+	entries []Completion
+}
+
+type trieNode struct {
+	label                 byte
+	firstChild, lastChild int32 // children are nodes[firstChild:lastChild]
+	firstEntry, lastEntry int32
+	best                  int32
+}
+
+// trieKey is a completion and the key it is found under
+type trieKey struct {
+	key        string
+	completion Completion
+}
+
+func newTrie(keys []trieKey) *trie {
+	sort.Slice(keys, func(a, b int) bool { return keys[a].key < keys[b].key })
+
+	t := &trie{entries: make([]Completion, len(keys))}
+	for i, k := range keys {
+		t.entries[i] = k.completion
+	}
+
+	// The nodes are added breadth first, so the children of a node are added together.
+	// Each node covers the keys sharing the prefix it stands for.
+	type span struct {
+		node          int32
+		lo, hi, depth int
+	}
+	t.nodes = append(t.nodes, trieNode{})
+	queue := []span{{node: 0, lo: 0, hi: len(keys), depth: 0}}
+	for next := 0; next < len(queue); next++ {
+		s := queue[next]
+
+		// keys ending at this node come first
+		lo := s.lo
+		for lo < s.hi && len(keys[lo].key) == s.depth {
+			lo++
+		}
+		t.nodes[s.node].firstEntry, t.nodes[s.node].lastEntry = int32(s.lo), int32(lo)
+		t.nodes[s.node].firstChild = int32(len(t.nodes))
+
+		for i := lo; i < s.hi; {
+			label := keys[i].key[s.depth]
+			j := i
+			for j < s.hi && keys[j].key[s.depth] == label {
+				j++
+			}
+			t.nodes = append(t.nodes, trieNode{label: label})
+			queue = append(queue, span{node: int32(len(t.nodes) - 1), lo: i, hi: j, depth: s.depth + 1})
+			i = j
+		}
+		t.nodes[s.node].lastChild = int32(len(t.nodes))
+	}
+
+	// children come after their parent: compute the best weights from the end
+	for i := len(t.nodes) - 1; i >= 0; i-- {
+		n := &t.nodes[i]
+		for _, e := range t.entries[n.firstEntry:n.lastEntry] {
+			n.best = max(n.best, int32(e.Weight))
+		}
+		for _, child := range t.nodes[n.firstChild:n.lastChild] {
+			n.best = max(n.best, child.best)
+		}
+	}
+	return t
+}
+
+// find returns the node of a prefix, or -1 if no key starts with it
+func (t *trie) find(prefix string) int32 {
+	node := int32(0)
+	for i := 0; i < len(prefix); i++ {
+		n := t.nodes[node]
+		children := t.nodes[n.firstChild:n.lastChild]
+		j := sort.Search(len(children), func(k int) bool { return children[k].label >= prefix[i] })
+		if j == len(children) || children[j].label != prefix[i] {
+			return -1
+		}
+		node = n.firstChild + int32(j)
+	}
+	return node
+}
+
+// complete returns the limit completions of highest weight whose key starts with prefix.
+// Nodes and completions are visited best weight first: a node's weight is the highest
+// under it, so a completion taken out of the queue is better than any left in it.
+func (t *trie) complete(prefix string, limit int) []Completion {
+	node := t.find(prefix)
+	if node < 0 {
+		return nil
+	}
+
+	var completions []Completion
+	seen := make(map[string]bool)
+	queue := &trieQueue{{weight: t.nodes[node].best, node: node, entry: -1, position: t.nodes[node].firstEntry}}
+	for queue.Len() > 0 && len(completions) < limit {
+		item := heap.Pop(queue).(trieItem)
+		if item.entry >= 0 {
+			// a title is found under each of its words
+			if c := t.entries[item.entry]; !seen[c.Text] {
+				seen[c.Text] = true
+				completions = append(completions, c)
+			}
+			continue
+		}
+
+		n := t.nodes[item.node]
+		for e := n.firstEntry; e < n.lastEntry; e++ {
+			heap.Push(queue, trieItem{weight: int32(t.entries[e].Weight), node: -1, entry: e, position: e})
+		}
+		for child := n.firstChild; child < n.lastChild; child++ {
+			heap.Push(queue, trieItem{weight: t.nodes[child].best, node: child, entry: -1, position: t.nodes[child].firstEntry})
+		}
+	}
+	return completions
+}
+
+// trieItem is a node or a completion waiting to be visited
+type trieItem struct {
+	weight      int32
+	node, entry int32
+	// position is the position of the item's first key in the sorted keys
+	position int32
+}
+
+// trieQueue orders the items by weight, then in the order of their keys
+type trieQueue []trieItem
+
+func (q trieQueue) Len() int { return len(q) }
+func (q trieQueue) Less(a, b int) bool {
+	if q[a].weight != q[b].weight {
+		return q[a].weight > q[b].weight
+	}
+	if q[a].position != q[b].position {
+		return q[a].position < q[b].position
+	}
+	return q[a].entry > q[b].entry
+}
+func (q trieQueue) Swap(a, b int)       { q[a], q[b] = q[b], q[a] }
+func (q *trieQueue) Push(x interface{}) { *q = append(*q, x.(trieItem)) }
+func (q *trieQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
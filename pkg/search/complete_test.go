@@ -0,0 +1,112 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+// completeIndex has words in 4 (whale), 3 (wharf, étoile), 2 (whalebone, été)
+// and 1 book (whaler, too rare to be completed to)
+func completeIndex(t *testing.T) *Completer {
+	t.Helper()
+	return NewCompleter(newTestIndex(t,
+		testBook{title: "Moby Dick", author: "Herman Melville", text: "whale wharf whalebone étoile été"},
+		testBook{title: "Moby Dick", author: "Herman Melville", text: "whale wharf whalebone étoile été"},
+		testBook{title: "Les Misérables", author: "Victor Hugo", text: "whale wharf étoile"},
+		testBook{title: "Moby Grape", author: "Émile Zola", text: "whale whaler"},
+	))
+}
+
+func completionTexts(completions []Completion) []string {
+	texts := []string{}
+	for _, c := range completions {
+		texts = append(texts, c.Text)
+	}
+	return texts
+}
+
+func TestCompleteTopK(t *testing.T) {
+	c := completeIndex(t)
+
+	got := c.Complete("wha", MaxCompletions).Words
+	want := []Completion{{Text: "whale", Weight: 4}, {Text: "wharf", Weight: 3}, {Text: "whalebone", Weight: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Complete(wha) = %v, want %v", got, want)
+	}
+
+	for k := 1; k <= len(want); k++ {
+		if got := c.Complete("wha", k).Words; !reflect.DeepEqual(got, want[:k]) {
+			t.Errorf("Complete(wha, %d) = %v, want %v", k, got, want[:k])
+		}
+	}
+
+	// The words before the last one are kept as typed
+	if got := completionTexts(c.Complete("Moby wharf whal", 1).Words); !reflect.DeepEqual(got, []string{"Moby wharf whale"}) {
+		t.Errorf("Complete(Moby wharf whal) = %v", got)
+	}
+}
+
+func TestCompleteTitlesAndAuthors(t *testing.T) {
+	c := completeIndex(t)
+
+	got := c.Complete("moby", MaxCompletions)
+	if want := []Completion{{Text: "Moby Dick", Weight: 2}, {Text: "Moby Grape", Weight: 1}}; !reflect.DeepEqual(got.Titles, want) {
+		t.Errorf("titles of moby = %v, want %v", got.Titles, want)
+	}
+
+	// From any word of a title or author, once each
+	if got := completionTexts(c.Complete("mel", MaxCompletions).Authors); !reflect.DeepEqual(got, []string{"Herman Melville"}) {
+		t.Errorf("authors of mel = %v", got)
+	}
+	if got := completionTexts(c.Complete("moby d", MaxCompletions).Titles); !reflect.DeepEqual(got, []string{"Moby Dick"}) {
+		t.Errorf("titles of \"moby d\" = %v", got)
+	}
+}
+
+func TestCompleteNothing(t *testing.T) {
+	c := completeIndex(t)
+
+	for _, prefix := range []string{"", "   ", "xyz", "whalex", "author:mel"} {
+		got := c.Complete(prefix, MaxCompletions)
+		if got.Words == nil || got.Titles == nil || got.Authors == nil {
+			t.Errorf("Complete(%q) has nil lists, which are null in JSON: %#v", prefix, got)
+		}
+		if prefix != "author:mel" && len(got.Words)+len(got.Titles)+len(got.Authors) > 0 {
+			t.Errorf("Complete(%q) = %v, want nothing", prefix, got)
+		}
+		if len(got.Words) > 0 {
+			t.Errorf("Complete(%q) completed a word: %v", prefix, got.Words)
+		}
+	}
+}
+
+func TestCompleteUTF8(t *testing.T) {
+	c := completeIndex(t)
+
+	tests := []struct {
+		prefix string
+		words  []string
+	}{
+		{"ét", []string{"étoile", "été"}},
+		{"été", []string{"été"}},
+		{"ÉT", []string{"étoile", "été"}},
+		{"étoiles", []string{}},
+	}
+	for _, tt := range tests {
+		if got := completionTexts(c.Complete(tt.prefix, MaxCompletions).Words); !reflect.DeepEqual(got, tt.words) {
+			t.Errorf("Complete(%q) = %v, want %v", tt.prefix, got, tt.words)
+		}
+	}
+
+	if got := completionTexts(c.Complete("misé", MaxCompletions).Titles); !reflect.DeepEqual(got, []string{"Les Misérables"}) {
+		t.Errorf("titles of misé = %v", got)
+	}
+	if got := completionTexts(c.Complete("émi", MaxCompletions).Authors); !reflect.DeepEqual(got, []string{"Émile Zola"}) {
+		t.Errorf("authors of émi = %v", got)
+	}
+
+	// Invalid UTF-8, such as a prefix cut inside a letter, completes to nothing
+	if got := c.Complete("\xc3", MaxCompletions); len(got.Words)+len(got.Titles)+len(got.Authors) > 0 {
+		t.Errorf("Complete of a partial letter = %v", got)
+	}
+}
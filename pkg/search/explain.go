@@ -0,0 +1,50 @@
+package search
+
+import (
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// Explain sets the explanation of the BM25 score of a result: the contribution of
+// every term of the query found in the book, computed like ScoreTerms does.
+// Its PageRank part is left to ranking.Explain.
+func Explain(idx *indexer.Indexer, q Query, stats CorpusStats, r *models.SearchResult) {
+	avgLength := stats.AvgBookLength()
+	e := &models.Explanation{
+		Terms:     []models.TermScore{},
+		K1:        bm25K1,
+		B:         bm25B,
+		AvgLength: avgLength,
+	}
+
+	for _, term := range q.Terms {
+		count, found := idx.Postings(term)[r.Book.ID]
+		if !found {
+			continue
+		}
+
+		// Metadata fields are a few words long: the length of the book doesn't apply
+		field, word := indexer.SplitFieldTerm(term)
+		length := r.Book.WordCount
+		if field != "" {
+			length = int(avgLength)
+		}
+
+		score := models.TermScore{
+			Term:        word,
+			Field:       field,
+			Occurrences: count,
+			DocFreq:     stats.DocFreq[term],
+			IDF:         stats.IDF(term),
+			Boost:       FieldBoost(term),
+			TF:          BM25TF(count, length, avgLength),
+			Length:      length,
+		}
+		score.Score = score.IDF * score.Boost * score.TF
+		e.Terms = append(e.Terms, score)
+		e.BM25 += score.Score
+	}
+
+	e.Relevance = e.BM25
+	r.Explanation = e
+}
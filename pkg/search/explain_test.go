@@ -0,0 +1,44 @@
+package search
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestExplainSumsToRelevance(t *testing.T) {
+	idx := newTestIndex(t,
+		testBook{title: "Moby Dick", author: "Herman Melville", text: "The white whale. Whales and whaling, the sea."},
+		testBook{title: "The Sea", text: "The sea, the sea, and a whale far away."},
+		testBook{title: "Emma", author: "Jane Austen", text: "Emma Woodhouse, handsome, clever and rich."},
+	)
+
+	for _, query := range []string{"whale", "whale sea", "author:melville whale", `"white whale"`, "moby"} {
+		q := KeywordQuery(idx, query)
+		stats := LocalStats(idx, q.Terms)
+		results, err := ScoreQuery(context.Background(), idx, q, stats)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 0 {
+			t.Fatalf("%s: no results", query)
+		}
+		for i := range results {
+			r := &results[i]
+			Explain(idx, q, stats, r)
+			e := r.Explanation
+
+			sum := 0.0
+			for _, term := range e.Terms {
+				if part := term.IDF * term.Boost * term.TF; math.Abs(part-term.Score) > 1e-9 {
+					t.Errorf("%s, book %d: %s scores %v, IDF × boost × TF = %v", query, r.Book.ID, term.Term, term.Score, part)
+				}
+				sum += term.Score
+			}
+			if math.Abs(sum-e.BM25) > 1e-9 || math.Abs(e.BM25-r.Relevance) > 1e-9 || e.Relevance != e.BM25 {
+				t.Errorf("%s, book %d: terms sum to %v, BM25 %v, relevance %v, scored %v",
+					query, r.Book.ID, sum, e.BM25, e.Relevance, r.Relevance)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package search
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// FacetNames are the facets computed for a search, in display order
+var FacetNames = []string{"author", "language", "subject", "cluster", "length"}
+
+// FacetLimit is the number of values of each facet sent to the client
+const FacetLimit = 10
+
+// FacetValue is a value of a facet and the number of results that have it
+type FacetValue struct {
+	Value string `json:"value"`
+	// Label is the name to display when the value is a code (language, cluster, length)
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+// Facets maps each facet name to its values, most frequent first
+type Facets map[string][]FacetValue
+
+// ComputeFacets counts the values of every facet over all the results of a search,
+// before the filters are applied. The count of a facet ignores the filter on that
+// facet but not the others: with lang=fr, the language facet still tells how many
+// results are in German, while the author facet only counts French books.
+func ComputeFacets(idx *indexer.Indexer, results []models.SearchResult, filters Filters) Facets {
+	counts := make(map[string]map[string]int, len(FacetNames))
+	for _, facet := range FacetNames {
+		counts[facet] = make(map[string]int)
+	}
+
+	for _, r := range results {
+		failed := ""
+		failures := 0
+		for _, facet := range FacetNames {
+			if !filters.matchFacet(facet, r.Book) {
+				failed = facet
+				failures++
+			}
+		}
+
+		for _, facet := range FacetNames {
+			if failures > 1 || (failures == 1 && facet != failed) {
+				continue
+			}
+			for _, value := range facetValues(facet, r.Book) {
+				counts[facet][value]++
+			}
+		}
+	}
+
+	facets := make(Facets, len(FacetNames))
+	for _, facet := range FacetNames {
+		values := make([]FacetValue, 0, len(counts[facet]))
+		for value, count := range counts[facet] {
+			values = append(values, FacetValue{
+				Value: value,
+				Label: facetLabel(idx, facet, value),
+				Count: count,
+			})
+		}
+		sortFacetValues(values)
+		facets[facet] = values
+	}
+	return facets
+}
+
+// facetLabel returns the display name of a facet value, "" if it is the value itself
+func facetLabel(idx *indexer.Indexer, facet, value string) string {
+	switch facet {
+	case "language":
+		// "french" -> "French"
+		if name := indexer.Languages[value]; name != "" {
+			return strings.ToUpper(name[:1]) + name[1:]
+		}
+	case "length":
+		for _, bucket := range LengthBuckets {
+			if bucket.Name == value {
+				return bucket.Label
+			}
+		}
+	case "cluster":
+		// clusters are named after their most connected book
+		id, _ := strconv.Atoi(value)
+		if book, found := idx.Books[id]; found {
+			return book.Title
+		}
+	}
+	return ""
+}
+
+// Merge adds the counts of other, computed on another part of the library (shards)
+func (f Facets) Merge(other Facets) {
+	for facet, values := range other {
+		if f[facet] == nil {
+			f[facet] = []FacetValue{}
+		}
+		positions := make(map[string]int, len(f[facet]))
+		for i, v := range f[facet] {
+			positions[v.Value] = i
+		}
+		for _, v := range values {
+			if i, found := positions[v.Value]; found {
+				f[facet][i].Count += v.Count
+			} else {
+				positions[v.Value] = len(f[facet])
+				f[facet] = append(f[facet], v)
+			}
+		}
+		sortFacetValues(f[facet])
+	}
+}
+
+// Top returns the facets with only their n most frequent values
+func (f Facets) Top(n int) Facets {
+	top := make(Facets, len(f))
+	for facet, values := range f {
+		if len(values) > n {
+			values = values[:n]
+		}
+		top[facet] = values
+	}
+	return top
+}
+
+func sortFacetValues(values []FacetValue) {
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+}
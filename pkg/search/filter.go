@@ -0,0 +1,132 @@
+package search
+
+import (
+	"strconv"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// Filters restricts results to books with some properties.
+// An empty field doesn't filter anything; a book passes a field if it has one of its values.
+type Filters struct {
+	// Languages keeps the books written in one of these languages (codes such as "fr")
+	Languages []string `json:"languages,omitempty"`
+	// Authors keeps the books by one of these authors, as in Book.Author
+	Authors []string `json:"authors,omitempty"`
+	// Subjects keeps the books with one of these catalog subjects
+	Subjects []string `json:"subjects,omitempty"`
+	// Clusters keeps the books of these clusters (see Book.Cluster)
+	Clusters []string `json:"clusters,omitempty"`
+	// Lengths keeps the books in these word-count buckets (see LengthBuckets)
+	Lengths []string `json:"lengths,omitempty"`
+}
+
+// values returns the filter values of a facet
+func (f Filters) values(facet string) []string {
+	switch facet {
+	case "language":
+		return f.Languages
+	case "author":
+		return f.Authors
+	case "subject":
+		return f.Subjects
+	case "cluster":
+		return f.Clusters
+	case "length":
+		return f.Lengths
+	}
+	return nil
+}
+
+// matchFacet reports whether a book passes the filter of one facet
+func (f Filters) matchFacet(facet string, book models.Book) bool {
+	wanted := f.values(facet)
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, value := range facetValues(facet, book) {
+		if contains(wanted, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether a book passes the filters
+func (f Filters) Match(book models.Book) bool {
+	for _, facet := range FacetNames {
+		if !f.matchFacet(facet, book) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply keeps the results whose book passes the filters
+func (f Filters) Apply(results []models.SearchResult) []models.SearchResult {
+	kept := results[:0]
+	for _, r := range results {
+		if f.Match(r.Book) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// LengthBucket is a range of word counts, from Min included to Max excluded (0 for no limit)
+type LengthBucket struct {
+	Name  string
+	Label string
+	Min   int
+	Max   int
+}
+
+// LengthBuckets are the values of the "length" facet
+var LengthBuckets = []LengthBucket{
+	{Name: "short", Label: "Under 20,000 words", Min: 0, Max: 20000},
+	{Name: "medium", Label: "20,000 to 60,000 words", Min: 20000, Max: 60000},
+	{Name: "long", Label: "60,000 to 150,000 words", Min: 60000, Max: 150000},
+	{Name: "very-long", Label: "Over 150,000 words", Min: 150000},
+}
+
+// lengthBucket returns the name of the bucket of a word count
+func lengthBucket(wordCount int) string {
+	for _, bucket := range LengthBuckets {
+		if wordCount >= bucket.Min && (bucket.Max == 0 || wordCount < bucket.Max) {
+			return bucket.Name
+		}
+	}
+	return ""
+}
+
+// facetValues returns the values a book has for a facet
+func facetValues(facet string, book models.Book) []string {
+	switch facet {
+	case "language":
+		if book.Language != "" {
+			return []string{book.Language}
+		}
+	case "author":
+		if book.Author != "" {
+			return []string{book.Author}
+		}
+	case "subject":
+		return book.Subjects
+	case "cluster":
+		if book.Cluster != 0 {
+			return []string{strconv.Itoa(book.Cluster)}
+		}
+	case "length":
+		return []string{lengthBucket(book.WordCount)}
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+)
+
+const (
+	// MaxEditDistance is the largest number of edits a fuzzy word may be given (whale~2)
+	MaxEditDistance = 2
+
+	// maxFuzzyExpansions is the number of words a fuzzy word is expanded to at most:
+	// the closest ones, then the most frequent
+	maxFuzzyExpansions = 50
+)
+
+// AutoDistance is the number of edits allowed for a fuzzy word given without one:
+// none for words of 1 or 2 letters, which are close to too many others, 1 up to
+// 5 letters and 2 for longer words
+func AutoDistance(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// FuzzyMatch is a word of the vocabulary close to a fuzzy word
+type FuzzyMatch struct {
+	Word     string
+	Distance int
+}
+
+// FuzzyTerms returns the terms of the words of the index at most distance edits
+// away from word, and those words, closest first
+func FuzzyTerms(idx *indexer.Indexer, word string, distance int) (terms []string, variants []string) {
+	vocabulary := idx.Vocabulary()
+	matches := newLevenshtein(normalizeWord(word), distance).match(vocabulary.Words)
+
+	docFreq := func(word string) int {
+		return bookFrequency(idx, vocabulary.Terms(word))
+	}
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].Distance != matches[b].Distance {
+			return matches[a].Distance < matches[b].Distance
+		}
+		dfA, dfB := docFreq(matches[a].Word), docFreq(matches[b].Word)
+		if dfA != dfB {
+			return dfA > dfB
+		}
+		return matches[a].Word < matches[b].Word
+	})
+	if len(matches) > maxFuzzyExpansions {
+		matches = matches[:maxFuzzyExpansions]
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range matches {
+		variants = append(variants, m.Word)
+		for _, term := range vocabulary.Terms(m.Word) {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms, variants
+}
+
+// levenshtein is a Levenshtein automaton: it accepts the words at most distance edits
+// away from a word, an edit being the insertion, deletion or substitution of a letter,
+// or the transposition of two neighbour letters ("wahle" for "whale").
+//
+// Its state after reading the first letters of a word is the row of the edit distance
+// table for that prefix. Words of a sorted vocabulary that share a prefix follow each
+// other and share its states, and a state from which no word can be accepted ends the
+// whole range of words under that prefix, so most of the vocabulary is never read.
+type levenshtein struct {
+	word     []rune
+	distance int
+}
+
+func newLevenshtein(word string, distance int) levenshtein {
+	return levenshtein{word: []rune(word), distance: distance}
+}
+
+// start returns the state before reading any letter
+func (l levenshtein) start() []int {
+	row := make([]int, len(l.word)+1)
+	for j := range row {
+		row[j] = j
+	}
+	return row
+}
+
+// step writes to next the state after reading r from state row, reached by reading
+// previous. before is the state preceding row (nil at the start), for transpositions.
+func (l levenshtein) step(next, before, row []int, previous, r rune) {
+	next[0] = row[0] + 1
+	for j := 1; j < len(row); j++ {
+		cost := 1
+		if l.word[j-1] == r {
+			cost = 0
+		}
+		next[j] = min(row[j]+1, next[j-1]+1, row[j-1]+cost)
+		if before != nil && j > 1 && l.word[j-1] == previous && l.word[j-2] == r {
+			next[j] = min(next[j], before[j-2]+1)
+		}
+	}
+}
+
+// accepts returns the distance of the word read to the automaton's word, if close enough
+func (l levenshtein) accepts(row []int) (int, bool) {
+	d := row[len(row)-1]
+	return d, d <= l.distance
+}
+
+// canMatch reports whether some word starting with the letters read can be accepted:
+// the distance never goes down as letters are added
+func (l levenshtein) canMatch(row []int) bool {
+	for _, d := range row {
+		if d <= l.distance {
+			return true
+		}
+	}
+	return false
+}
+
+// match runs the automaton over a sorted list of words and returns the ones it accepts
+func (l levenshtein) match(words []string) []FuzzyMatch {
+	var matches []FuzzyMatch
+
+	// states[k] is the state after the first k letters of the current word,
+	// letters[k] its k+1th letter and ends[k] the end of that letter in the word
+	states := [][]int{l.start()}
+	var letters []rune
+	var ends []int
+	previousWord := ""
+
+	for i := 0; i < len(words); {
+		word := words[i]
+
+		// keep the states of the letters shared with the previous word
+		shared := 0
+		for shared < len(word) && shared < len(previousWord) && word[shared] == previousWord[shared] {
+			shared++
+		}
+		k := 0
+		for k < len(ends) && ends[k] <= shared {
+			k++
+		}
+		letters, ends = letters[:k], ends[:k]
+		previousWord = word
+
+		offset := 0
+		if k > 0 {
+			offset = ends[k-1]
+		}
+		dead := false
+		for offset < len(word) {
+			r, size := utf8.DecodeRuneInString(word[offset:])
+
+			if len(states) == k+1 {
+				states = append(states, make([]int, len(l.word)+1))
+			}
+			var before []int
+			var previous rune
+			if k > 0 {
+				before, previous = states[k-1], letters[k-1]
+			}
+			l.step(states[k+1], before, states[k], previous, r)
+			offset += size
+			letters, ends = append(letters, r), append(ends, offset)
+			k++
+
+			if !l.canMatch(states[k]) {
+				// skip every word starting with these letters
+				prefix := word[:offset]
+				i += sort.Search(len(words)-i, func(n int) bool {
+					return !strings.HasPrefix(words[i+n], prefix)
+				})
+				dead = true
+				break
+			}
+		}
+		if dead {
+			continue
+		}
+
+		if d, ok := l.accepts(states[k]); ok {
+			matches = append(matches, FuzzyMatch{Word: word, Distance: d})
+		}
+		i++
+	}
+	return matches
+}
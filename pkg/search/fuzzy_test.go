@@ -0,0 +1,131 @@
+package search
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// osaDistance is the optimal string alignment distance computed with the whole
+// table: insertions, deletions, substitutions and transpositions of neighbour letters
+func osaDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+// bruteForceMatches returns the words at most distance edits away, in vocabulary order
+func bruteForceMatches(vocabulary []string, word string, distance int) []FuzzyMatch {
+	var matches []FuzzyMatch
+	for _, w := range vocabulary {
+		if d := osaDistance(word, w); d <= distance {
+			matches = append(matches, FuzzyMatch{Word: w, Distance: d})
+		}
+	}
+	return matches
+}
+
+func TestLevenshteinMatchesBruteForce(t *testing.T) {
+	vocabulary := []string{
+		"a", "ab", "abc", "ba", "whale", "whales", "whaling", "wahle", "while", "whole",
+		"what", "wheat", "hwale", "shale", "sale", "scale", "ocean", "oceans", "ocaen",
+		"café", "cafés", "cafe", "caf", "acfé", "naïve", "naive", "gödel", "godel", "ögdel",
+		"straße", "strasse", "été", "ete", "tée", "ωμέγα", "ωμεγα",
+	}
+	sort.Strings(vocabulary)
+
+	words := []string{
+		"", "a", "ab", "ba", "whale", "wahle", "hwale", "whael", "ocean", "oecan",
+		"café", "cafe", "acfé", "naive", "gödel", "ögdel", "strase", "été", "tée", "ωμεγα", "μωεγα",
+	}
+
+	for distance := 0; distance <= MaxEditDistance; distance++ {
+		for _, word := range words {
+			got := newLevenshtein(word, distance).match(vocabulary)
+			want := bruteForceMatches(vocabulary, word, distance)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%q~%d:\n got %v\nwant %v", word, distance, got, want)
+			}
+		}
+	}
+}
+
+// TestLevenshteinRandomWords compares the automaton with the brute force distance on
+// many words sharing prefixes, which exercises the reuse of states and the skips
+func TestLevenshteinRandomWords(t *testing.T) {
+	letters := []rune("abcé")
+	rng := rand.New(rand.NewSource(1))
+	randomWord := func() string {
+		word := make([]rune, rng.Intn(6))
+		for i := range word {
+			word[i] = letters[rng.Intn(len(letters))]
+		}
+		return string(word)
+	}
+
+	seen := make(map[string]bool)
+	var vocabulary []string
+	for len(vocabulary) < 300 {
+		if word := randomWord(); !seen[word] {
+			seen[word] = true
+			vocabulary = append(vocabulary, word)
+		}
+	}
+	sort.Strings(vocabulary)
+
+	for distance := 0; distance <= MaxEditDistance; distance++ {
+		for n := 0; n < 100; n++ {
+			word := randomWord()
+			got := newLevenshtein(word, distance).match(vocabulary)
+			want := bruteForceMatches(vocabulary, word, distance)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%q~%d:\n got %v\nwant %v", word, distance, got, want)
+			}
+		}
+	}
+}
+
+func TestOSADistanceTranspositions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"whale", "wahle", 1},
+		{"whale", "hwale", 1},
+		{"café", "caéf", 1},
+		{"ca", "abc", 3}, // no edit of a transposed pair in optimal string alignment
+		{"gödel", "godel", 1},
+	}
+	for _, tt := range tests {
+		if got := osaDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("osaDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		l := newLevenshtein(tt.a, MaxEditDistance)
+		got := l.match([]string{tt.b})
+		if accepted := len(got) == 1; accepted != (tt.want <= MaxEditDistance) {
+			t.Errorf("automaton for %q on %q: got %v", tt.a, tt.b, got)
+		} else if accepted && got[0].Distance != tt.want {
+			t.Errorf("automaton distance of %q to %q = %d, want %d", tt.b, tt.a, got[0].Distance, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// GlobSearch finds books containing words matching glob patterns (see GlobQuery).
+// With a LimitError, the results are those found before the limit.
+func GlobSearch(ctx context.Context, idx *indexer.Indexer, query string) ([]models.SearchResult, error) {
+	q, err := GlobQuery(ctx, idx, query)
+	if err != nil && !IsLimit(err) {
+		return nil, err
+	}
+	results, scoreErr := ScoreTerms(ctx, idx, q.Terms, LocalStats(idx, q.Terms))
+	if scoreErr != nil {
+		return results, scoreErr
+	}
+	return results, err
+}
+
+// GlobQuery builds the query of a glob search: every word of the query is a pattern
+// in which * stands for any letters and ? for one letter (wha*, *ness, wom?n).
+// The words matched by each pattern are listed in Variants. Past the limits, the
+// query is returned with a LimitError and the terms found until then.
+func GlobQuery(ctx context.Context, idx *indexer.Indexer, query string) (Query, error) {
+	q := Query{Variants: make(map[string][]string)}
+	matching := newTermSet(ctx)
+
+	var patterns []string
+	var limit error
+	for _, glob := range strings.Fields(normalizeWord(query)) {
+		patterns = append(patterns, globPattern(glob))
+
+		terms, words, err := GlobTerms(ctx, idx, glob)
+		if err != nil && !IsLimit(err) {
+			return Query{}, err
+		}
+		q.Variants[glob] = words
+		if addErr := matching.add(terms); err == nil {
+			err = addErr
+		}
+		if err != nil {
+			limit = err
+			break
+		}
+	}
+
+	q.Terms = matching.terms
+	if len(patterns) > 0 {
+		q.Pattern = regexp.MustCompile(`^(?:` + strings.Join(patterns, "|") + `)$`)
+	}
+	return q, limit
+}
+
+// GlobTerms returns the terms of the words of the books matching a glob pattern,
+// and those words. Only part of the vocabulary is read: the words starting with the
+// letters before the first wildcard (wha*), or else ending with the letters after
+// the last one (*ness). Only patterns with wildcards at both ends (*hal*) read it all.
+// Past the limits, they are returned with a LimitError.
+func GlobTerms(ctx context.Context, idx *indexer.Indexer, glob string) (terms []string, words []string, err error) {
+	if err := checkGlob(glob); err != nil {
+		return nil, nil, err
+	}
+	vocabulary := idx.Vocabulary()
+
+	var candidates []string
+	prefix := glob[:strings.IndexAny(glob+"*", "*?")]
+	suffix := glob[strings.LastIndexAny(glob, "*?")+1:]
+	switch {
+	case prefix == glob:
+		// no wildcard
+		if len(vocabulary.Terms(glob)) > 0 {
+			candidates = []string{glob}
+		}
+	case prefix != "":
+		candidates = vocabulary.WithPrefix(prefix)
+	case suffix != "":
+		candidates = vocabulary.WithSuffix(suffix)
+	default:
+		candidates = vocabulary.Words
+	}
+
+	re := regexp.MustCompile(`^` + globPattern(glob) + `$`)
+	matching := newTermSet(ctx)
+	words = []string{}
+	for i, word := range candidates {
+		if i%checkEvery == 0 {
+			if err = interrupted(ctx); err != nil {
+				break
+			}
+		}
+		if !re.MatchString(word) {
+			continue
+		}
+		if err = matching.add(vocabulary.Terms(word)); err != nil {
+			break
+		}
+		words = append(words, word)
+	}
+	// words read backwards are in the order of their endings
+	sort.Strings(words)
+	return matching.terms, words, err
+}
+
+// ValidateGlob checks the patterns of a glob query without searching
+func ValidateGlob(query string) error {
+	for _, glob := range strings.Fields(query) {
+		if err := checkGlob(glob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGlob rejects the patterns made of wildcards only, which match every word
+func checkGlob(glob string) error {
+	if !strings.ContainsFunc(glob, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) {
+		return fmt.Errorf("glob pattern %q has no letters", glob)
+	}
+	return nil
+}
+
+// globPattern translates a glob pattern into a regular expression
+func globPattern(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+)
+
+func globIndex(t *testing.T) *indexer.Indexer {
+	t.Helper()
+	return newTestIndex(t,
+		testBook{text: "whale whalebone whaler wharf whaling sailing king shale"},
+		testBook{text: "whale sailing ring"},
+	)
+}
+
+// wordTerms returns the sorted terms of words of the vocabulary
+func wordTerms(idx *indexer.Indexer, words []string) []string {
+	seen := make(map[string]bool)
+	terms := []string{}
+	for _, word := range words {
+		for _, term := range idx.Vocabulary().Terms(word) {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+func TestGlobTerms(t *testing.T) {
+	idx := globIndex(t)
+
+	tests := []struct {
+		glob  string
+		words []string
+	}{
+		// Prefix range
+		{"wha*", []string{"whale", "whalebone", "whaler", "whaling", "wharf"}},
+		{"whal?", []string{"whale"}},
+		// Suffix range, over the reversed words
+		{"*ing", []string{"king", "ring", "sailing", "whaling"}},
+		{"?ing", []string{"king", "ring"}},
+		// Prefix range, then the pattern
+		{"w?al*", []string{"whale", "whalebone", "whaler", "whaling"}},
+		{"s*ing", []string{"sailing"}},
+		// Suffix range, then the pattern
+		{"*h?le", []string{"shale", "whale"}},
+		// Wildcards at both ends: the whole vocabulary
+		{"*hal*", []string{"shale", "whale", "whalebone", "whaler", "whaling"}},
+		// No wildcard: the word itself, if the books have it
+		{"whale", []string{"whale"}},
+		{"whal", []string{}},
+		{"x*", []string{}},
+		{"*x", []string{}},
+	}
+	for _, tt := range tests {
+		terms, words, err := GlobTerms(context.Background(), idx, tt.glob)
+		if err != nil {
+			t.Errorf("GlobTerms(%q): %v", tt.glob, err)
+			continue
+		}
+		if !reflect.DeepEqual(words, tt.words) {
+			t.Errorf("GlobTerms(%q) words = %v, want %v", tt.glob, words, tt.words)
+		}
+		sort.Strings(terms)
+		if want := wordTerms(idx, tt.words); !reflect.DeepEqual(terms, want) {
+			t.Errorf("GlobTerms(%q) terms = %v, want %v", tt.glob, terms, want)
+		}
+	}
+
+	for _, glob := range []string{"*", "?*?", "**"} {
+		if _, _, err := GlobTerms(context.Background(), idx, glob); err == nil {
+			t.Errorf("GlobTerms(%q) succeeded, want an error", glob)
+		}
+	}
+}
+
+func TestGlobTermsLimit(t *testing.T) {
+	idx := globIndex(t)
+
+	ctx, cancel := Limits{MaxTerms: 2}.Context(context.Background())
+	defer cancel()
+
+	terms, words, err := GlobTerms(ctx, idx, "wha*")
+	var limit *LimitError
+	if !errors.As(err, &limit) || limit.Limit != LimitTerms {
+		t.Fatalf("GlobTerms past MaxTerms: err = %v, want a terms LimitError", err)
+	}
+	if want := "search stopped after 2 matching terms"; err.Error() != want {
+		t.Errorf("LimitError = %q, want %q", err, want)
+	}
+	if len(terms) != 2 || len(words) == 0 {
+		t.Errorf("partial result: terms %v, words %v, want 2 terms", terms, words)
+	}
+
+	// The query keeps the terms found and reports the limit
+	q, err := GlobQuery(ctx, idx, "wha* *ing")
+	if !IsLimit(err) {
+		t.Fatalf("GlobQuery past MaxTerms: err = %v, want a LimitError", err)
+	}
+	if len(q.Terms) != 2 {
+		t.Errorf("GlobQuery terms = %v, want the 2 found", q.Terms)
+	}
+	results, err := GlobSearch(ctx, idx, "wha*")
+	if !IsLimit(err) || len(results) == 0 {
+		t.Errorf("GlobSearch past MaxTerms: %d results, err %v; want results and a LimitError", len(results), err)
+	}
+
+	// Without limits in its context, a search has none
+	if _, _, err := GlobTerms(context.Background(), idx, "wha*"); err != nil {
+		t.Errorf("GlobTerms without limits: %v", err)
+	}
+
+	// A canceled search is not a limit
+	cancel()
+	if _, _, err := GlobTerms(ctx, idx, "*hal*"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GlobTerms with a canceled context: err = %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+const (
+	// MaxBookMatches is the number of matches a search inside a book returns at most
+	MaxBookMatches = 5000
+
+	// contextLength is the length of the text shown around a match, in bytes
+	contextLength = 120
+)
+
+// Matches returns every place a book matches the query: the occurrences of its
+// phrases, and its other words. content is the book file; language and chapters are the book's.
+// truncated is true when there were more than MaxBookMatches.
+func (h *Highlighter) Matches(content, language string, chapters []models.Chapter) (matches []models.Match, truncated bool) {
+	if len(h.words) == 0 {
+		return []models.Match{}, false
+	}
+
+	text := indexer.SplitGutenberg(content)
+	body := text.Body
+
+	// Words of a phrase only count inside the phrase, hence the extra hits
+	hits := h.findHits(body, indexer.StopWordsFor(language), 10*MaxBookMatches)
+	truncated = len(hits) == 10*MaxBookMatches
+
+	var spans []span
+	inPhrase := make(map[int]bool)
+	for _, p := range h.findPhrases(hits) {
+		spans = append(spans, span{hits[p[0]].start, hits[p[1]].end})
+		for i := p[0]; i <= p[1]; i++ {
+			inPhrase[i] = true
+		}
+	}
+	for i, hit := range hits {
+		if !inPhrase[i] && h.plain[hit.word] {
+			spans = append(spans, span{hit.start, hit.end})
+		}
+	}
+	sort.Slice(spans, func(a, b int) bool { return spans[a].start < spans[b].start })
+
+	// Overlapping phrases ("white whale", "whale ship") are kept as the first one
+	kept := spans[:0]
+	for _, s := range spans {
+		if len(kept) > 0 && s.start < kept[len(kept)-1].end {
+			continue
+		}
+		kept = append(kept, s)
+	}
+	spans = kept
+
+	if len(spans) > MaxBookMatches {
+		spans = spans[:MaxBookMatches]
+		truncated = true
+	}
+
+	// Lines and character offsets are counted in the whole file, and in the chapter
+	matches = make([]models.Match, 0, len(spans))
+	line, lineStart, chars, pos := 1, 0, 0, 0
+	advance := func(to int) {
+		for pos < to {
+			r, size := utf8.DecodeRuneInString(content[pos:])
+			if r == '\n' {
+				line++
+				lineStart = chars + 1
+			}
+			chars++
+			pos += size
+		}
+	}
+
+	chapter, chapterStart := 0, 0
+	for _, s := range spans {
+		start := text.BodyStart + s.start
+		for chapter < len(chapters) && chapters[chapter].Start <= start {
+			advance(chapters[chapter].Start)
+			chapterStart = chars
+			chapter++
+		}
+		advance(start)
+
+		from, to := passage(body, s.start, s.end, contextLength)
+		matches = append(matches, models.Match{
+			Line:          line,
+			Column:        chars - lineStart,
+			Offset:        chars,
+			Length:        utf8.RuneCountInString(body[s.start:s.end]),
+			Chapter:       chapter,
+			ChapterOffset: chars - chapterStart,
+			Text:          body[s.start:s.end],
+			Context:       fragment(body, span{from, to}, []span{s}),
+		})
+	}
+	return matches, truncated
+}
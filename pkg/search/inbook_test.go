@@ -0,0 +1,102 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// position returns the line, column and character offset of a byte offset of a text
+func position(content string, offset int) (line, column, chars int) {
+	before := content[:offset]
+	line = strings.Count(before, "\n") + 1
+	column = utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:])
+	return line, column, utf8.RuneCountInString(before)
+}
+
+func TestMatches(t *testing.T) {
+	idx := newTestIndex(t, testBook{
+		text: "CHAPTER I. L'été\n\n" +
+			"À l'aube, «la baleine» <b>blanche</b> nageait.\n" +
+			"Puis la baleine blanche plongea, et la baleine disparut.\n\n" +
+			"CHAPTER II. Œuvres\n\n" +
+			"Naïve, la baleine blanche revint.\n",
+	})
+	content := bookContent(t, idx, 1)
+	chapters := idx.Chapters[1]
+	if len(chapters) != 2 {
+		t.Fatalf("chapters = %+v, want 2", chapters)
+	}
+
+	tests := []struct {
+		query, searchType string
+		texts             []string
+	}{
+		{"baleine", "", []string{"baleine", "baleine", "baleine", "baleine"}},
+		// A phrase is one match, its words don't match alone
+		{`"baleine blanche"`, "", []string{"baleine» <b>blanche", "baleine blanche", "baleine blanche"}},
+		{"bal.*", "regex", []string{"baleine", "baleine", "baleine", "baleine"}},
+		{"na?ve", "glob", []string{"Naïve"}},
+	}
+	for _, tt := range tests {
+		q, err := MatchQuery(context.Background(), idx, tt.query, tt.searchType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matches, truncated := NewHighlighter(idx, q).Matches(content, "en", chapters)
+		if truncated {
+			t.Errorf("%s: truncated", tt.query)
+		}
+		if len(matches) != len(tt.texts) {
+			t.Errorf("%s: %d matches %+v, want %d", tt.query, len(matches), matches, len(tt.texts))
+			continue
+		}
+
+		from := 0
+		for i, m := range matches {
+			if m.Text != tt.texts[i] {
+				t.Errorf("%s match %d = %q, want %q", tt.query, i, m.Text, tt.texts[i])
+			}
+			// Lines, columns and offsets count characters, not bytes
+			start := from + strings.Index(content[from:], m.Text)
+			from = start + len(m.Text)
+			line, column, chars := position(content, start)
+			if m.Line != line || m.Column != column || m.Offset != chars || m.Length != utf8.RuneCountInString(m.Text) {
+				t.Errorf("%s match %d at line %d, column %d, offset %d, length %d; want %d, %d, %d, %d",
+					tt.query, i, m.Line, m.Column, m.Offset, m.Length, line, column, chars, utf8.RuneCountInString(m.Text))
+			}
+			if got := string([]rune(content)[m.Offset : m.Offset+m.Length]); got != m.Text {
+				t.Errorf("%s match %d: characters %d+%d are %q", tt.query, i, m.Offset, m.Length, got)
+			}
+
+			var ch models.Chapter
+			if m.Chapter >= 1 && m.Chapter <= len(chapters) {
+				ch = chapters[m.Chapter-1]
+			}
+			if start < ch.Start || start >= ch.End || m.ChapterOffset != m.Offset-ch.Offset {
+				t.Errorf("%s match %d in chapter %d at %d, the chapter is %d-%d", tt.query, i, m.Chapter, m.ChapterOffset, ch.Start, ch.End)
+			}
+
+			// The context is escaped, with the match marked
+			if !strings.Contains(m.Context, "<mark>") || strings.Contains(m.Context, "<b>") {
+				t.Errorf("%s match %d context = %s", tt.query, i, m.Context)
+			}
+		}
+	}
+}
+
+func TestMatchesLimit(t *testing.T) {
+	idx := newTestIndex(t, testBook{text: strings.Repeat("whale ", MaxBookMatches+10)})
+	matches, truncated := NewHighlighter(idx, KeywordQuery(idx, "whale")).Matches(bookContent(t, idx, 1), "en", idx.Chapters[1])
+	if len(matches) != MaxBookMatches || !truncated {
+		t.Errorf("%d matches, truncated %v; want %d, truncated", len(matches), truncated, MaxBookMatches)
+	}
+
+	// Nothing to find
+	if matches, _ := NewHighlighter(idx, KeywordQuery(idx, "zanzibar")).Matches(bookContent(t, idx, 1), "en", nil); len(matches) != 0 {
+		t.Errorf("matches of a word not in the book = %v", matches)
+	}
+}
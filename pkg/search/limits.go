@@ -0,0 +1,124 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Limits bound the work of a search, zero meaning no limit. A search runs under
+// the limits of its context (see Context), and without limits if it has none.
+type Limits struct {
+	// MaxTerms is the number of matching terms searched at most: a pattern like .*
+	// matches the whole vocabulary, and every term is a posting list to read
+	MaxTerms int
+	// MaxResults is the number of books kept at most, the most relevant ones
+	MaxResults int
+	// Timeout is the time a search may take
+	Timeout time.Duration
+}
+
+// DefaultLimits are the limits of the server's searches, unless its flags change them
+var DefaultLimits = Limits{
+	MaxTerms:   10000,
+	MaxResults: 10000,
+	Timeout:    10 * time.Second,
+}
+
+// The limits a search can reach
+const (
+	LimitTerms   = "terms"
+	LimitResults = "results"
+	LimitTime    = "time"
+)
+
+// checkEvery is the number of words or postings read between two checks of the context
+const checkEvery = 1024
+
+// LimitError reports a limit reached by a search. The results returned
+// with it are the ones found until then.
+type LimitError struct {
+	Limit string
+	// Limits are the limits the search ran under
+	Limits Limits
+}
+
+func (e *LimitError) Error() string {
+	switch e.Limit {
+	case LimitTerms:
+		return fmt.Sprintf("search stopped after %d matching terms", e.Limits.MaxTerms)
+	case LimitResults:
+		return fmt.Sprintf("search kept the %d best books", e.Limits.MaxResults)
+	default:
+		return fmt.Sprintf("search stopped after %v", e.Limits.Timeout)
+	}
+}
+
+// limitsKey is the key of the limits in the context of a search
+type limitsKey struct{}
+
+// Context returns the context of a search under these limits, ending after their timeout
+func (l Limits) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx := context.WithValue(parent, limitsKey{}, l)
+	if l.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, l.Timeout)
+}
+
+// limitsOf returns the limits of the context of a search, none if it has none
+func limitsOf(ctx context.Context) Limits {
+	l, _ := ctx.Value(limitsKey{}).(Limits)
+	return l
+}
+
+// interrupted returns why a search must stop, if it must: a LimitError when its
+// time ran out, the context's error when it was canceled (the client went away)
+func interrupted(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &LimitError{Limit: LimitTime, Limits: limitsOf(ctx)}
+	}
+	return err
+}
+
+// IsLimit reports whether err is a LimitError, after which a search
+// goes on with what it found
+func IsLimit(err error) bool {
+	var limit *LimitError
+	return errors.As(err, &limit)
+}
+
+// Partial lists the limits reached by the steps of a search
+type Partial []string
+
+// Check records the limit reached by a step of a search and returns
+// the other errors, which end the search
+func (p *Partial) Check(err error) error {
+	var limit *LimitError
+	if !errors.As(err, &limit) {
+		return err
+	}
+	p.Add(limit.Limit)
+	return nil
+}
+
+// Add records a limit reached, once
+func (p *Partial) Add(limits ...string) {
+	for _, limit := range limits {
+		if !p.Has(limit) {
+			*p = append(*p, limit)
+		}
+	}
+}
+
+// Has reports whether a limit was reached
+func (p Partial) Has(limit string) bool {
+	for _, l := range p {
+		if l == limit {
+			return true
+		}
+	}
+	return false
+}
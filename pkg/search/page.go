@@ -0,0 +1,107 @@
+package search
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// Cursor is the place of a result in the ranking (see SortResults). The page after a
+// result starts right after its cursor, which stays true whatever the size of the
+// pages or the number of pages read before.
+type Cursor struct {
+	Relevance float64 `json:"relevance"`
+	BookID    int     `json:"book_id"`
+}
+
+// CursorOf returns the cursor of a result
+func CursorOf(r models.SearchResult) Cursor {
+	return Cursor{Relevance: r.Relevance, BookID: r.Book.ID}
+}
+
+// String encodes the cursor for a URL
+func (c Cursor) String() string {
+	text := strconv.FormatFloat(c.Relevance, 'g', -1, 64) + ":" + strconv.Itoa(c.BookID)
+	return base64.RawURLEncoding.EncodeToString([]byte(text))
+}
+
+// ParseCursor decodes a cursor encoded by String, rejecting malformed ones
+func ParseCursor(s string) (Cursor, error) {
+	invalid := errors.New("invalid cursor")
+	text, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, invalid
+	}
+	relevance, bookID, found := strings.Cut(string(text), ":")
+	if !found {
+		return Cursor{}, invalid
+	}
+
+	var c Cursor
+	// NaN and infinities are not the relevance of any result and don't rank
+	if c.Relevance, err = strconv.ParseFloat(relevance, 64); err != nil || math.IsNaN(c.Relevance) || math.IsInf(c.Relevance, 0) {
+		return Cursor{}, invalid
+	}
+	if c.BookID, err = strconv.Atoi(bookID); err != nil {
+		return Cursor{}, invalid
+	}
+	return c, nil
+}
+
+// TopResults returns the k best results, in the order of SortResults. Instead of
+// sorting all the results, the best ones are kept in a heap of k results as they
+// are read. With k <= 0, all of them are returned. results is left unchanged:
+// ranked results may be shared (see the server's result cache).
+func TopResults(results []models.SearchResult, k int) []models.SearchResult {
+	return topResults(results, k, nil)
+}
+
+// TopResultsAfter returns the k best results ranked after a cursor, like TopResults
+func TopResultsAfter(results []models.SearchResult, c Cursor, k int) []models.SearchResult {
+	at := models.SearchResult{Book: models.Book{ID: c.BookID}, Relevance: c.Relevance}
+	return topResults(results, k, func(r models.SearchResult) bool { return ranksBefore(at, r) })
+}
+
+func topResults(results []models.SearchResult, k int, keep func(models.SearchResult) bool) []models.SearchResult {
+	top := worstFirst{}
+	for _, r := range results {
+		if keep != nil && !keep(r) {
+			continue
+		}
+		if k <= 0 || len(top) < k {
+			top = append(top, r)
+			if len(top) == k {
+				heap.Init(&top)
+			}
+			continue
+		}
+		if ranksBefore(r, top[0]) {
+			top[0] = r
+			heap.Fix(&top, 0)
+		}
+	}
+
+	SortResults(top)
+	return top
+}
+
+// worstFirst is a heap of results whose top is the one ranked last
+type worstFirst []models.SearchResult
+
+func (h worstFirst) Len() int           { return len(h) }
+func (h worstFirst) Less(a, b int) bool { return ranksBefore(h[b], h[a]) }
+func (h worstFirst) Swap(a, b int)      { h[a], h[b] = h[b], h[a] }
+func (h *worstFirst) Push(x interface{}) {
+	*h = append(*h, x.(models.SearchResult))
+}
+func (h *worstFirst) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
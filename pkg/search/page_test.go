@@ -0,0 +1,127 @@
+package search
+
+import (
+	"encoding/base64"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// randomResults returns results in no particular order, many of them with the
+// same relevance so that the book ID decides their order
+func randomResults(rng *rand.Rand, n int) []models.SearchResult {
+	relevances := []float64{0.1, 1.0 / 3, 2.5, 7, rng.Float64() * 10}
+	results := make([]models.SearchResult, n)
+	for i, id := range rng.Perm(n) {
+		results[i] = models.SearchResult{
+			Book:      models.Book{ID: id + 1},
+			Relevance: relevances[rng.Intn(len(relevances))],
+		}
+	}
+	return results
+}
+
+func bookIDs(results []models.SearchResult) []int {
+	ids := []int{}
+	for _, r := range results {
+		ids = append(ids, r.Book.ID)
+	}
+	return ids
+}
+
+func TestTopResults(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	results := randomResults(rng, 200)
+	original := append([]models.SearchResult(nil), results...)
+
+	sorted := append([]models.SearchResult(nil), results...)
+	SortResults(sorted)
+
+	for _, k := range []int{0, -1, 1, 5, 199, 200, 500} {
+		want := sorted
+		if k > 0 && k < len(sorted) {
+			want = sorted[:k]
+		}
+		if got := TopResults(results, k); !reflect.DeepEqual(bookIDs(got), bookIDs(want)) {
+			t.Errorf("TopResults(%d) = %v, want %v", k, bookIDs(got), bookIDs(want))
+		}
+	}
+
+	if !reflect.DeepEqual(results, original) {
+		t.Error("TopResults changed its input")
+	}
+}
+
+func TestCursorPaging(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	results := randomResults(rng, 103)
+	original := append([]models.SearchResult(nil), results...)
+
+	sorted := append([]models.SearchResult(nil), results...)
+	SortResults(sorted)
+	want := bookIDs(sorted)
+
+	for _, perPage := range []int{1, 3, 10, 103, 200} {
+		var got []int
+		page := TopResults(results, perPage)
+		for len(page) > 0 && len(got) <= len(results) {
+			got = append(got, bookIDs(page)...)
+
+			// The cursor goes through the URL of the next page
+			cursor, err := ParseCursor(CursorOf(page[len(page)-1]).String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			page = TopResultsAfter(results, cursor, perPage)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("pages of %d: got %v, want %v", perPage, got, want)
+		}
+	}
+
+	if !reflect.DeepEqual(results, original) {
+		t.Error("TopResultsAfter changed its input")
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, c := range []Cursor{
+		{Relevance: 1.0 / 3, BookID: 1342},
+		{Relevance: 0, BookID: 1},
+		{Relevance: 12345.678901234567, BookID: 2701},
+		{Relevance: 5e-324, BookID: 7},
+	} {
+		got, err := ParseCursor(c.String())
+		if err != nil || got != c {
+			t.Errorf("ParseCursor(%v.String()) = %v, %v", c, got, err)
+		}
+	}
+}
+
+func TestParseCursorInvalid(t *testing.T) {
+	encode := func(text string) string { return base64.RawURLEncoding.EncodeToString([]byte(text)) }
+	valid := Cursor{Relevance: 2.5, BookID: 1342}.String()
+
+	for _, s := range []string{
+		"",
+		"not base64!",
+		valid + "=",                // padded
+		"*" + valid[1:],            // character out of the alphabet
+		valid[:len(valid)-1] + "+", // standard base64, not URL
+		encode("2.5"),
+		encode("2.5:"),
+		encode(":1342"),
+		encode("high:1342"),
+		encode("2.5:1342:1"),
+		encode("2.5:book"),
+		encode("NaN:1342"),
+		encode("Inf:1342"),
+		encode("-Inf:1342"),
+	} {
+		if c, err := ParseCursor(s); err == nil {
+			t.Errorf("ParseCursor(%q) = %v, want an error", s, c)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package search
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// FieldBoosts weighs the score of a match in a metadata field against a match
+// in the text (weight 1): finding the words in the title says more about a book
+var FieldBoosts = map[string]float64{
+	"title":    2.5,
+	"subtitle": 1.5,
+	"author":   2,
+	"subject":  1.5,
+}
+
+// BoostedFields are the fields searched, in addition to the text, for the words
+// of a query that are not prefixed by a field
+var BoostedFields = []string{"title", "subtitle", "author", "subject"}
+
+// FieldBoost returns the weight of the matches of a term (1 for words of the text)
+func FieldBoost(term string) float64 {
+	field, _ := indexer.SplitFieldTerm(term)
+	if boost, found := FieldBoosts[field]; found {
+		return boost
+	}
+	return 1
+}
+
+// Clause is a part of a query: words to find in a field, or anywhere if Field is "".
+// Phrase marks quoted words of the text, which must all be in a book.
+// Fuzzy marks a word that also matches the words of the books close to it (wahle~).
+type Clause struct {
+	Field  string
+	Text   string
+	Phrase bool
+	Fuzzy  bool
+	// Distance is the number of edits allowed for a fuzzy word, 0 for AutoDistance
+	Distance int
+}
+
+// fuzzySuffix matches a fuzzy word: wahle~, or wahle~1 and wahle~2 with their number of edits
+var fuzzySuffix = regexp.MustCompile(`^([^~"]+)~([12]?)$`)
+
+// ParseQuery splits a query like `author:melville "white whale"` into clauses.
+// A field applies to the next word, or to a quoted group of words (title:"moby dick").
+// Unknown fields are kept as plain words; a quoted group without a field is a phrase;
+// a plain word ending with ~ is fuzzy.
+func ParseQuery(query string) []Clause {
+	var clauses []Clause
+	var plain []string
+
+	for rest := strings.TrimSpace(query); rest != ""; rest = strings.TrimSpace(rest) {
+		word := nextWord(&rest)
+
+		if i := strings.IndexByte(word, ':'); i > 0 && indexer.IsMetadataField(strings.ToLower(word[:i])) {
+			field := strings.ToLower(word[:i])
+			text := word[i+1:]
+			if text == "" {
+				// title: "moby dick", with a space after the colon
+				rest = strings.TrimSpace(rest)
+				text = nextWord(&rest)
+			}
+			if text = strings.Trim(text, `"`); text != "" {
+				clauses = append(clauses, Clause{Field: field, Text: text})
+			}
+			continue
+		}
+		if text := strings.Trim(word, `"`); text != word && strings.ContainsAny(text, " \t") {
+			clauses = append(clauses, Clause{Text: text, Phrase: true})
+			continue
+		}
+		if m := fuzzySuffix.FindStringSubmatch(word); m != nil {
+			distance, _ := strconv.Atoi(m[2])
+			clauses = append(clauses, Clause{Text: m[1], Fuzzy: true, Distance: distance})
+			continue
+		}
+		plain = append(plain, strings.Trim(word, `"`))
+	}
+
+	if len(plain) > 0 {
+		clauses = append([]Clause{{Text: strings.Join(plain, " ")}}, clauses...)
+	}
+	return clauses
+}
+
+// nextWord cuts the next word off a query; a quoted group counts as one word
+func nextWord(rest *string) string {
+	s := *rest
+	end := 0
+	inQuotes := false
+	for end < len(s) {
+		c := s[end]
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if !inQuotes && (c == ' ' || c == '\t') {
+			break
+		}
+		end++
+	}
+	*rest = s[end:]
+	return s[:end]
+}
+
+// Query is what a search looks for in the index: the terms that are scored and,
+// for the words given with a field or in a phrase, groups of terms of which a book must contain one
+type Query struct {
+	Terms    []string   `json:"terms"`
+	Required [][]string `json:"required,omitempty"`
+	// Phrases lists the quoted groups of words, each word being the group of terms it gives.
+	// The index has no word positions: a book matches a phrase if it has all its words,
+	// snippets show where they follow each other.
+	Phrases [][][]string `json:"phrases,omitempty"`
+	// Pattern is the pattern of a regex search, nil otherwise
+	Pattern *regexp.Regexp `json:"-"`
+	// Variants maps each fuzzy word or glob pattern of the query to the words of the books it matched
+	Variants map[string][]string `json:"variants,omitempty"`
+}
+
+// Matches reports whether a book satisfies the required clauses of the query
+func (q Query) Matches(idx *indexer.Indexer, bookID int) bool {
+	for _, group := range q.Required {
+		found := false
+		for _, term := range group {
+			if _, ok := idx.Postings(term)[bookID]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// KeywordQuery builds the query of a keyword search. Plain words are looked up in
+// the text and in the boosted fields; every word given with a field (author:melville)
+// must be in that field of the books found, every word of a phrase in their text.
+func KeywordQuery(idx *indexer.Indexer, query string) Query {
+	return keywordQuery(idx, query, false)
+}
+
+// FuzzyQuery builds the query of a fuzzy search: a keyword search where every
+// plain word is fuzzy, with its own number of edits or AutoDistance
+func FuzzyQuery(idx *indexer.Indexer, query string) Query {
+	return keywordQuery(idx, query, true)
+}
+
+func keywordQuery(idx *indexer.Indexer, query string, fuzzy bool) Query {
+	var q Query
+	seen := make(map[string]bool)
+	add := func(terms []string) {
+		for _, term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				q.Terms = append(q.Terms, term)
+			}
+		}
+	}
+
+	for _, clause := range ParseQuery(query) {
+		if clause.Phrase {
+			var phrase [][]string
+			for _, word := range strings.Fields(clause.Text) {
+				if len(idx.QueryTerms(word)) == 0 {
+					continue // stop word
+				}
+				terms := KeywordTerms(idx, word)
+				q.Required = append(q.Required, terms)
+				phrase = append(phrase, terms)
+				add(terms)
+			}
+			if len(phrase) > 0 {
+				q.Phrases = append(q.Phrases, phrase)
+			}
+			continue
+		}
+		if clause.Fuzzy || (fuzzy && clause.Field == "") {
+			for _, word := range strings.Fields(clause.Text) {
+				if len(idx.QueryTerms(word)) == 0 {
+					continue // stop word
+				}
+				distance := clause.Distance
+				if distance == 0 {
+					distance = AutoDistance(word)
+				}
+				terms, variants := FuzzyTerms(idx, word, distance)
+				if variants == nil {
+					variants = []string{}
+				}
+				if q.Variants == nil {
+					q.Variants = make(map[string][]string)
+				}
+				q.Variants[strings.ToLower(word)] = variants
+				add(terms)
+				add(boostedFieldTerms(idx, terms))
+			}
+			continue
+		}
+		if clause.Field == "" {
+			add(KeywordTerms(idx, clause.Text))
+			for _, field := range BoostedFields {
+				add(FieldTerms(idx, field, clause.Text))
+			}
+			continue
+		}
+
+		// Each word is a requirement; its terms are the ones it gives with the
+		// analyzers of the different languages
+		for _, word := range strings.Fields(clause.Text) {
+			terms := FieldTerms(idx, clause.Field, word)
+			if len(idx.QueryTerms(word)) == 0 {
+				continue // stop word
+			}
+			q.Required = append(q.Required, terms)
+			add(terms)
+		}
+	}
+	return q
+}
+
+// boostedFieldTerms returns the field terms of the boosted fields, for terms of the text,
+// that are in some book
+func boostedFieldTerms(idx *indexer.Indexer, terms []string) []string {
+	var fieldTerms []string
+	for _, field := range BoostedFields {
+		for _, term := range terms {
+			fieldTerm := indexer.FieldTerm(field, term)
+			if _, found := idx.Fields[fieldTerm]; found {
+				fieldTerms = append(fieldTerms, fieldTerm)
+			}
+		}
+	}
+	return fieldTerms
+}
+
+// ScoreQuery scores the books matching a query, leaving out those
+// that miss one of its required clauses. They are in no particular order:
+// TopResults picks the best ones. Past the limits, the books scored until
+// then are returned with a LimitError.
+func ScoreQuery(ctx context.Context, idx *indexer.Indexer, q Query, stats CorpusStats) ([]models.SearchResult, error) {
+	var keep func(bookID int) bool
+	if len(q.Required) > 0 {
+		keep = func(bookID int) bool { return q.Matches(idx, bookID) }
+	}
+	return scoreTerms(ctx, idx, q.Terms, stats, keep)
+}
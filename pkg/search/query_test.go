@@ -0,0 +1,78 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []Clause
+	}{
+		{"whale", []Clause{{Text: "whale"}}},
+		{`author:"herman melville" whale`, []Clause{{Text: "whale"}, {Field: "author", Text: "herman melville"}}},
+		{`Author: "herman melville"`, []Clause{{Field: "author", Text: "herman melville"}}},
+		{`title:moby dick`, []Clause{{Text: "dick"}, {Field: "title", Text: "moby"}}},
+		// Unknown prefixes are plain words
+		{"http://x", []Clause{{Text: "http://x"}}},
+		{"note:whale sea", []Clause{{Text: "note:whale sea"}}},
+		{`"white whale" sea`, []Clause{{Text: "sea"}, {Text: "white whale", Phrase: true}}},
+		{`"whale"`, []Clause{{Text: "whale"}}},
+		{"wahle~ wahle~2", []Clause{{Text: "wahle", Fuzzy: true}, {Text: "wahle", Fuzzy: true, Distance: 2}}},
+		{"wahle~3", []Clause{{Text: "wahle~3"}}},
+		{`author:`, nil},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseQuery(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestKeywordQuery(t *testing.T) {
+	idx := newTestIndex(t,
+		testBook{title: "Moby Dick", author: "Herman Melville", text: "Call me Ishmael. The whale."},
+		testBook{title: "Whale Songs", author: "Herman Hesse", text: "The whale sings."},
+		testBook{title: "Emma", author: "Jane Austen", text: "Emma Woodhouse, handsome, clever."},
+	)
+
+	search := func(query string) []int {
+		t.Helper()
+		q := KeywordQuery(idx, query)
+		results, err := ScoreQuery(context.Background(), idx, q, LocalStats(idx, q.Terms))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bookIDs(TopResults(results, 0))
+	}
+
+	if got := search(`author:"herman melville" whale`); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf(`author:"herman melville" whale = %v, want [1]`, got)
+	}
+	// Every word of the field is required, in any order
+	if got := search(`author:"melville herman"`); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf(`author:"melville herman" = %v, want [1]`, got)
+	}
+	// Plain words are also searched in the title, where they weigh more
+	if got := search("whale"); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("whale = %v, want [2 1]", got)
+	}
+	if got := search("author:hesse whale"); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("author:hesse whale = %v, want [2]", got)
+	}
+	if got := search("author:austen whale"); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("author:austen whale = %v, want [3]: plain words are not required", got)
+	}
+
+	// An unknown prefix is searched as words
+	q := KeywordQuery(idx, "http://x")
+	if len(q.Required) != 0 {
+		t.Errorf("http://x required %v", q.Required)
+	}
+	if got := search("note:whale"); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("note:whale = %v, want [2 1]", got)
+	}
+}
@@ -0,0 +1,186 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+)
+
+// BM25 parameters (the usual defaults)
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// CorpusStats are the collection statistics used for scoring.
+// When the books are split over several indexes (shards, segments),
+// the stats of every part are added up so all parts score with the same numbers.
+type CorpusStats struct {
+	TotalBooks int            `json:"total_books"`
+	TotalWords int            `json:"total_words"`
+	DocFreq    map[string]int `json:"doc_freq"`
+}
+
+// LocalStats computes the stats of one index for the given terms
+func LocalStats(idx *indexer.Indexer, terms []string) CorpusStats {
+	stats := CorpusStats{
+		TotalBooks: len(idx.Books),
+		TotalWords: idx.TotalWords,
+		DocFreq:    make(map[string]int, len(terms)),
+	}
+	for _, term := range terms {
+		stats.DocFreq[term] = len(idx.Postings(term))
+	}
+	return stats
+}
+
+// Add merges the stats of another part of the collection
+func (s *CorpusStats) Add(other CorpusStats) {
+	if s.DocFreq == nil {
+		s.DocFreq = make(map[string]int, len(other.DocFreq))
+	}
+	s.TotalBooks += other.TotalBooks
+	s.TotalWords += other.TotalWords
+	for term, df := range other.DocFreq {
+		s.DocFreq[term] += df
+	}
+}
+
+// Terms returns the terms the stats were computed for
+func (s CorpusStats) Terms() []string {
+	terms := make([]string, 0, len(s.DocFreq))
+	for term := range s.DocFreq {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// AvgBookLength is the average number of indexed words per book
+func (s CorpusStats) AvgBookLength() float64 {
+	if s.TotalBooks == 0 {
+		return 0
+	}
+	return float64(s.TotalWords) / float64(s.TotalBooks)
+}
+
+// IDF is the BM25 inverse document frequency of a term
+func (s CorpusStats) IDF(term string) float64 {
+	df := float64(s.DocFreq[term])
+	n := float64(s.TotalBooks)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// BM25TF is the saturated, length normalized term frequency part of BM25
+func BM25TF(tf int, bookLength int, avgBookLength float64) float64 {
+	if avgBookLength == 0 {
+		avgBookLength = float64(bookLength)
+	}
+	norm := 1 - bm25B
+	if avgBookLength > 0 {
+		norm += bm25B * float64(bookLength) / avgBookLength
+	}
+	f := float64(tf)
+	return f * (bm25K1 + 1) / (f + bm25K1*norm)
+}
+
+// ScoreTerms returns the books containing any of the terms, best first.
+// Occurrences is the total count of the terms in the book and
+// Relevance is the BM25 score computed with the given stats,
+// matches in metadata fields being weighted by FieldBoost.
+// Past the limits of ctx, the books scored until then are returned with a LimitError:
+// past MaxResults books, only the most relevant are kept.
+func ScoreTerms(ctx context.Context, idx *indexer.Indexer, terms []string, stats CorpusStats) ([]models.SearchResult, error) {
+	results, err := scoreTerms(ctx, idx, terms, stats, nil)
+	SortResults(results)
+	return results, err
+}
+
+// scoreTerms scores the books like ScoreTerms, leaving them in no particular order.
+// Only the books keep accepts (all if nil) are kept, before MaxResults applies.
+func scoreTerms(ctx context.Context, idx *indexer.Indexer, terms []string, stats CorpusStats, keep func(bookID int) bool) ([]models.SearchResult, error) {
+	avgLength := stats.AvgBookLength()
+
+	positions := make(map[int]int)
+	results := []models.SearchResult{}
+	var err error
+	read := 0
+
+terms:
+	for _, term := range terms {
+		idf := stats.IDF(term) * FieldBoost(term)
+		field, _ := indexer.SplitFieldTerm(term)
+
+		for bookID, count := range idx.Postings(term) {
+			if read%checkEvery == 0 {
+				if stop := interrupted(ctx); stop != nil {
+					err = stop
+					break terms
+				}
+			}
+			read++
+
+			book, exists := idx.Books[bookID]
+			if !exists {
+				continue
+			}
+
+			// Metadata fields are a few words long: the length of the book doesn't apply
+			length := book.WordCount
+			if field != "" {
+				length = int(avgLength)
+			}
+
+			pos, found := positions[bookID]
+			if !found {
+				pos = len(results)
+				positions[bookID] = pos
+				results = append(results, models.SearchResult{Book: book})
+			}
+			results[pos].Occurrences += count
+			results[pos].Relevance += idf * BM25TF(count, length, avgLength)
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+
+	if keep != nil {
+		kept := results[:0]
+		for _, r := range results {
+			if keep(r.Book.ID) {
+				kept = append(kept, r)
+			}
+		}
+		results = kept
+	}
+
+	// The books past the limit are the least relevant, whatever the order they were read in
+	limits := limitsOf(ctx)
+	if max := limits.MaxResults; max > 0 && len(results) > max {
+		results = TopResults(results, max)
+		if err == nil {
+			err = &LimitError{Limit: LimitResults, Limits: limits}
+		}
+	}
+	return results, err
+}
+
+// SortResults orders results by relevance, then by book ID so that
+// the order is stable across requests (and across shards)
+func SortResults(results []models.SearchResult) {
+	sort.Slice(results, func(i, j int) bool { return ranksBefore(results[i], results[j]) })
+}
+
+// ranksBefore reports whether a comes before b in the order of SortResults
+func ranksBefore(a, b models.SearchResult) bool {
+	if a.Relevance != b.Relevance {
+		return a.Relevance > b.Relevance
+	}
+	return a.Book.ID < b.Book.ID
+}
@@ -0,0 +1,60 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScoreQueryMaxResults(t *testing.T) {
+	// Books 1 to 11 say whale more and more, book 12 by Melville once, white
+	var books []testBook
+	for id := 1; id <= 11; id++ {
+		books = append(books, testBook{title: "Sea", text: strings.Repeat("whale ", id) + "sea"})
+	}
+	books = append(books, testBook{title: "Sea", author: "Herman Melville", text: "white whale sea"})
+	idx := newTestIndex(t, books...)
+
+	ctx, cancel := Limits{MaxResults: 3}.Context(context.Background())
+	defer cancel()
+
+	// The books kept are the most relevant, on every run
+	q := KeywordQuery(idx, "whale")
+	all, err := ScoreQuery(context.Background(), idx, q, LocalStats(idx, q.Terms))
+	if err != nil || len(all) != 12 {
+		t.Fatalf("ScoreQuery without limits: %d results, err %v", len(all), err)
+	}
+	want := bookIDs(TopResults(all, 3))
+	for run := 0; run < 20; run++ {
+		results, err := ScoreQuery(ctx, idx, q, LocalStats(idx, q.Terms))
+		var limit *LimitError
+		if !errors.As(err, &limit) || limit.Limit != LimitResults {
+			t.Fatalf("ScoreQuery past MaxResults: err = %v, want a results LimitError", err)
+		}
+		if err.Error() != "search kept the 3 best books" {
+			t.Errorf("LimitError = %q", err)
+		}
+		if got := bookIDs(TopResults(results, 0)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: kept %v, want the best %v", run, got, want)
+		}
+	}
+
+	// The required words are checked before the books are cut: the least
+	// relevant book matching whale is the only one by Melville
+	q = KeywordQuery(idx, "author:melville whale")
+	results, err := ScoreQuery(ctx, idx, q, LocalStats(idx, q.Terms))
+	if err != nil {
+		t.Errorf("ScoreQuery of one book: %v", err)
+	}
+	if got := bookIDs(results); !reflect.DeepEqual(got, []int{12}) {
+		t.Errorf("author:melville whale = %v, want [12]", got)
+	}
+
+	// A phrase requires all its words
+	q = KeywordQuery(idx, `"white whale"`)
+	if results, _ := ScoreQuery(ctx, idx, q, LocalStats(idx, q.Terms)); !reflect.DeepEqual(bookIDs(results), []int{12}) {
+		t.Errorf(`"white whale" = %v, want [12]`, bookIDs(results))
+	}
+}
@@ -1,8 +1,8 @@
 package search
 
 import (
+	"context"
 	"regexp"
-	"sort"
 	"strings"
 
 	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
@@ -10,37 +10,93 @@ import (
 )
 
 // Search finds books containing a keyword
-func Search(idx *indexer.Indexer, keyword string) []models.SearchResult {
-	keyword = strings.ToLower(keyword)
+func Search(ctx context.Context, idx *indexer.Indexer, keyword string) ([]models.SearchResult, error) {
+	terms := KeywordTerms(idx, keyword)
+	return ScoreTerms(ctx, idx, terms, LocalStats(idx, terms))
+}
 
-	bookOccurrences, found := idx.WordToBooks[keyword]
-	if !found {
-		return []models.SearchResult{}
+// RegexSearch finds books containing words matching a pattern.
+// With a LimitError, the results are those found before the limit.
+func RegexSearch(ctx context.Context, idx *indexer.Indexer, pattern string) ([]models.SearchResult, error) {
+	terms, err := RegexTerms(ctx, idx, pattern)
+	if err != nil && !IsLimit(err) {
+		return nil, err
 	}
+	results, scoreErr := ScoreTerms(ctx, idx, terms, LocalStats(idx, terms))
+	if scoreErr != nil {
+		return results, scoreErr
+	}
+	return results, err
+}
 
-	results := make([]models.SearchResult, 0, len(bookOccurrences))
-
-	for bookID, count := range bookOccurrences {
-		book, exists := idx.Books[bookID]
-		if !exists {
-			continue
+// MatchQuery parses a query for the given search type.
+// The type may also be a metadata field (e.g. "translator") to search that field only.
+// Patterns (regex, glob) stop matching terms at the limits: the query is then
+// returned with a LimitError and the terms found until then.
+func MatchQuery(ctx context.Context, idx *indexer.Indexer, query string, searchType string) (Query, error) {
+	if searchType == "regex" {
+		terms, err := RegexTerms(ctx, idx, query)
+		if err != nil && !IsLimit(err) {
+			return Query{}, err
 		}
+		return Query{Terms: terms, Pattern: regexp.MustCompile(query)}, err
+	}
+	if searchType == "glob" {
+		return GlobQuery(ctx, idx, query)
+	}
+	if searchType == "fuzzy" {
+		return FuzzyQuery(idx, query), nil
+	}
+	if indexer.IsMetadataField(searchType) {
+		return Query{Terms: FieldTerms(idx, searchType, query)}, nil
+	}
+	return KeywordQuery(idx, query), nil
+}
 
-		results = append(results, models.SearchResult{
-			Book:        book,
-			Occurrences: count,
-			Relevance:   float64(count),
-		})
+// NormalizeQuery returns the form of a query that gives the same results, for a
+// search type: its words as they are analyzed, separated by single spaces.
+// Regular expressions are kept as typed, case and spaces changing what they match.
+func NormalizeQuery(query, searchType string) string {
+	if searchType == "regex" {
+		return query
 	}
-	// sorting the book was missing, we got an output of random mactched book which is not efficient!!
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Occurrences > results[j].Occurrences
-	})
+	return strings.Join(strings.Fields(normalizeWord(query)), " ")
+}
 
-	return results
+// MatchTerms returns the index terms a query matches, for the given search type
+func MatchTerms(ctx context.Context, idx *indexer.Indexer, query string, searchType string) ([]string, error) {
+	q, err := MatchQuery(ctx, idx, query, searchType)
+	return q.Terms, err
 }
 
-func RegexSearch(idx *indexer.Indexer, pattern string) ([]models.SearchResult, error) {
+// KeywordTerms analyzes the keywords like the books were (lowercase, stem...)
+// and returns the resulting terms that are in the index
+func KeywordTerms(idx *indexer.Indexer, keywords string) []string {
+	terms := []string{}
+	for _, term := range idx.QueryTerms(keywords) {
+		if _, found := idx.WordToBooks[term]; found {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// FieldTerms analyzes the keywords like KeywordTerms and returns the
+// resulting terms that are in a metadata field of some book
+func FieldTerms(idx *indexer.Indexer, field, keywords string) []string {
+	terms := []string{}
+	for _, term := range idx.QueryTerms(keywords) {
+		fieldTerm := indexer.FieldTerm(field, term)
+		if _, found := idx.Fields[fieldTerm]; found {
+			terms = append(terms, fieldTerm)
+		}
+	}
+	return terms
+}
+
+// RegexTerms returns every word of the vocabulary matching a pattern, in the order
+// of the words, up to the MaxTerms of the limits of ctx
+func RegexTerms(ctx context.Context, idx *indexer.Indexer, pattern string) ([]string, error) {
 	// we need to have an engine that treats the regex ??
 	// wha* ==> ? how to guess it to whale
 	// run egrep on the index.json, capture the output then reutrn the the bookoccurences with that word
@@ -49,32 +105,56 @@ func RegexSearch(idx *indexer.Indexer, pattern string) ([]models.SearchResult, e
 		return nil, err
 	}
 
-	var matchingWords []string
-	for word := range idx.WordToBooks {
+	// The pattern is matched against the words as written in the books
+	// (whaling), not only against the terms they were reduced to (whale)
+	vocabulary := idx.Vocabulary()
+	matching := newTermSet(ctx)
+	for i, word := range vocabulary.Words {
+		if i%checkEvery == 0 {
+			if err := interrupted(ctx); err != nil {
+				return matching.terms, err
+			}
+		}
 		if re.MatchString(word) {
-			matchingWords = append(matchingWords, word)
+			if err := matching.add(vocabulary.Terms(word)); err != nil {
+				return matching.terms, err
+			}
 		}
 	}
+	return matching.terms, nil
+}
 
-	bookOccurrences := make(map[int]int)
-	for _, word := range matchingWords {
-		for bookID, count := range idx.WordToBooks[word] {
-			bookOccurrences[bookID] += count
+// termSet collects the terms matched by a pattern, up to the MaxTerms of its limits
+type termSet struct {
+	terms  []string
+	seen   map[string]bool
+	limits Limits
+}
+
+func newTermSet(ctx context.Context) *termSet {
+	return &termSet{terms: []string{}, seen: make(map[string]bool), limits: limitsOf(ctx)}
+}
+
+// add adds the terms not seen yet, or returns a LimitError once the set is full
+func (s *termSet) add(terms []string) error {
+	for _, term := range terms {
+		if s.seen[term] {
+			continue
+		}
+		if max := s.limits.MaxTerms; max > 0 && len(s.terms) >= max {
+			return &LimitError{Limit: LimitTerms, Limits: s.limits}
 		}
+		s.seen[term] = true
+		s.terms = append(s.terms, term)
 	}
+	return nil
+}
 
-	results := []models.SearchResult{}
-	for bookID, totalCount := range bookOccurrences {
-		book := idx.Books[bookID]
-		results = append(results, models.SearchResult{
-			Book:        book,
-			Occurrences: totalCount,
-			Relevance:   float64(totalCount),
-		})
+// TermForms maps each matched term to the words of the books it stands for, for display
+func TermForms(idx *indexer.Indexer, terms []string) map[string][]string {
+	forms := make(map[string][]string, len(terms))
+	for _, term := range terms {
+		forms[term] = idx.SurfaceForms(term)
 	}
-	// sorting the book was missing, we got an output of random mactched book which is not efficient!!
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Occurrences > results[j].Occurrences
-	})
-	return results, nil
+	return forms
 }
@@ -0,0 +1,106 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+)
+
+// testBook is a book of the indexes built by the tests; an empty title or
+// author is left out of the header
+type testBook struct {
+	title, author, text string
+}
+
+// newTestIndex indexes books given in ID order, starting at 1
+func newTestIndex(t *testing.T, books ...testBook) *indexer.Indexer {
+	t.Helper()
+	dir := t.TempDir()
+	idx := indexer.NewIndexer()
+	for i, book := range books {
+		header := "Language: English\n"
+		if book.title != "" {
+			header += "Title: " + book.title + "\n"
+		}
+		if book.author != "" {
+			header += "Author: " + book.author + "\n"
+		}
+		content := fmt.Sprintf("%s\n*** START OF THE PROJECT GUTENBERG EBOOK %d ***\n\n%s\n\n*** END OF THE PROJECT GUTENBERG EBOOK %d ***\n",
+			header, i+1, book.text, i+1)
+
+		path := filepath.Join(dir, fmt.Sprintf("book_%d.txt", i+1))
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.IndexBook(i+1, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return idx
+}
+
+func TestSearchStems(t *testing.T) {
+	idx := newTestIndex(t,
+		testBook{text: "The whale rose from the sea."},
+		testBook{text: "The ship sailed on."},
+		testBook{text: "Whaling was their trade, and whales their catch."},
+	)
+
+	for _, query := range []string{"whales", "whale", "whaling", "WHALES"} {
+		results, err := Search(context.Background(), idx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bookIDs(results); len(got) != 2 || !containsInt(got, 1) || !containsInt(got, 3) {
+			t.Errorf("Search(%q) = %v, want books 1 and 3", query, got)
+		}
+	}
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSearchAccents(t *testing.T) {
+	idx := newTestIndex(t,
+		testBook{text: "They met at the cafe\u0301 on the corner."},
+		testBook{text: "Kurt Gödel proved his theorems."},
+		testBook{text: "A cafe without an accent, and Godel without one either."},
+	)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		// The book has a combining accent: both spellings find it
+		{"cafe\u0301", []int{1}},
+		{"café", []int{1}},
+		// Without accents, both spellings are found
+		{"cafe", []int{1, 3}},
+		{"godel", []int{2, 3}},
+		{"gödel", []int{2}},
+		{"GÖDEL", []int{2}},
+	}
+	for _, tt := range tests {
+		results, err := Search(context.Background(), idx, tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := bookIDs(results)
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
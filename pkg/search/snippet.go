@@ -0,0 +1,413 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxSnippets is the number of passages shown for a book
+	MaxSnippets = 3
+
+	// snippetLength is the length of a passage, in bytes
+	snippetLength = 240
+
+	// maxSnippetHits stops the scan of a book after that many matches: the best
+	// passages of a book full of matches are found well before its end
+	maxSnippetHits = 1000
+)
+
+// Highlighter finds the words of a query in the text of books, to show why they matched.
+//
+// Analyzing a whole book again for every result would be too slow, so the text is only
+// split into lowercased words, looked up in the words the books were seen to have for
+// the query's terms (see Indexer.SurfaceForms).
+type Highlighter struct {
+	words map[string]bool
+	// plain holds the words that are not only in phrases, which match on their own
+	plain map[string]bool
+	// phrases holds, for each word of each phrase, the words of the text it accepts
+	phrases       [][]map[string]bool
+	minWordLength int
+}
+
+// hit is a word of the text that matched
+type hit struct {
+	start, end int
+	word       string
+	// position counts the words of the text, leaving out the ones that are not
+	// indexed (stop words, short words), so that words of a phrase follow each other
+	position int
+}
+
+// span is a part of a passage to mark: one word, or a whole phrase
+type span struct {
+	start, end int
+}
+
+// NewHighlighter prepares the highlighting of a query. The words of a regex search
+// must also match its pattern: "wha.*" matches the term whale, not its form "whaling".
+func NewHighlighter(idx *indexer.Indexer, q Query) *Highlighter {
+	h := &Highlighter{
+		words:         make(map[string]bool),
+		plain:         make(map[string]bool),
+		minWordLength: idx.MinWordLength,
+	}
+
+	phraseTerms := make(map[string]bool)
+	for _, phrase := range q.Phrases {
+		positions := make([]map[string]bool, len(phrase))
+		for i, terms := range phrase {
+			positions[i] = make(map[string]bool)
+			for _, term := range terms {
+				phraseTerms[term] = true
+				for _, form := range idx.SurfaceForms(term) {
+					positions[i][form] = true
+				}
+			}
+		}
+		h.phrases = append(h.phrases, positions)
+	}
+
+	for _, term := range q.Terms {
+		for _, form := range idx.SurfaceForms(term) {
+			if q.Pattern == nil || q.Pattern.MatchString(form) {
+				h.words[form] = true
+				if !phraseTerms[term] {
+					h.plain[form] = true
+				}
+			}
+		}
+	}
+	return h
+}
+
+// Snippets returns the best passages of a book for the query, in the order of the book.
+// content is the book file; language is the book's, for its stop words.
+func (h *Highlighter) Snippets(content, language string) []models.Snippet {
+	if len(h.words) == 0 {
+		return nil
+	}
+
+	text := indexer.SplitGutenberg(content)
+	body := text.Body
+
+	hits := h.findHits(body, indexer.StopWordsFor(language), maxSnippetHits)
+	if len(hits) == 0 {
+		return nil
+	}
+	hits, phrases := h.matchingHits(hits, h.findPhrases(hits))
+	if len(hits) == 0 {
+		return nil
+	}
+
+	type window struct {
+		first, last int // hits
+		score       int
+	}
+	var windows []window
+	for i := range hits {
+		j := i
+		for j+1 < len(hits) && hits[j+1].end-hits[i].start <= snippetLength {
+			j++
+		}
+
+		distinct := make(map[string]bool)
+		for k := i; k <= j; k++ {
+			distinct[hits[k].word] = true
+		}
+		score := 3*len(distinct) + (j - i + 1)
+		for _, p := range phrases {
+			if p[0] >= i && p[1] <= j {
+				score += 5
+			}
+		}
+		windows = append(windows, window{first: i, last: j, score: score})
+	}
+
+	sort.SliceStable(windows, func(a, b int) bool {
+		return windows[a].score > windows[b].score
+	})
+
+	// Best passages first, skipping those overlapping a better one
+	var chosen []span
+	for _, w := range windows {
+		if len(chosen) == MaxSnippets {
+			break
+		}
+		from, to := passage(body, hits[w.first].start, hits[w.last].end, snippetLength)
+		overlaps := false
+		for _, c := range chosen {
+			if from < c.end && c.start < to {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			chosen = append(chosen, span{from, to})
+		}
+	}
+	sort.Slice(chosen, func(a, b int) bool { return chosen[a].start < chosen[b].start })
+
+	snippets := make([]models.Snippet, 0, len(chosen))
+	for _, c := range chosen {
+		snippets = append(snippets, models.Snippet{
+			Fragment: fragment(body, c, marks(hits, phrases, c)),
+			Offset:   text.BodyStart + c.start,
+		})
+	}
+	return snippets
+}
+
+// findHits splits the text into words like WordTokenizer, lowercased, and returns
+// the ones that are words of the query, stopping after limit of them
+func (h *Highlighter) findHits(text string, stopWords map[string]bool, limit int) []hit {
+	var hits []hit
+	buf := make([]byte, 0, 64)
+	start, runes, position := -1, 0, 0
+	ascii := true
+
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := ""
+		found := false
+		if ascii {
+			// m[string(buf)] doesn't allocate
+			found = h.words[string(buf)]
+		} else {
+			word = norm.NFKC.String(string(buf))
+			found = h.words[word]
+		}
+
+		switch {
+		case found:
+			if word == "" {
+				word = string(buf)
+			}
+			hits = append(hits, hit{start: start, end: end, word: word, position: position})
+			position++
+		case runes < h.minWordLength || stopWords[string(buf)]:
+			// not indexed, doesn't separate the words of a phrase
+		default:
+			position++
+		}
+
+		buf = buf[:0]
+		start, runes = -1, 0
+		ascii = true
+	}
+
+	for i := 0; i < len(text) && len(hits) < limit; {
+		c := text[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+			case 'A' <= c && c <= 'Z':
+				c += 'a' - 'A'
+			default:
+				flush(i)
+				if c == '.' || c == '!' || c == '?' || c == ';' {
+					position++ // phrases don't run over the end of a sentence
+				}
+				i++
+				continue
+			}
+			if start < 0 {
+				start = i
+			}
+			buf = append(buf, c)
+			runes++
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case unicode.Is(unicode.Han, r):
+			// each ideograph is a word, as in WordTokenizer
+			flush(i)
+			start, runes, ascii = i, 1, false
+			buf = utf8.AppendRune(buf, r)
+			flush(i + size)
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if start < 0 {
+				start = i
+			}
+			buf = utf8.AppendRune(buf, unicode.ToLower(r))
+			runes++
+			ascii = false
+		case unicode.IsMark(r) && start >= 0:
+			buf = utf8.AppendRune(buf, r)
+			ascii = false
+		default:
+			flush(i)
+		}
+		i += size
+	}
+	if len(hits) < limit {
+		flush(len(text))
+	}
+	return hits
+}
+
+// findPhrases returns the first and last hit of every occurrence of a phrase
+func (h *Highlighter) findPhrases(hits []hit) [][2]int {
+	var found [][2]int
+	for _, phrase := range h.phrases {
+		for i := range hits {
+			if i+len(phrase) > len(hits) {
+				break
+			}
+			match := true
+			for k, words := range phrase {
+				next := hits[i+k]
+				if next.position != hits[i].position+k || !words[next.word] {
+					match = false
+					break
+				}
+			}
+			if match {
+				found = append(found, [2]int{i, i + len(phrase) - 1})
+			}
+		}
+	}
+	return found
+}
+
+// matchingHits keeps the hits that match on their own, the words of phrases
+// only matching inside their phrases, and the phrases with their new hit indexes
+func (h *Highlighter) matchingHits(hits []hit, phrases [][2]int) ([]hit, [][2]int) {
+	inPhrase := make([]bool, len(hits))
+	for _, p := range phrases {
+		for i := p[0]; i <= p[1]; i++ {
+			inPhrase[i] = true
+		}
+	}
+
+	index := make([]int, len(hits))
+	var kept []hit
+	for i, hit := range hits {
+		index[i] = len(kept)
+		if inPhrase[i] || h.plain[hit.word] {
+			kept = append(kept, hit)
+		}
+	}
+	for i, p := range phrases {
+		phrases[i] = [2]int{index[p[0]], index[p[1]]}
+	}
+	return kept, phrases
+}
+
+// passage extends the matches from start to end to a passage of about length bytes,
+// cut between words
+func passage(text string, start, end, length int) (from, to int) {
+	pad := (length - (end - start)) / 2
+	if pad < 0 {
+		pad = 0
+	}
+	from, to = start-pad, end+pad
+	if from < 0 {
+		from = 0
+	}
+	if to > len(text) {
+		to = len(text)
+	}
+
+	if from > 0 {
+		if i := strings.IndexAny(text[from:start], " \t\r\n"); i >= 0 {
+			from += i + 1
+		}
+		for from < start && !utf8.RuneStart(text[from]) {
+			from++
+		}
+	}
+	if to < len(text) {
+		if i := strings.LastIndexAny(text[end:to], " \t\r\n"); i >= 0 {
+			to = end + i
+		}
+		for to > end && !utf8.RuneStart(text[to]) {
+			to--
+		}
+	}
+	return from, to
+}
+
+// marks returns the parts of a passage to mark: the phrases it contains, and the other matches
+func marks(hits []hit, phrases [][2]int, passage span) []span {
+	phraseEnd := make(map[int]int)
+	for _, p := range phrases {
+		if hits[p[0]].start >= passage.start && hits[p[1]].end <= passage.end {
+			if last, found := phraseEnd[p[0]]; !found || p[1] > last {
+				phraseEnd[p[0]] = p[1]
+			}
+		}
+	}
+
+	var spans []span
+	for i := 0; i < len(hits); i++ {
+		if hits[i].start < passage.start || hits[i].end > passage.end {
+			continue
+		}
+		if last, found := phraseEnd[i]; found {
+			spans = append(spans, span{hits[i].start, hits[last].end})
+			i = last
+			continue
+		}
+		spans = append(spans, span{hits[i].start, hits[i].end})
+	}
+	return spans
+}
+
+// fragment renders a passage as HTML, with the spans in <mark> tags and line breaks
+// turned into spaces
+func fragment(text string, passage span, spans []span) string {
+	var b strings.Builder
+	pos := passage.start
+	for _, s := range spans {
+		b.WriteString(html.EscapeString(collapseSpaces(text[pos:s.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(collapseSpaces(text[s.start:s.end])))
+		b.WriteString("</mark>")
+		pos = s.end
+	}
+	b.WriteString(html.EscapeString(collapseSpaces(text[pos:passage.end])))
+
+	result := strings.TrimSpace(b.String())
+	if passage.start > 0 {
+		result = "…" + result
+	}
+	if passage.end < len(text) {
+		result += "…"
+	}
+	return result
+}
+
+// collapseSpaces replaces every run of spaces and line breaks by a single space
+func collapseSpaces(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
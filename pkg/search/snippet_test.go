@@ -0,0 +1,109 @@
+package search
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+)
+
+// bookContent reads the file of an indexed book
+func bookContent(t *testing.T, idx *indexer.Indexer, id int) string {
+	t.Helper()
+	content, err := os.ReadFile(idx.Books[id].FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// snippetsOf returns the snippets of a book for a query of a search type
+func snippetsOf(t *testing.T, idx *indexer.Indexer, id int, query, searchType string) []string {
+	t.Helper()
+	q, err := MatchQuery(context.Background(), idx, query, searchType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fragments []string
+	for _, s := range NewHighlighter(idx, q).Snippets(bookContent(t, idx, id), "en") {
+		fragments = append(fragments, s.Fragment)
+	}
+	return fragments
+}
+
+func TestSnippetsEscapeHTML(t *testing.T) {
+	idx := newTestIndex(t, testBook{
+		text: `The <script>alert("whale")</script> tag & the <b>whale</b> of <i>Moby</i>.`,
+	})
+
+	fragments := snippetsOf(t, idx, 1, "whale", "")
+	if len(fragments) != 1 {
+		t.Fatalf("snippets = %q, want one", fragments)
+	}
+	fragment := fragments[0]
+	want := `The &lt;script&gt;alert(&#34;<mark>whale</mark>&#34;)&lt;/script&gt; tag &amp; the &lt;b&gt;<mark>whale</mark>&lt;/b&gt; of &lt;i&gt;Moby&lt;/i&gt;.`
+	if fragment != want {
+		t.Errorf("fragment =\n%s\nwant\n%s", fragment, want)
+	}
+	// The only tags are the marks
+	if rest := strings.NewReplacer("<mark>", "", "</mark>", "").Replace(fragment); strings.ContainsAny(rest, "<>") {
+		t.Errorf("fragment has tags of the book: %s", fragment)
+	}
+}
+
+func TestSnippetsBestPassages(t *testing.T) {
+	filler := strings.Repeat("The sea was calm and the sky grey over the ship. ", 12)
+	idx := newTestIndex(t, testBook{
+		text: "A whale was seen. " + filler +
+			"Then the white whale rose, the white whale of the stories. " + filler +
+			"The sailors spoke of whales. " + filler +
+			"A whale again. " + filler,
+	})
+	content := bookContent(t, idx, 1)
+
+	q := KeywordQuery(idx, `"white whale"`)
+	snippets := NewHighlighter(idx, q).Snippets(content, "en")
+	// The phrase is marked whole, and the words of the phrase don't match alone
+	if len(snippets) != 1 || !strings.Contains(snippets[0].Fragment, "<mark>white whale</mark> rose, the <mark>white whale</mark>") {
+		t.Errorf("phrase snippets = %v, want the passage of the phrase", snippets)
+	}
+
+	// Keyword snippets: the passage with most matches, then others, in the order of the book
+	snippets = NewHighlighter(idx, KeywordQuery(idx, "whale")).Snippets(content, "en")
+	if len(snippets) != MaxSnippets {
+		t.Fatalf("%d snippets, want %d", len(snippets), MaxSnippets)
+	}
+	found := false
+	for i, s := range snippets {
+		if i > 0 && s.Offset <= snippets[i-1].Offset {
+			t.Errorf("snippets out of the order of the book: %d after %d", s.Offset, snippets[i-1].Offset)
+		}
+		// The offset is where the passage starts in the file
+		first := strings.Fields(strings.NewReplacer("<mark>", "", "</mark>", "", "…", "").Replace(s.Fragment))[0]
+		if !strings.HasPrefix(strings.TrimSpace(content[s.Offset:]), first) {
+			t.Errorf("snippet at %d starts with %q, the file with %q", s.Offset, first, content[s.Offset:s.Offset+20])
+		}
+		found = found || strings.Contains(s.Fragment, "<mark>whale</mark> rose, the white <mark>whale</mark>")
+	}
+	if !found {
+		t.Errorf("the passage with the most matches is missing: %v", snippets)
+	}
+}
+
+func TestSnippetsRegex(t *testing.T) {
+	idx := newTestIndex(t, testBook{text: "The whale and the whales, then whaling."})
+
+	// whales and whaling are forms of the term whale, but don't match the pattern
+	fragments := snippetsOf(t, idx, 1, "^whale$", "regex")
+	want := []string{"The <mark>whale</mark> and the whales, then whaling."}
+	if strings.Join(fragments, "|") != strings.Join(want, "|") {
+		t.Errorf("regex snippets = %q, want %q", fragments, want)
+	}
+	fragments = snippetsOf(t, idx, 1, "whal.*", "regex")
+	want = []string{"The <mark>whale</mark> and the <mark>whales</mark>, then <mark>whaling</mark>."}
+	if strings.Join(fragments, "|") != strings.Join(want, "|") {
+		t.Errorf("regex snippets = %q, want %q", fragments, want)
+	}
+}
@@ -0,0 +1,331 @@
+package search
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// FewHits is the number of books under which a word of a query is checked for typos
+	FewHits = 3
+
+	// MaxCorrections is the number of corrections suggested for a word
+	MaxCorrections = 5
+
+	// minSpellingFrequency is the number of books a word must be in to be suggested:
+	// a word found in a single book is as likely to be a typo of that book
+	minSpellingFrequency = 2
+
+	// spellPrefixLength is the number of letters of a word its deletes are made from.
+	// Longer words are told apart by the full edit distance of the candidates.
+	spellPrefixLength = 7
+)
+
+// Speller suggests corrections for the misspelled words of a query, with the
+// SymSpell algorithm: every word of the books is stored under each string obtained by
+// deleting up to MaxEditDistance of its letters ("whale" under "hale", "wale", "wle"...).
+// The words close to a typed word are then found among those stored under its own
+// deletes ("wahle" -> "wale"), without comparing it to the whole vocabulary.
+type Speller struct {
+	idx         *indexer.Indexer
+	words       []string
+	frequencies []int
+	// deletes maps each delete to the words it was made from, as positions in words
+	deletes map[string][]int32
+}
+
+// Correction is a word of the books a misspelled word may have been meant as
+type Correction struct {
+	Word      string `json:"word"`
+	Distance  int    `json:"distance"`
+	Frequency int    `json:"frequency"` // number of books containing the word
+}
+
+// Suggestion lists the corrections of a word of a query, best first
+type Suggestion struct {
+	Word        string       `json:"word"`
+	Frequency   int          `json:"frequency"`
+	Corrections []Correction `json:"corrections"`
+}
+
+// NewSpeller builds the deletes dictionary of the words of an index
+func NewSpeller(idx *indexer.Indexer) *Speller {
+	s := &Speller{idx: idx, deletes: make(map[string][]int32)}
+
+	for _, word := range idx.Vocabulary().Words {
+		frequency := bookFrequency(idx, idx.Vocabulary().Terms(word))
+		if frequency < minSpellingFrequency {
+			continue
+		}
+		position := int32(len(s.words))
+		s.words = append(s.words, word)
+		s.frequencies = append(s.frequencies, frequency)
+
+		for _, d := range deletes(spellPrefix(word), MaxEditDistance) {
+			s.deletes[d] = append(s.deletes[d], position)
+		}
+	}
+	return s
+}
+
+// Size returns the number of words and of deletes of the dictionary
+func (s *Speller) Size() (words, deletes int) {
+	return len(s.words), len(s.deletes)
+}
+
+// Corrections returns the words of the books at most distance edits away from word,
+// closest first, then found in the most books
+func (s *Speller) Corrections(word string, distance int) []Correction {
+	input := []rune(word)
+
+	seen := make(map[int32]bool)
+	var corrections []Correction
+	for _, d := range deletes(spellPrefix(word), distance) {
+		for _, position := range s.deletes[d] {
+			if seen[position] {
+				continue
+			}
+			seen[position] = true
+
+			candidate := []rune(s.words[position])
+			if abs(len(candidate)-len(input)) > distance {
+				continue
+			}
+			if dist := editDistance(input, candidate); dist > 0 && dist <= distance {
+				corrections = append(corrections, Correction{
+					Word:      s.words[position],
+					Distance:  dist,
+					Frequency: s.frequencies[position],
+				})
+			}
+		}
+	}
+
+	sortCorrections(corrections)
+	return corrections
+}
+
+// Suggest returns corrections for the words of a query that are in fewer than FewHits
+// books, keeping those found in more books than the word itself
+func (s *Speller) Suggest(query string) []Suggestion {
+	var suggestions []Suggestion
+	seen := make(map[string]bool)
+	for _, word := range spellingWords(query) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+
+		terms := s.idx.QueryTerms(word)
+		distance := AutoDistance(word)
+		if len(terms) == 0 || distance == 0 {
+			continue // stop word, or too short to guess
+		}
+		frequency := bookFrequency(s.idx, terms)
+		if frequency >= FewHits {
+			continue
+		}
+
+		var kept []Correction
+		for _, c := range s.Corrections(word, distance) {
+			if c.Frequency > frequency {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) > MaxCorrections {
+			kept = kept[:MaxCorrections]
+		}
+		if len(kept) > 0 {
+			suggestions = append(suggestions, Suggestion{Word: word, Frequency: frequency, Corrections: kept})
+		}
+	}
+	return suggestions
+}
+
+// MergeSuggestions adds up the suggestions made by several parts of the collection
+// (shards): the frequencies of a word and of its corrections are summed
+func MergeSuggestions(lists ...[]Suggestion) []Suggestion {
+	var merged []Suggestion
+	byWord := make(map[string]int)
+	for _, list := range lists {
+		for _, suggestion := range list {
+			i, found := byWord[suggestion.Word]
+			if !found {
+				i = len(merged)
+				byWord[suggestion.Word] = i
+				merged = append(merged, Suggestion{Word: suggestion.Word})
+			}
+			merged[i].Frequency += suggestion.Frequency
+			merged[i].Corrections = mergeCorrections(merged[i].Corrections, suggestion.Corrections)
+		}
+	}
+	for i := range merged {
+		sortCorrections(merged[i].Corrections)
+		if len(merged[i].Corrections) > MaxCorrections {
+			merged[i].Corrections = merged[i].Corrections[:MaxCorrections]
+		}
+	}
+	return merged
+}
+
+func mergeCorrections(list, other []Correction) []Correction {
+	for _, c := range other {
+		found := false
+		for i := range list {
+			if list[i].Word == c.Word {
+				list[i].Frequency += c.Frequency
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// spellingWord matches the words of a query checked for typos: field values
+// (author:melvile) and fuzzy words (wahle~) are left as typed
+var spellingWord = regexp.MustCompile(`[^\s"]+`)
+
+// CorrectQuery returns the query with each misspelled word replaced by its best
+// correction ("moby wahle" -> "moby whale"), or "" if there is nothing to correct
+func CorrectQuery(query string, suggestions []Suggestion) string {
+	best := make(map[string]string)
+	for _, s := range suggestions {
+		if len(s.Corrections) > 0 {
+			best[s.Word] = s.Corrections[0].Word
+		}
+	}
+	if len(best) == 0 {
+		return ""
+	}
+
+	return spellingWord.ReplaceAllStringFunc(query, func(token string) string {
+		if strings.ContainsAny(token, ":~") {
+			return token
+		}
+		word := strings.TrimFunc(token, isNotWordRune)
+		if correction, found := best[normalizeWord(word)]; found && word != "" {
+			return strings.Replace(token, word, correction, 1)
+		}
+		return token
+	})
+}
+
+// spellingWords returns the words of a query checked for typos, normalized
+func spellingWords(query string) []string {
+	var words []string
+	for _, token := range spellingWord.FindAllString(query, -1) {
+		if strings.ContainsAny(token, ":~") {
+			continue
+		}
+		if word := strings.TrimFunc(token, isNotWordRune); word != "" {
+			words = append(words, normalizeWord(word))
+		}
+	}
+	return words
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r)
+}
+
+// normalizeWord puts a typed word in the form of the words of the vocabulary
+func normalizeWord(word string) string {
+	return strings.ToLower(norm.NFKC.String(word))
+}
+
+// bookFrequency returns the number of books containing a word, given the terms it stands for
+func bookFrequency(idx *indexer.Indexer, terms []string) int {
+	frequency := 0
+	for _, term := range terms {
+		frequency = max(frequency, len(idx.WordToBooks[term]))
+	}
+	return frequency
+}
+
+func sortCorrections(corrections []Correction) {
+	sort.Slice(corrections, func(a, b int) bool {
+		if corrections[a].Distance != corrections[b].Distance {
+			return corrections[a].Distance < corrections[b].Distance
+		}
+		if corrections[a].Frequency != corrections[b].Frequency {
+			return corrections[a].Frequency > corrections[b].Frequency
+		}
+		return corrections[a].Word < corrections[b].Word
+	})
+}
+
+// spellPrefix returns the first spellPrefixLength letters of a word
+func spellPrefix(word string) string {
+	runes := []rune(word)
+	if len(runes) > spellPrefixLength {
+		runes = runes[:spellPrefixLength]
+	}
+	return string(runes)
+}
+
+// deletes returns the word and every string obtained by deleting up to distance of its letters
+func deletes(word string, distance int) []string {
+	seen := map[string]bool{word: true}
+	found := []string{word}
+	level := []string{word}
+	for d := 0; d < distance; d++ {
+		var next []string
+		for _, w := range level {
+			runes := []rune(w)
+			for i := range runes {
+				deleted := string(runes[:i]) + string(runes[i+1:])
+				if !seen[deleted] {
+					seen[deleted] = true
+					found = append(found, deleted)
+					next = append(next, deleted)
+				}
+			}
+		}
+		level = next
+	}
+	return found
+}
+
+// editDistance returns the number of insertions, deletions, substitutions and
+// transpositions of neighbour letters turning a into b
+func editDistance(a, b []rune) int {
+	// three rows of the table: before, previous and current
+	before := make([]int, len(b)+1)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				current[j] = min(current[j], before[j-2]+1)
+			}
+		}
+		before, previous, current = previous, current, before
+	}
+	return previous[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,97 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+// spellIndex has words in 4 (barn), 3 (born, extraordinary), 2 (burn) and 1 book (bern)
+func spellIndex(t *testing.T) *Speller {
+	t.Helper()
+	return NewSpeller(newTestIndex(t,
+		testBook{text: "barn born burn extraordinary bern"},
+		testBook{text: "barn born burn extraordinary"},
+		testBook{text: "barn born extraordinary"},
+		testBook{text: "barn"},
+	))
+}
+
+func correctionWords(corrections []Correction) []string {
+	var words []string
+	for _, c := range corrections {
+		words = append(words, c.Word)
+	}
+	return words
+}
+
+func TestSpellerTiesByFrequency(t *testing.T) {
+	s := spellIndex(t)
+
+	// Every word is one edit away: the ones in more books come first,
+	// and bern, in a single book, is not suggested at all
+	got := s.Corrections("bxrn", 1)
+	want := []Correction{
+		{Word: "barn", Distance: 1, Frequency: 4},
+		{Word: "born", Distance: 1, Frequency: 3},
+		{Word: "burn", Distance: 1, Frequency: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Corrections(bxrn) = %v, want %v", got, want)
+	}
+
+	// Closer words come before more frequent ones
+	if got := correctionWords(s.Corrections("brn", 2)); !reflect.DeepEqual(got[:3], []string{"barn", "born", "burn"}) {
+		t.Errorf("Corrections(brn) = %v", got)
+	}
+}
+
+func TestSpellerLongWords(t *testing.T) {
+	s := spellIndex(t)
+
+	for _, typo := range []string{
+		"extraordinery", // after the first 7 letters
+		"extrordinary",  // in the first 7 letters
+		"etxraordinray", // in both
+	} {
+		suggestions := s.Suggest(typo)
+		if len(suggestions) != 1 || len(suggestions[0].Corrections) == 0 ||
+			suggestions[0].Corrections[0].Word != "extraordinary" {
+			t.Errorf("Suggest(%q) = %v, want extraordinary", typo, suggestions)
+		}
+	}
+
+	// Same first 7 letters, but too many edits after them
+	if got := s.Corrections("extraorbbbbbb", 2); len(got) != 0 {
+		t.Errorf("Corrections(extraorbbbbbb) = %v, want none", got)
+	}
+}
+
+func TestSpellerKnownWords(t *testing.T) {
+	s := spellIndex(t)
+
+	for _, query := range []string{"barn", "born", "extraordinary", "barn born", "BARN"} {
+		suggestions := s.Suggest(query)
+		if len(suggestions) != 0 {
+			t.Errorf("Suggest(%q) = %v, want none", query, suggestions)
+		}
+		if didYouMean := CorrectQuery(query, suggestions); didYouMean != "" {
+			t.Errorf("did you mean %q for %q, want nothing", didYouMean, query)
+		}
+	}
+
+	// A known word in few books may still be a typo of a more frequent one
+	if got := s.Suggest("burn"); len(got) != 1 || got[0].Frequency != 2 ||
+		!reflect.DeepEqual(correctionWords(got[0].Corrections), []string{"barn", "born"}) {
+		t.Errorf("Suggest(burn) = %v, want barn and born", got)
+	}
+}
+
+func TestCorrectQuery(t *testing.T) {
+	s := spellIndex(t)
+
+	query := `Bxrn "extrordinary" author:bxrn bxrn~`
+	got := CorrectQuery(query, s.Suggest(query))
+	if want := `barn "extraordinary" author:bxrn bxrn~`; got != want {
+		t.Errorf("CorrectQuery(%q) = %q, want %q", query, got, want)
+	}
+}
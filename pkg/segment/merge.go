@@ -0,0 +1,142 @@
+package segment
+
+import (
+	"time"
+)
+
+// pickSmallSegments returns the segments the policy wants merged, or nil.
+// The oldest small segments are picked first so that merged segments keep
+// roughly the ingestion order.
+func (si *SegmentedIndex) pickSmallSegments() []*Segment {
+	var small []*Segment
+	for _, seg := range si.segments {
+		if seg.LiveBooks() < si.Policy.SmallSegmentBooks {
+			small = append(small, seg)
+		}
+	}
+
+	factor := si.Policy.MergeFactor
+	if factor < 2 {
+		factor = 2
+	}
+	if len(small) < factor {
+		return nil
+	}
+	return small[:factor]
+}
+
+// MaybeMerge applies the merge policy once.
+// It returns true if segments were merged.
+func (si *SegmentedIndex) MaybeMerge() bool {
+	si.mu.RLock()
+	picked := si.pickSmallSegments()
+	si.mu.RUnlock()
+
+	if picked == nil {
+		return false
+	}
+	return si.Merge(picked)
+}
+
+// Merge combines segments into one, dropping their deleted books.
+// The new segment takes the place of the oldest merged one.
+// Searches keep running on the old segments while the merge is being built.
+func (si *SegmentedIndex) Merge(segments []*Segment) bool {
+	si.mergeMu.Lock()
+	defer si.mergeMu.Unlock()
+
+	// Copy the tombstones so the merge doesn't race with DeleteBook
+	si.mu.RLock()
+	copies := make([]*Segment, len(segments))
+	for i, seg := range segments {
+		tombstones := make(map[int]bool, len(seg.Tombstones))
+		for bookID := range seg.Tombstones {
+			tombstones[bookID] = true
+		}
+		copies[i] = &Segment{ID: seg.ID, Index: seg.Index, Tombstones: tombstones}
+	}
+	si.mu.RUnlock()
+
+	mergedIndex := mergeSegments(copies)
+
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	merged := &Segment{ID: si.nextID, Index: mergedIndex, Tombstones: make(map[int]bool)}
+
+	// Books deleted while we were merging are still live in the merged index
+	for i, seg := range segments {
+		for bookID := range seg.Tombstones {
+			if !copies[i].Tombstones[bookID] {
+				merged.Tombstones[bookID] = true
+			}
+		}
+	}
+
+	isMerged := make(map[*Segment]bool)
+	for _, seg := range segments {
+		isMerged[seg] = true
+	}
+
+	// The merged segment takes the place of the oldest source.
+	// It is dropped entirely if all of its books were deleted.
+	var kept []*Segment
+	inserted := false
+	for _, seg := range si.segments {
+		if !isMerged[seg] {
+			kept = append(kept, seg)
+			continue
+		}
+		if !inserted && len(merged.Index.Books) > 0 {
+			kept = append(kept, merged)
+		}
+		inserted = true
+	}
+
+	si.nextID++
+	si.segments = kept
+	return true
+}
+
+// StartMerging runs the merge policy in the background until Stop is called
+func (si *SegmentedIndex) StartMerging() {
+	si.mu.Lock()
+	if si.stop != nil {
+		si.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	si.stop = stop
+	si.mu.Unlock()
+
+	interval := si.Policy.Interval
+	if interval <= 0 {
+		interval = DefaultMergePolicy().Interval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				for si.MaybeMerge() {
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the background merger started by StartMerging
+func (si *SegmentedIndex) Stop() {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	if si.stop != nil {
+		close(si.stop)
+		si.stop = nil
+	}
+}
@@ -0,0 +1,234 @@
+package segment
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+	"github.com/taqiyeddinedj/daar-project3/pkg/search"
+)
+
+// Segment is an immutable mini inverted index.
+// Books deleted after the segment was written are only marked in Tombstones;
+// they are physically dropped when the segment gets merged.
+type Segment struct {
+	ID         int              `json:"id"`
+	Index      *indexer.Indexer `json:"-"`
+	Tombstones map[int]bool     `json:"tombstones"`
+}
+
+// LiveBooks returns the number of books of the segment that are not deleted
+func (s *Segment) LiveBooks() int {
+	return len(s.Index.Books) - len(s.Tombstones)
+}
+
+func (s *Segment) isLive(bookID int) bool {
+	_, exists := s.Index.Books[bookID]
+	return exists && !s.Tombstones[bookID]
+}
+
+// MergePolicy decides when small segments are combined
+type MergePolicy struct {
+	// Segments with fewer live books than this are considered small
+	SmallSegmentBooks int
+	// A merge happens once this many small segments exist
+	MergeFactor int
+	// How often the background merger checks the policy
+	Interval time.Duration
+}
+
+// DefaultMergePolicy merges 4 segments of less than 200 books, checking every 30s
+func DefaultMergePolicy() MergePolicy {
+	return MergePolicy{
+		SmallSegmentBooks: 200,
+		MergeFactor:       4,
+		Interval:          30 * time.Second,
+	}
+}
+
+// SegmentedIndex is a list of segments searched together.
+// New books always go into a fresh segment, so existing segments never change
+// except for their tombstones.
+type SegmentedIndex struct {
+	Policy MergePolicy
+
+	mu       sync.RWMutex
+	segments []*Segment
+	nextID   int
+
+	// mergeMu makes sure only one merge runs at a time
+	mergeMu sync.Mutex
+	stop    chan struct{}
+}
+
+// New creates an empty segmented index
+func New(policy MergePolicy) *SegmentedIndex {
+	return &SegmentedIndex{Policy: policy, nextID: 1}
+}
+
+// Segments returns the current segments, oldest first
+func (si *SegmentedIndex) Segments() []*Segment {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+
+	return append([]*Segment(nil), si.segments...)
+}
+
+// AddBooks indexes book files into a new segment.
+// Books that already exist in older segments are tombstoned there, so this is also how books are updated.
+// It returns nil if no file could be indexed.
+func (si *SegmentedIndex) AddBooks(files []string, opts indexer.BuildOptions) *Segment {
+	idx := indexer.NewIndexer()
+	idx.IndexFiles(files, opts)
+	if len(idx.Books) == 0 {
+		return nil
+	}
+
+	return si.AddSegment(idx)
+}
+
+// AddSegment appends an already built index as the newest segment
+func (si *SegmentedIndex) AddSegment(idx *indexer.Indexer) *Segment {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	for bookID := range idx.Books {
+		si.deleteLocked(bookID)
+	}
+
+	seg := &Segment{ID: si.nextID, Index: idx, Tombstones: make(map[int]bool)}
+	si.nextID++
+	si.segments = append(si.segments, seg)
+	return seg
+}
+
+// DeleteBook marks a book as deleted. It returns false if no segment had it.
+func (si *SegmentedIndex) DeleteBook(bookID int) bool {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	return si.deleteLocked(bookID)
+}
+
+func (si *SegmentedIndex) deleteLocked(bookID int) bool {
+	deleted := false
+	for _, seg := range si.segments {
+		if seg.isLive(bookID) {
+			seg.Tombstones[bookID] = true
+			deleted = true
+		}
+	}
+	return deleted
+}
+
+// Book returns a live book from whichever segment holds it
+func (si *SegmentedIndex) Book(bookID int) (models.Book, bool) {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+
+	for _, seg := range si.segments {
+		if seg.isLive(bookID) {
+			return seg.Index.Books[bookID], true
+		}
+	}
+	return models.Book{}, false
+}
+
+// Search looks up a keyword in every segment and merges the results
+func (si *SegmentedIndex) Search(ctx context.Context, keyword string) ([]models.SearchResult, error) {
+	return si.searchAll(ctx, func(idx *indexer.Indexer) ([]string, error) {
+		return search.KeywordTerms(idx, keyword), nil
+	})
+}
+
+// RegexSearch matches a pattern in every segment and merges the results
+func (si *SegmentedIndex) RegexSearch(ctx context.Context, pattern string) ([]models.SearchResult, error) {
+	return si.searchAll(ctx, func(idx *indexer.Indexer) ([]string, error) {
+		return search.RegexTerms(ctx, idx, pattern)
+	})
+}
+
+// searchAll matches terms in each segment, then scores every segment with all
+// the matched terms and the stats of the live books of the whole index, so that
+// results are those a merged index would give, and drops deleted books.
+// A live book is in exactly one segment, so merging is a concatenation.
+// Past the limits of a search, the results found are returned with the LimitError.
+func (si *SegmentedIndex) searchAll(ctx context.Context, match func(idx *indexer.Indexer) ([]string, error)) ([]models.SearchResult, error) {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+
+	// A word may be written one way in a segment and another way elsewhere
+	// (ships, ship), so the terms matched anywhere are looked up everywhere
+	var limit error
+	terms := []string{}
+	seen := make(map[string]bool)
+	for _, seg := range si.segments {
+		segTerms, err := match(seg.Index)
+		if search.IsLimit(err) {
+			limit = err
+		} else if err != nil {
+			return nil, err
+		}
+		for _, term := range segTerms {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+
+	var stats search.CorpusStats
+	for _, seg := range si.segments {
+		segStats := search.LocalStats(seg.Index, terms)
+		for bookID := range seg.Tombstones {
+			segStats.TotalBooks--
+			segStats.TotalWords -= seg.Index.Books[bookID].WordCount
+			for _, term := range terms {
+				if _, found := seg.Index.Postings(term)[bookID]; found {
+					segStats.DocFreq[term]--
+				}
+			}
+		}
+		stats.Add(segStats)
+	}
+
+	results := []models.SearchResult{}
+	for _, seg := range si.segments {
+		segResults, err := search.ScoreTerms(ctx, seg.Index, terms, stats)
+		if search.IsLimit(err) {
+			limit = err
+		} else if err != nil {
+			return nil, err
+		}
+		for _, r := range segResults {
+			if !seg.Tombstones[r.Book.ID] {
+				results = append(results, r)
+			}
+		}
+	}
+
+	search.SortResults(results)
+	return results, limit
+}
+
+// Snapshot merges all live books into a single index,
+// for code that works on a plain indexer.Indexer (graph, PageRank, storage)
+func (si *SegmentedIndex) Snapshot() *indexer.Indexer {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+
+	return mergeSegments(si.segments)
+}
+
+// mergeSegments builds a new index from the live books of some segments
+func mergeSegments(segments []*Segment) *indexer.Indexer {
+	merged := indexer.NewIndexer()
+	for _, seg := range segments {
+		merged.MergeFrom(seg.Index, func(bookID int) bool {
+			return seg.Tombstones[bookID]
+		})
+	}
+	return merged
+}
@@ -0,0 +1,278 @@
+package segment
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+	"github.com/taqiyeddinedj/daar-project3/pkg/search"
+)
+
+// writeBook writes a small Gutenberg-like book file and returns its path
+func writeBook(t *testing.T, dir string, id int, body string) string {
+	t.Helper()
+	content := fmt.Sprintf(`Title: Book %[1]d
+
+Author: Test Author
+
+Language: English
+
+*** START OF THE PROJECT GUTENBERG EBOOK BOOK %[1]d ***
+
+%[2]s
+
+*** END OF THE PROJECT GUTENBERG EBOOK BOOK %[1]d ***
+`, id, body)
+
+	path := filepath.Join(dir, fmt.Sprintf("book_%d.txt", id))
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// addSegment indexes the given books (ID to text) as one new segment
+func addSegment(t *testing.T, si *SegmentedIndex, dir string, books map[int]string) *Segment {
+	t.Helper()
+	var files []string
+	for id, body := range books {
+		files = append(files, writeBook(t, dir, id, body))
+	}
+	seg := si.AddBooks(files, indexer.BuildOptions{Workers: 1})
+	if seg == nil {
+		t.Fatal("no book indexed")
+	}
+	return seg
+}
+
+func segmentIDs(si *SegmentedIndex) []int {
+	var ids []int
+	for _, seg := range si.Segments() {
+		ids = append(ids, seg.ID)
+	}
+	return ids
+}
+
+func TestMergeDropsTombstones(t *testing.T) {
+	dir := t.TempDir()
+	si := New(DefaultMergePolicy())
+	addSegment(t, si, dir, map[int]string{1: "whales in the ocean", 2: "sailors on the ocean"})
+	addSegment(t, si, dir, map[int]string{3: "zanzibar merchants and spices"})
+	// Updating book 2 tombstones it in the first segment
+	addSegment(t, si, dir, map[int]string{2: "sailors in the harbour"})
+
+	if !si.DeleteBook(3) {
+		t.Fatal("DeleteBook(3) = false, want true")
+	}
+	if si.DeleteBook(3) {
+		t.Error("deleting a book twice should return false")
+	}
+	if _, found := si.Book(3); found {
+		t.Error("deleted book still returned by Book")
+	}
+
+	if !si.Merge(si.Segments()) {
+		t.Fatal("Merge returned false")
+	}
+
+	segments := si.Segments()
+	if len(segments) != 1 {
+		t.Fatalf("got %d segments after merging all of them, want 1", len(segments))
+	}
+	merged := segments[0]
+	if len(merged.Tombstones) != 0 {
+		t.Errorf("merged segment has tombstones %v", merged.Tombstones)
+	}
+	if len(merged.Index.Books) != 2 {
+		t.Errorf("merged segment has %d books, want 2", len(merged.Index.Books))
+	}
+	if _, found := merged.Index.WordToBooks["zanzibar"]; found {
+		t.Error("words of the deleted book are still indexed")
+	}
+	if postings := merged.Index.WordToBooks["ocean"]; len(postings) != 1 || postings[1] == 0 {
+		t.Errorf("postings of \"ocean\" = %v, want only book 1", postings)
+	}
+	if postings := merged.Index.WordToBooks["harbour"]; postings[2] == 0 {
+		t.Error("the update of book 2 was lost")
+	}
+}
+
+func TestMergePolicyPicksSmallSegments(t *testing.T) {
+	dir := t.TempDir()
+	si := New(MergePolicy{SmallSegmentBooks: 2, MergeFactor: 2})
+	big := addSegment(t, si, dir, map[int]string{1: "whales", 2: "sailors", 3: "islands"})
+	first := addSegment(t, si, dir, map[int]string{4: "harbour"})
+	second := addSegment(t, si, dir, map[int]string{5: "lighthouse"})
+	third := addSegment(t, si, dir, map[int]string{6: "treasure"})
+
+	// Deleting books makes a big segment small
+	si.DeleteBook(1)
+	si.DeleteBook(2)
+
+	picked := si.pickSmallSegments()
+	if len(picked) != 2 || picked[0] != big || picked[1] != first {
+		t.Fatalf("picked %v, want the 2 oldest small segments %d and %d", picked, big.ID, first.ID)
+	}
+
+	if !si.MaybeMerge() {
+		t.Fatal("MaybeMerge = false with 4 small segments")
+	}
+	ids := segmentIDs(si)
+	if len(ids) != 3 || ids[1] != second.ID || ids[2] != third.ID {
+		t.Fatalf("segments after merge = %v, want the merged one then %d, %d", ids, second.ID, third.ID)
+	}
+	if merged := si.Segments()[0]; merged.LiveBooks() != 2 {
+		t.Errorf("merged segment has %d live books, want 2", merged.LiveBooks())
+	}
+
+	// The merged segment has 2 books, so it is not small anymore
+	if !si.MaybeMerge() {
+		t.Fatal("MaybeMerge = false with 2 small segments left")
+	}
+	if si.MaybeMerge() {
+		t.Errorf("MaybeMerge merged again: segments %v", segmentIDs(si))
+	}
+}
+
+func TestStartMerging(t *testing.T) {
+	dir := t.TempDir()
+	si := New(MergePolicy{SmallSegmentBooks: 10, MergeFactor: 2, Interval: time.Millisecond})
+	for id := 1; id <= 4; id++ {
+		addSegment(t, si, dir, map[int]string{id: "whales and sailors"})
+	}
+
+	si.StartMerging()
+	si.StartMerging()
+	defer si.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(si.Segments()) > 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("segments not merged in the background: %v", segmentIDs(si))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	si.Stop()
+	addSegment(t, si, dir, map[int]string{5: "whales"})
+	time.Sleep(20 * time.Millisecond)
+	if n := len(si.Segments()); n != 2 {
+		t.Errorf("got %d segments after Stop, want 2 (no more merges)", n)
+	}
+}
+
+func TestSearchMatchesMergedIndex(t *testing.T) {
+	dir := t.TempDir()
+	si := New(DefaultMergePolicy())
+	addSegment(t, si, dir, map[int]string{
+		1: "The whale swam in the ocean. The whale was white.",
+		2: "Sailors feared the ocean and the whales of the north.",
+		3: "A merchant ship carried spices across the ocean.",
+	})
+	addSegment(t, si, dir, map[int]string{
+		4: "The lighthouse keeper watched whales and ships.",
+		5: "Whales, whales everywhere, and not a ship in sight.",
+	})
+	addSegment(t, si, dir, map[int]string{
+		// Updates book 2, whose old text is now a tombstone
+		2: "Sailors rested in the harbour.",
+		6: "An ocean of sand, with no whale in it.",
+	})
+	si.DeleteBook(5)
+
+	merged := si.Snapshot()
+	if len(merged.Books) != 5 {
+		t.Fatalf("snapshot has %d books, want 5", len(merged.Books))
+	}
+
+	ctx := context.Background()
+	for _, query := range []string{"whale", "ocean", "sailors", "whale ship", "lighthouse"} {
+		got, err := si.Search(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := search.Search(ctx, merged, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareResults(t, query, got, want)
+	}
+
+	for _, pattern := range []string{"wh.*", "s.*s"} {
+		got, err := si.RegexSearch(ctx, pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := search.RegexSearch(ctx, merged, pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareResults(t, pattern, got, want)
+	}
+}
+
+func compareResults(t *testing.T, query string, got, want []models.SearchResult) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%q: got %d results, want %d", query, len(got), len(want))
+		return
+	}
+	for i := range got {
+		if got[i].Book.ID != want[i].Book.ID || got[i].Occurrences != want[i].Occurrences ||
+			math.Abs(got[i].Relevance-want[i].Relevance) > 1e-9 {
+			t.Errorf("%q result %d: got book %d (%d, %v), want book %d (%d, %v)", query, i,
+				got[i].Book.ID, got[i].Occurrences, got[i].Relevance,
+				want[i].Book.ID, want[i].Occurrences, want[i].Relevance)
+		}
+	}
+}
+
+func TestSaveToDirAfterCrash(t *testing.T) {
+	booksDir, dir := t.TempDir(), t.TempDir()
+	si := New(DefaultMergePolicy())
+	addSegment(t, si, booksDir, map[int]string{1: "whales in the ocean", 2: "sailors on the ocean"})
+	if err := si.SaveToDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// A save that stopped after half a segment file, before segments.json listed it
+	orphan := segmentPath(dir, si.nextID)
+	if err := os.WriteFile(orphan, []byte(`{"books": {"1": {"id"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next segment gets the ID of the orphan file, which is written again
+	loaded, err := LoadFromDir(dir, DefaultMergePolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg := addSegment(t, loaded, booksDir, map[int]string{3: "zanzibar merchants and spices"})
+	if segmentPath(dir, seg.ID) != orphan {
+		t.Fatalf("new segment %d doesn't reuse the ID of %s", seg.ID, orphan)
+	}
+	if err := loaded.SaveToDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadFromDir(dir, DefaultMergePolicy())
+	if err != nil {
+		t.Fatalf("loading after the crash: %v", err)
+	}
+	for _, id := range []int{1, 2, 3} {
+		if _, found := reloaded.Book(id); !found {
+			t.Errorf("book %d missing after the crash", id)
+		}
+	}
+
+	// Nothing is left of the temporary files
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 3 {
+		t.Errorf("files after saving = %v, want 2 segments and segments.json", files)
+	}
+}
@@ -0,0 +1,118 @@
+package segment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/storage"
+)
+
+// segmentsFile lists the segments of a directory and their tombstones
+const segmentsFile = "segments.json"
+
+type segmentList struct {
+	NextID   int        `json:"next_id"`
+	Segments []*Segment `json:"segments"`
+}
+
+func segmentPath(dir string, id int) string {
+	return filepath.Join(dir, fmt.Sprintf("segment_%06d.json", id))
+}
+
+// SaveToDir writes every segment to its own file plus a segments.json listing them.
+// Files are written whole then renamed, so a crash leaves the previous ones.
+// Segments are immutable: the files of the segments segments.json already lists
+// are not written again. Other files may be left by a save that didn't finish and
+// have the ID of a new segment, so they are.
+func (si *SegmentedIndex) SaveToDir(dir string) error {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	saved := make(map[int]bool)
+	if list, err := readSegmentList(dir); err == nil {
+		for _, seg := range list.Segments {
+			saved[seg.ID] = true
+		}
+	}
+
+	keep := make(map[string]bool)
+	for _, seg := range si.segments {
+		path := segmentPath(dir, seg.ID)
+		keep[path] = true
+		if _, err := os.Stat(path); err == nil && saved[seg.ID] {
+			continue
+		}
+		if err := storage.SaveToFile(seg.Index, path); err != nil {
+			return fmt.Errorf("failed to save segment %d: %w", seg.ID, err)
+		}
+	}
+
+	err := storage.WriteFile(filepath.Join(dir, segmentsFile), func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(segmentList{NextID: si.nextID, Segments: si.segments})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Segments that were merged away are no longer listed: delete their files
+	old, _ := filepath.Glob(filepath.Join(dir, "segment_*.json"))
+	for _, path := range old {
+		if !keep[path] {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+// LoadFromDir loads the segments written by SaveToDir.
+// A missing directory gives an empty index.
+func LoadFromDir(dir string, policy MergePolicy) (*SegmentedIndex, error) {
+	si := New(policy)
+
+	list, err := readSegmentList(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return si, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seg := range list.Segments {
+		seg.Index, err = storage.LoadFromFile(segmentPath(dir, seg.ID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load segment %d: %w", seg.ID, err)
+		}
+		if seg.Tombstones == nil {
+			seg.Tombstones = make(map[int]bool)
+		}
+	}
+
+	si.segments = list.Segments
+	si.nextID = list.NextID
+	return si, nil
+}
+
+// readSegmentList reads the segments.json of a directory
+func readSegmentList(dir string) (segmentList, error) {
+	var list segmentList
+	file, err := os.Open(filepath.Join(dir, segmentsFile))
+	if err != nil {
+		return list, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&list); err != nil {
+		return list, fmt.Errorf("failed to decode %s: %w", segmentsFile, err)
+	}
+	return list, nil
+}
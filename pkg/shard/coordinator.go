@@ -0,0 +1,174 @@
+package shard
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+	"github.com/taqiyeddinedj/daar-project3/pkg/search"
+)
+
+// Coordinator fans queries out to every shard and merges their answers
+type Coordinator struct {
+	Shards []*Client
+}
+
+// NewCoordinator creates a coordinator for shards listed in shard order
+// (the shard at position i holds the books with Assign(id, n) == i)
+func NewCoordinator(urls []string) *Coordinator {
+	co := &Coordinator{}
+	for _, u := range urls {
+		co.Shards = append(co.Shards, NewClient(u))
+	}
+	return co
+}
+
+// ShardFor returns the shard holding a book
+func (co *Coordinator) ShardFor(bookID int) *Client {
+	return co.Shards[Assign(bookID, len(co.Shards))]
+}
+
+// Search returns the best req.Limit results over all shards that pass req.Filters,
+// the total number of matches and the words each matched term stands for.
+//
+// It runs in two rounds: first the document frequencies of the matching terms are
+// collected from every shard and summed, then every shard scores its books with
+// these global stats (req.Stats), so scores are comparable and the merged order is
+// the same as if all books were in one index. Each shard only sends its own top
+// req.Limit, which is enough to fill the first req.Limit merged results.
+// Ending ctx cancels the requests to the shards, which stop searching.
+func (co *Coordinator) Search(ctx context.Context, req SearchRequest) (SearchResponse, error) {
+	shardStats, err := fanOut(co.Shards, func(c *Client) (search.CorpusStats, error) {
+		return c.Stats(ctx, req.Query, req.Type)
+	})
+	if err != nil {
+		return SearchResponse{}, err
+	}
+
+	req.Stats = search.CorpusStats{}
+	for _, stats := range shardStats {
+		req.Stats.Add(stats)
+	}
+
+	responses, err := fanOut(co.Shards, func(c *Client) (SearchResponse, error) {
+		return c.Search(ctx, req)
+	})
+	if err != nil {
+		return SearchResponse{}, err
+	}
+
+	merged := SearchResponse{
+		Results: []models.SearchResult{},
+		Terms:   make(map[string][]string),
+		Facets:  make(search.Facets),
+	}
+	var suggestions [][]search.Suggestion
+	for _, resp := range responses {
+		suggestions = append(suggestions, resp.Suggestions)
+		merged.Partial.Add(resp.Partial...)
+		merged.Facets.Merge(resp.Facets)
+		merged.TotalCount += resp.TotalCount
+		merged.Results = append(merged.Results, resp.Results...)
+		for term, forms := range resp.Terms {
+			merged.Terms[term] = appendMissing(merged.Terms[term], forms)
+		}
+		for word, variants := range resp.Variants {
+			if merged.Variants == nil {
+				merged.Variants = make(map[string][]string)
+			}
+			merged.Variants[word] = appendMissing(merged.Variants[word], variants)
+		}
+	}
+
+	for _, forms := range merged.Terms {
+		sort.Strings(forms)
+	}
+	merged.Suggestions = search.MergeSuggestions(suggestions...)
+
+	merged.Results = search.TopResults(merged.Results, req.Limit)
+	return merged, nil
+}
+
+// Complete returns the best completions of a prefix over all shards. A word's weight
+// is the sum of its shards' weights, so each shard sends its best `limit` of each kind.
+func (co *Coordinator) Complete(ctx context.Context, prefix string, limit int) (search.Completions, error) {
+	completions, err := fanOut(co.Shards, func(c *Client) (search.Completions, error) {
+		return c.Complete(ctx, prefix, limit)
+	})
+	if err != nil {
+		return search.Completions{}, err
+	}
+	return search.MergeCompletions(completions, limit), nil
+}
+
+// AddSnippets fills the snippets of results, asking each shard for those of its books.
+// Only the results of the page being shown need them.
+func (co *Coordinator) AddSnippets(ctx context.Context, query, searchType string, results []models.SearchResult) error {
+	bookIDs := make(map[*Client][]int)
+	for _, r := range results {
+		c := co.ShardFor(r.Book.ID)
+		bookIDs[c] = append(bookIDs[c], r.Book.ID)
+	}
+
+	responses, err := fanOut(co.Shards, func(c *Client) (SnippetsResponse, error) {
+		if len(bookIDs[c]) == 0 {
+			return SnippetsResponse{}, nil
+		}
+		return c.Snippets(ctx, SnippetsRequest{Query: query, Type: searchType, BookIDs: bookIDs[c]})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, resp := range responses {
+		for i := range results {
+			if snippets, found := resp.Snippets[results[i].Book.ID]; found {
+				results[i].Snippets = snippets
+			}
+		}
+	}
+	return nil
+}
+
+// appendMissing appends the values that are not in list yet
+func appendMissing(list []string, values []string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// fanOut calls fn on every shard in parallel.
+// A single failing shard fails the whole call: with a shard missing,
+// counts and pagination would silently be wrong.
+func fanOut[T any](shards []*Client, fn func(c *Client) (T, error)) ([]T, error) {
+	out := make([]T, len(shards))
+	errs := make([]error, len(shards))
+
+	var wg sync.WaitGroup
+	for i, c := range shards {
+		wg.Add(1)
+		go func(i int, c *Client) {
+			defer wg.Done()
+			out[i], errs[i] = fn(c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
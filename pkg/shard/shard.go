@@ -0,0 +1,175 @@
+package shard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/taqiyeddinedj/daar-project3/pkg/models"
+	"github.com/taqiyeddinedj/daar-project3/pkg/search"
+)
+
+// Assign returns the shard a book belongs to
+func Assign(bookID, numShards int) int {
+	return bookID % numShards
+}
+
+// SearchRequest asks a shard for its best results, scored with global stats
+type SearchRequest struct {
+	Query string             `json:"query"`
+	Type  string             `json:"type"`
+	Stats search.CorpusStats `json:"stats"`
+	// Filters is applied by the shard before picking its best results
+	Filters search.Filters `json:"filters"`
+	// Limit is the number of results to return (0 means all)
+	Limit int `json:"limit"`
+	// After asks for the results ranked after a cursor only
+	After *search.Cursor `json:"after,omitempty"`
+	// Explain asks for the explanation of the relevance of the results returned
+	Explain bool `json:"explain,omitempty"`
+}
+
+// SearchResponse is a shard's answer to a SearchRequest
+type SearchResponse struct {
+	Results    []models.SearchResult `json:"results"`
+	TotalCount int                   `json:"total_count"`
+	// Terms maps the matched terms to the words found in the shard's books
+	Terms map[string][]string `json:"terms"`
+	// Variants maps each fuzzy word or glob pattern of the query to the words of the shard's books it matched
+	Variants map[string][]string `json:"variants,omitempty"`
+	// Suggestions correct the words of the query found in few of the shard's books
+	Suggestions []search.Suggestion `json:"suggestions,omitempty"`
+	// Partial lists the limits the shard's search reached (see search.Limits)
+	Partial search.Partial `json:"partial,omitempty"`
+	// Facets counts the values of all the shard's matches, not only the returned ones
+	Facets search.Facets `json:"facets"`
+}
+
+// SnippetsRequest asks a shard for the snippets of some of its books
+type SnippetsRequest struct {
+	Query   string `json:"query"`
+	Type    string `json:"type"`
+	BookIDs []int  `json:"book_ids"`
+}
+
+// SnippetsResponse maps each requested book to its snippets
+type SnippetsResponse struct {
+	Snippets map[int][]models.Snippet `json:"snippets"`
+}
+
+// Client talks to one shard server
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewClient creates a client for a shard running at baseURL (e.g. http://localhost:8081)
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Stats returns the shard's collection stats for the terms matching a query
+func (c *Client) Stats(ctx context.Context, query, searchType string) (search.CorpusStats, error) {
+	var stats search.CorpusStats
+
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("type", searchType)
+
+	resp, err := c.get(ctx, "/api/shard/stats?"+params.Encode())
+	if err != nil {
+		return stats, fmt.Errorf("shard %s: %w", c.BaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if err := decodeResponse(c.BaseURL, resp, &stats); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// Complete returns the shard's completions of a prefix
+func (c *Client) Complete(ctx context.Context, prefix string, limit int) (search.Completions, error) {
+	var completions search.Completions
+
+	params := url.Values{}
+	params.Set("prefix", prefix)
+	params.Set("limit", strconv.Itoa(limit))
+
+	resp, err := c.get(ctx, "/api/suggest?"+params.Encode())
+	if err != nil {
+		return completions, fmt.Errorf("shard %s: %w", c.BaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	err = decodeResponse(c.BaseURL, resp, &completions)
+	return completions, err
+}
+
+// Search runs a query on the shard
+func (c *Client) Search(ctx context.Context, req SearchRequest) (SearchResponse, error) {
+	var out SearchResponse
+	err := c.post(ctx, "/api/shard/search", req, &out)
+	return out, err
+}
+
+// Snippets returns the snippets of books of the shard for a query
+func (c *Client) Snippets(ctx context.Context, req SnippetsRequest) (SnippetsResponse, error) {
+	var out SnippetsResponse
+	err := c.post(ctx, "/api/shard/snippets", req, &out)
+	return out, err
+}
+
+// get sends a GET request to the shard; it is abandoned when ctx ends
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.HTTP.Do(req)
+}
+
+// post sends a JSON request to the shard and decodes its answer into out.
+// The request is abandoned when ctx ends.
+func (c *Client) post(ctx context.Context, path string, req interface{}, out interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("shard %s: %w", c.BaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	return decodeResponse(c.BaseURL, resp, out)
+}
+
+func decodeResponse(baseURL string, resp *http.Response, v interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("shard %s: status %d: %s", baseURL, resp.StatusCode, apiErr.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("shard %s: failed to decode response: %w", baseURL, err)
+	}
+	return nil
+}
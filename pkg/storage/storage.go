@@ -2,23 +2,42 @@ package storage
 
 import (
 	"encoding/json"
+	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/taqiyeddinedj/daar-project3/pkg/indexer"
 )
 
 // SaveToFile saves the index to a JSON file
 func SaveToFile(idx *indexer.Indexer, filename string) error {
-	file, err := os.Create(filename)
+	return WriteFile(filename, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(idx)
+	})
+}
+
+// WriteFile writes a file through a temporary file renamed over it once complete,
+// so that a crash never leaves it half written
+func WriteFile(filename string, write func(w io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer os.Remove(file.Name()) // once renamed, there is nothing left to remove
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-
-	return encoder.Encode(idx)
+	err = write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filename)
 }
 
 // LoadFromFile loads the index from a JSON file
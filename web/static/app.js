@@ -6,13 +6,62 @@ let totalPages = 1;
 let currentBookId = null;
 let readerFontSize = 16;
 let searchType = 'keyword';
-let searchResults = []; // Store full results with occurrences
+let searchResults = []; // Results of the page shown, with occurrences
+let pageCursors = {}; // Cursor of each page after the first, as given by the page before
+let cursorSearch = ''; // Search the cursors belong to
+let activeFilters = {}; // Checked facet values, by facet name
+let readerBookId = null;
+let readerChapters = []; // Table of contents of the book open in the reader
+let readerChapter = 0; // Number of the chapter shown, from 1
+let readerText = ''; // Content of that chapter
+let readerMatches = []; // Matches of the search inside that book
+let readerMatchIndex = -1;
+let suggestTimer = null;
+let suggestIndex = -1; // Completion selected with the arrow keys
+
+// Facets shown next to the results, and the query parameter filtering each of them
+const FACETS = [
+    { name: 'author', title: 'Author', param: 'author' },
+    { name: 'language', title: 'Language', param: 'lang' },
+    { name: 'subject', title: 'Subject', param: 'subject' },
+    { name: 'cluster', title: 'Similar to', param: 'cluster' },
+    { name: 'length', title: 'Length', param: 'length' },
+];
 
 document.addEventListener('DOMContentLoaded', () => {
     document.getElementById('search-btn').addEventListener('click', () => performSearch(1));
     
-    document.getElementById('search-input').addEventListener('keypress', (e) => {
-        if (e.key === 'Enter') performSearch(1);
+    const searchInput = document.getElementById('search-input');
+    searchInput.addEventListener('keypress', (e) => {
+        if (e.key !== 'Enter') return;
+        const active = document.querySelector('.suggestion.active');
+        if (active) {
+            chooseSuggestion(active);
+        } else {
+            hideSuggestions();
+            performSearch(1);
+        }
+    });
+    searchInput.addEventListener('input', () => {
+        clearTimeout(suggestTimer);
+        suggestTimer = setTimeout(loadSuggestions, 100);
+    });
+    searchInput.addEventListener('keydown', (e) => {
+        if (e.key === 'ArrowDown' || e.key === 'ArrowUp') {
+            e.preventDefault();
+            moveSuggestion(e.key === 'ArrowDown' ? 1 : -1);
+        } else if (e.key === 'Escape') {
+            hideSuggestions();
+        }
+    });
+    searchInput.addEventListener('blur', () => setTimeout(hideSuggestions, 150));
+
+    document.getElementById('suggestions').addEventListener('mousedown', (e) => {
+        const item = e.target.closest('.suggestion');
+        if (item) {
+            e.preventDefault();
+            chooseSuggestion(item);
+        }
     });
 
     // Tab switching with placeholder update
@@ -27,8 +76,112 @@ document.addEventListener('DOMContentLoaded', () => {
             document.getElementById('search-input').placeholder = placeholder;
         });
     });
+
+    document.getElementById('reader-search-input').addEventListener('keypress', (e) => {
+        if (e.key === 'Enter') {
+            // Enter again goes to the next match of the same search
+            if (readerMatches.length > 0 && e.target.value.trim() === e.target.dataset.searched) {
+                jumpToMatch(readerMatchIndex + 1);
+            } else {
+                searchInBook();
+            }
+        }
+    });
+
+    document.getElementById('reader-toc').addEventListener('change', (e) => {
+        loadChapter(parseInt(e.target.value, 10));
+    });
+
+    document.getElementById('did-you-mean').addEventListener('click', (e) => {
+        if (e.target.tagName === 'A') {
+            e.preventDefault();
+            document.getElementById('search-input').value = e.target.textContent;
+            performSearch(1);
+        }
+    });
+
+    document.getElementById('facets').addEventListener('change', (e) => {
+        if (e.target.type === 'checkbox') {
+            toggleFilter(e.target.dataset.facet, e.target.value, e.target.checked);
+        }
+    });
 });
 
+// Complete what is typed in the search box with words, titles and authors of the books.
+// Regex and wildcard searches are patterns, not words to complete.
+function loadSuggestions() {
+    const prefix = document.getElementById('search-input').value;
+    if (!prefix.trim() || searchType === 'regex' || searchType === 'glob') {
+        hideSuggestions();
+        return;
+    }
+
+    fetch(`${API_BASE}/suggest?prefix=${encodeURIComponent(prefix)}`)
+        .then(res => res.json())
+        .then(data => {
+            // the box may have changed while the request was running
+            if (document.getElementById('search-input').value === prefix) {
+                displaySuggestions(data);
+            }
+        })
+        .catch(err => console.error('Suggest error:', err));
+}
+
+function displaySuggestions(data) {
+    const el = document.getElementById('suggestions');
+    const sections = [
+        { title: 'Words', items: data.words || [], query: text => text },
+        { title: 'Titles', items: data.titles || [], query: text => `title:"${text}"` },
+        { title: 'Authors', items: data.authors || [], query: text => `author:"${text}"` },
+    ].filter(s => s.items.length > 0);
+
+    if (sections.length === 0) {
+        hideSuggestions();
+        return;
+    }
+
+    el.innerHTML = sections.map(s => `
+        <h4>${s.title}</h4>
+        ${s.items.map(item => `
+            <div class="suggestion" data-query="${escapeHtml(s.query(item.text)).replace(/"/g, '&quot;')}">
+                <span>${escapeHtml(item.text)}</span>
+                <span class="weight">${item.weight} ${item.weight === 1 ? 'book' : 'books'}</span>
+            </div>
+        `).join('')}
+    `).join('');
+    suggestIndex = -1;
+    el.classList.remove('hidden');
+}
+
+function moveSuggestion(step) {
+    const items = document.querySelectorAll('.suggestion');
+    if (items.length === 0) return;
+
+    items.forEach(item => item.classList.remove('active'));
+    suggestIndex = (suggestIndex + step + items.length) % items.length;
+    items[suggestIndex].classList.add('active');
+    items[suggestIndex].scrollIntoView({ block: 'nearest' });
+}
+
+// A word completes the search box; a title or an author is searched right away
+function chooseSuggestion(item) {
+    const input = document.getElementById('search-input');
+    input.value = item.dataset.query;
+    hideSuggestions();
+    if (input.value.includes(':')) {
+        performSearch(1);
+    } else {
+        input.focus();
+    }
+}
+
+function hideSuggestions() {
+    const el = document.getElementById('suggestions');
+    el.classList.add('hidden');
+    el.innerHTML = '';
+    suggestIndex = -1;
+}
+
 function performSearch(page = 1) {
     const query = document.getElementById('search-input').value.trim();
     if (!query) {
@@ -36,16 +189,32 @@ function performSearch(page = 1) {
         return;
     }
 
+    // Filters belong to the results of a query, a new query starts without them
+    if (query !== currentQuery) {
+        activeFilters = {};
+    }
+
     currentQuery = query;
     currentPage = page;
 
     showLoading();
     document.getElementById('results-section').classList.remove('hidden');
 
-    fetch(`${API_BASE}/search?q=${encodeURIComponent(query)}&type=${searchType}&page=${page}`)
+    // A page reached from the one before is asked for with its cursor, cheaper than its number
+    const params = `q=${encodeURIComponent(query)}&type=${searchType}${filterParams()}`;
+    if (params !== cursorSearch) {
+        pageCursors = {};
+        cursorSearch = params;
+    }
+    const cursor = pageCursors[page] ? `&cursor=${pageCursors[page]}` : '';
+
+    fetch(`${API_BASE}/search?${params}&page=${page}${cursor}`)
         .then(res => res.json())
         .then(data => {
             hideLoading();
+            if (data.next_cursor) {
+                pageCursors[page + 1] = data.next_cursor;
+            }
             searchResults = data.results || []; // Store results with occurrences
             displayResults(data);
             updatePagination(data);
@@ -62,6 +231,10 @@ function displayResults(data) {
     const count = document.getElementById('results-count');
 
     count.textContent = `Found ${data.total_count} books - Page ${data.page} of ${data.total_pages}`;
+    displayDidYouMean(data.did_you_mean);
+    displayPartial(data.partial);
+    displayMatchedTerms(data.terms, data.variants);
+    displayFacets(data.facets);
 
     if (!data.books || data.books.length === 0) {
         grid.innerHTML = '<p style="grid-column:1/-1;text-align:center;color:#808080;padding:2rem;">No books found</p>';
@@ -73,7 +246,8 @@ function displayResults(data) {
         const result = searchResults.find(r => r.book.id === book.id);
         return {
             ...book,
-            occurrences: result ? result.occurrences : 0
+            occurrences: result ? result.occurrences : 0,
+            snippets: result && result.snippets ? result.snippets : []
         };
     });
 
@@ -91,10 +265,120 @@ function displayResults(data) {
                     </div>
                 </div>
             </div>
+            ${displaySnippets(book.snippets)}
         </div>
     `).join('');
 }
 
+// Passages of a book around the matched words. The fragments come escaped from the
+// server, with the matches in <mark> tags.
+function displaySnippets(snippets) {
+    if (!snippets.length) return '';
+    return `<div class="book-snippets">${snippets.map(s => `<p class="snippet">${s.fragment}</p>`).join('')}</div>`;
+}
+
+// Query string of the checked facet values, e.g. "&lang=fr&author=Victor%20Hugo"
+function filterParams() {
+    return FACETS.map(facet => [...(activeFilters[facet.name] || [])]
+        .map(value => `&${facet.param}=${encodeURIComponent(value)}`)
+        .join('')).join('');
+}
+
+function toggleFilter(facet, value, checked) {
+    if (!activeFilters[facet]) {
+        activeFilters[facet] = new Set();
+    }
+    if (checked) {
+        activeFilters[facet].add(value);
+    } else {
+        activeFilters[facet].delete(value);
+    }
+    performSearch(1);
+}
+
+// Show a group of checkboxes per facet, with the number of matching books of each value
+function displayFacets(facets) {
+    const container = document.getElementById('facets');
+    facets = facets || {};
+
+    container.innerHTML = FACETS.map(facet => {
+        const checked = activeFilters[facet.name] || new Set();
+        const values = [...(facets[facet.name] || [])];
+
+        // Keep checked values visible so they can be unchecked, even when nothing matches them anymore
+        checked.forEach(value => {
+            if (!values.some(v => v.value === value)) {
+                values.push({ value: value, count: 0 });
+            }
+        });
+        if (values.length === 0) return '';
+
+        return `
+            <div class="facet-group">
+                <h4>${facet.title}</h4>
+                ${values.map(v => `
+                    <label class="facet-value">
+                        <input type="checkbox" data-facet="${facet.name}" value="${escapeHtml(v.value).replace(/"/g, '&quot;')}" ${checked.has(v.value) ? 'checked' : ''}>
+                        <span>${escapeHtml(v.label || v.value)}</span>
+                        <span class="facet-count">${v.count}</span>
+                    </label>
+                `).join('')}
+            </div>
+        `;
+    }).join('');
+}
+
+// Offer the query with its misspelled words corrected, when some were found in few books
+function displayDidYouMean(correction) {
+    const el = document.getElementById('did-you-mean');
+    if (!correction) {
+        el.classList.add('hidden');
+        el.innerHTML = '';
+        return;
+    }
+    el.innerHTML = `Did you mean <a href="#">${escapeHtml(correction)}</a>?`;
+    el.classList.remove('hidden');
+}
+
+// Warn that the search stopped at a limit of the server and the results are incomplete
+function displayPartial(limits) {
+    const el = document.getElementById('partial');
+    const reasons = {
+        terms: 'the query matches too many words',
+        results: 'the query matches too many books',
+        time: 'the search took too long'
+    };
+    if (!limits || limits.length === 0) {
+        el.classList.add('hidden');
+        el.textContent = '';
+        return;
+    }
+    el.textContent = `Partial results: ${limits.map(l => reasons[l] || l).join(', ')}. Try a more specific query.`;
+    el.classList.remove('hidden');
+}
+
+// Show the words of the books that matched the query (e.g. whales, whaling for "whale")
+// and, for fuzzy words, the spellings they were taken for (wahle → whale)
+function displayMatchedTerms(terms, variants) {
+    const el = document.getElementById('matched-terms');
+    const forms = [...new Set(Object.values(terms || {}).flat())];
+
+    if (forms.length === 0) {
+        el.textContent = Object.keys(variants || {}).length > 0 ? 'No close words found' : '';
+        return;
+    }
+
+    const shown = forms.slice(0, 15).join(', ');
+    let text = `Matching words: ${shown}${forms.length > 15 ? `, ... (${forms.length} in total)` : ''}`;
+
+    const fuzzy = Object.entries(variants || {})
+        .map(([word, words]) => `${word} → ${words.length > 0 ? words.slice(0, 5).join(', ') : 'nothing close'}`);
+    if (fuzzy.length > 0) {
+        text += ` (fuzzy: ${fuzzy.join('; ')})`;
+    }
+    el.textContent = text;
+}
+
 function updatePagination(data) {
     const pagination = document.getElementById('pagination');
     totalPages = data.total_pages;
@@ -160,17 +444,48 @@ function loadBookDetails(bookId) {
     });
 }
 
+// Optional header metadata shown on the book page, in this order
+const BOOK_METADATA = [
+    ['translator', 'Translator'],
+    ['editor', 'Editor'],
+    ['illustrator', 'Illustrator'],
+    ['language', 'Language'],
+    ['release_date', 'Release Date'],
+    ['original_publication', 'Original Publication'],
+    ['credits', 'Credits'],
+    ['subjects', 'Subjects'],
+    ['bookshelves', 'Bookshelves'],
+];
+
+// authorYears formats the lifetime of the first catalog author, e.g. " (1775–1817)"
+function authorYears(book) {
+    const person = (book.authors || [])[0];
+    if (!person || !person.birth_year) return '';
+    return ` (${person.birth_year}–${person.death_year || ''})`;
+}
+
 function displayBookDetails(book) {
     const details = document.getElementById('book-details');
+
+    const metadataRows = BOOK_METADATA
+        .filter(([key]) => book[key])
+        .map(([key, label]) => `
+                <div class="book-info-row">
+                    <span class="book-info-label">${label}:</span>
+                    <span class="book-info-value">${escapeHtml([].concat(book[key]).join(', '))}</span>
+                </div>`)
+        .join('');
+
     details.innerHTML = `
         <div class="book-header">
             <div class="book-cover">📚</div>
             <div class="book-info">
                 <h2>${escapeHtml(book.title)}</h2>
+                ${book.subtitle ? `<p class="book-subtitle">${escapeHtml(book.subtitle)}</p>` : ''}
                 <div class="book-info-row">
                     <span class="book-info-label">Author:</span>
-                    <span class="book-info-value">${escapeHtml(book.author)}</span>
-                </div>
+                    <span class="book-info-value">${escapeHtml(book.author + authorYears(book))}</span>
+                </div>${metadataRows}
                 <div class="book-info-row">
                     <span class="book-info-label">Book ID:</span>
                     <span class="book-info-value">${book.id}</span>
@@ -208,11 +523,16 @@ function displayRecommendations(recommendations) {
     `).join('');
 }
 
+// Open the reader on a book: its table of contents, then the chapter of the
+// first match of the current search, or the first chapter
 function loadBookContent(bookId) {
+    readerBookId = bookId;
+    readerChapter = 0;
     showReaderView();
     showLoading();
+    document.getElementById('reader-content').textContent = '';
 
-    fetch(`${API_BASE}/content/${bookId}`)
+    fetch(`${API_BASE}/book/${bookId}/toc`)
         .then(res => {
             if (!res.ok) {
                 return res.json().then(err => {
@@ -223,10 +543,20 @@ function loadBookContent(bookId) {
         })
         .then(data => {
             hideLoading();
-            if (!data || !data.content) {
+            readerChapters = data.chapters || [];
+            if (readerChapters.length === 0) {
                 throw new Error('No content received from server');
             }
-            displayBookContent(data);
+            displayToc();
+
+            clearReaderMatches();
+            const input = document.getElementById('reader-search-input');
+            input.value = currentQuery;
+            if (currentQuery) {
+                searchInBook();
+            } else {
+                loadChapter(1);
+            }
         })
         .catch(err => {
             hideLoading();
@@ -238,10 +568,144 @@ function loadBookContent(bookId) {
         });
 }
 
-function displayBookContent(data) {
+function displayToc() {
+    const select = document.getElementById('reader-toc');
+    select.innerHTML = readerChapters.map(ch => `
+        <option value="${ch.number}">${ch.level > 1 ? '\u00a0\u00a0\u00a0' : ''}${escapeHtml(ch.title)}</option>
+    `).join('');
+}
+
+// Show one chapter of the book, then call done (e.g. to scroll to a match)
+function loadChapter(number, done) {
+    if (number < 1 || number > readerChapters.length) return;
+
+    fetch(`${API_BASE}/book/${readerBookId}/chapters/${number}`)
+        .then(res => res.json())
+        .then(data => {
+            if (data.error) {
+                throw new Error(data.error);
+            }
+            readerChapter = number;
+            displayChapter(data);
+            if (done) {
+                done();
+            } else {
+                document.querySelector('.reader-container').scrollIntoView();
+            }
+        })
+        .catch(err => {
+            console.error('Load chapter error:', err);
+            alert('Failed to load chapter: ' + err.message);
+        });
+}
+
+function displayChapter(data) {
     const content = document.getElementById('reader-content');
-    content.textContent = data.content;
+    readerText = data.content;
+    renderReaderMatches();
     content.style.fontSize = `${readerFontSize}px`;
+
+    document.getElementById('reader-toc').value = data.number;
+    document.querySelectorAll('.chapter-prev').forEach(b => b.disabled = data.number <= 1);
+    document.querySelectorAll('.chapter-next').forEach(b => b.disabled = data.number >= data.total_chapters);
+    document.getElementById('reader-chapter-info').textContent = `${data.number} / ${data.total_chapters}`;
+}
+
+function changeChapter(delta) {
+    loadChapter(readerChapter + delta);
+}
+
+// Search the book open in the reader, with the type of search of the home page
+function searchInBook() {
+    const input = document.getElementById('reader-search-input');
+    const query = input.value.trim();
+    if (!query || !readerBookId) {
+        clearReaderMatches();
+        return;
+    }
+
+    fetch(`${API_BASE}/book/${readerBookId}/search?q=${encodeURIComponent(query)}&type=${searchType}`)
+        .then(res => res.json())
+        .then(data => {
+            if (data.error) {
+                throw new Error(data.error);
+            }
+            input.dataset.searched = query;
+            readerMatches = data.matches || [];
+            if (readerMatches.length > 0) {
+                jumpToMatch(0);
+            } else {
+                jumpToMatch(-1);
+                if (readerChapter === 0) {
+                    loadChapter(1);
+                } else {
+                    renderReaderMatches();
+                }
+            }
+        })
+        .catch(err => {
+            console.error('Book search error:', err);
+            clearReaderMatches();
+            document.getElementById('reader-match-count').textContent = 'Search failed';
+            if (readerChapter === 0) {
+                loadChapter(1);
+            }
+        });
+}
+
+// Show the chapter again with its matches in a <mark>. Offsets are in characters of the chapter.
+function renderReaderMatches() {
+    const content = document.getElementById('reader-content');
+    content.textContent = '';
+
+    let pos = 0;
+    readerMatches.forEach((match, i) => {
+        if (match.chapter !== readerChapter) return;
+        content.appendChild(document.createTextNode(readerText.slice(pos, match.chapter_offset)));
+        const mark = document.createElement('mark');
+        mark.id = `reader-match-${i}`;
+        mark.className = 'reader-match';
+        mark.textContent = readerText.slice(match.chapter_offset, match.chapter_offset + match.length);
+        content.appendChild(mark);
+        pos = match.chapter_offset + match.length;
+    });
+    content.appendChild(document.createTextNode(readerText.slice(pos)));
+}
+
+// Scroll to a match, going round from the last match to the first,
+// and turning to its chapter if needed
+function jumpToMatch(index) {
+    const count = document.getElementById('reader-match-count');
+    if (readerMatches.length === 0) {
+        readerMatchIndex = -1;
+        count.textContent = document.getElementById('reader-search-input').dataset.searched ? 'No matches' : '';
+        return;
+    }
+
+    const previous = document.getElementById(`reader-match-${readerMatchIndex}`);
+    if (previous) previous.classList.remove('current');
+
+    readerMatchIndex = (index + readerMatches.length) % readerMatches.length;
+    const match = readerMatches[readerMatchIndex];
+    count.textContent = `${readerMatchIndex + 1} / ${readerMatches.length} (line ${match.line})`;
+
+    const showMatch = () => {
+        const mark = document.getElementById(`reader-match-${readerMatchIndex}`);
+        mark.classList.add('current');
+        mark.scrollIntoView({ behavior: 'smooth', block: 'center' });
+    };
+    if (match.chapter !== readerChapter) {
+        loadChapter(match.chapter, showMatch);
+    } else {
+        showMatch();
+    }
+}
+
+function clearReaderMatches() {
+    readerMatches = [];
+    readerMatchIndex = -1;
+    document.getElementById('reader-match-count').textContent = '';
+    delete document.getElementById('reader-search-input').dataset.searched;
 }
 
 function closeReader() {